@@ -8,6 +8,7 @@ import (
 	"agent/pkg/logger"
 	"context"
 	"fmt"
+	"math"
 	"strconv"
 	"sync"
 	"time"
@@ -25,7 +26,12 @@ type Application struct {
 	logger  *zap.Logger
 }
 
-var ops map[string]func(float64, float64) float64
+var (
+	ops        map[string]func(float64, float64) float64
+	checkedOps map[string]func(float64, float64) (float64, error)
+	unaryOps   map[string]func(float64) float64
+	naryOps    map[string]func(...float64) float64
+)
 
 func init() {
 	ops = make(map[string]func(float64, float64) float64)
@@ -33,12 +39,58 @@ func init() {
 	ops["-"] = subtraction
 	ops["*"] = multiplication
 	ops["/"] = division
+	ops["^"] = power
+	ops["&&"] = and
+	ops["||"] = or
+	for op, accept := range comparisons {
+		ops[op] = comparison(accept)
+	}
+
+	checkedOps = make(map[string]func(float64, float64) (float64, error))
+	checkedOps["%"] = modulo
+	checkedOps["//"] = floorDivision
+	checkedOps["&"] = bitwise(func(x, y int64) int64 { return x & y })
+	checkedOps["|"] = bitwise(func(x, y int64) int64 { return x | y })
+	checkedOps["xor"] = bitwise(func(x, y int64) int64 { return x ^ y })
+	checkedOps["<<"] = shift(true)
+	checkedOps[">>"] = shift(false)
+
+	unaryOps = make(map[string]func(float64) float64)
+	unaryOps["neg"] = negation
+	unaryOps["not"] = not
+	unaryOps["sqrt"] = math.Sqrt
+	unaryOps["sin"] = math.Sin
+	unaryOps["cos"] = math.Cos
+	unaryOps["log"] = math.Log
+	unaryOps["abs"] = math.Abs
+
+	naryOps = make(map[string]func(...float64) float64)
+	naryOps["min"] = minimum
+	naryOps["max"] = maximum
 }
 
 func addition(a, b float64) float64       { return a + b }
 func subtraction(a, b float64) float64    { return a - b }
 func multiplication(a, b float64) float64 { return a * b }
 func division(a, b float64) float64       { return a / b }
+func power(a, b float64) float64          { return math.Pow(a, b) }
+func negation(a float64) float64          { return -a }
+
+func minimum(args ...float64) float64 {
+	result := args[0]
+	for _, arg := range args[1:] {
+		result = math.Min(result, arg)
+	}
+	return result
+}
+
+func maximum(args ...float64) float64 {
+	result := args[0]
+	for _, arg := range args[1:] {
+		result = math.Max(result, arg)
+	}
+	return result
+}
 
 func NewApplicationAgent(cfg *config.Config) (*Application, error) {
 	logger := logger.SetupLogger()
@@ -113,22 +165,96 @@ func runWorker(tasks <-chan resp.Task, results chan<- req.Result, ready chan<- s
 
 		time.Sleep(task.OperationTime)
 
-		arg1, err1 := strconv.ParseFloat(task.Arg1, 64)
-		arg2, err2 := strconv.ParseFloat(task.Arg2, 64)
-
-		if err1 != nil || err2 != nil {
-			results <- req.Result{
-				ID:     task.ID,
-				Value:  0.0,
-				UserID: task.UserID,
-			}
+		var value any
+		var err error
+		if task.Rational {
+			value, err = executeRational(task)
+		} else if task.Exact {
+			value, err = executeExact(task)
 		} else {
-			value := ops[task.Operation](arg1, arg2)
-			results <- req.Result{
-				ID:     task.ID,
-				Value:  value,
-				UserID: task.UserID,
-			}
+			value, err = executeFloat(task)
+		}
+		if err == nil {
+			value, err = applyNonFinite(task.NonFinite, value)
+		}
+		var unit string
+		if err == nil {
+			unit, err = resultUnit(task)
+		}
+		var code string
+		if err != nil {
+			value, code = err, errorCode(err)
+		}
+
+		results <- req.Result{
+			ID:        task.ID,
+			Value:     value,
+			Unit:      unit,
+			ErrorCode: code,
+			UserID:    task.UserID,
+		}
+	}
+}
+
+func execute(task resp.Task) (float64, error) {
+	args, err := parseArgs(task)
+	if err != nil {
+		return 0, err
+	}
+	if len(args) == 0 {
+		return 0, fmt.Errorf("%w: no arguments for operation %q", errInvalidOperand, task.Operation)
+	}
+
+	if op, ok := naryOps[task.Operation]; ok {
+		return op(args...), nil
+	}
+
+	if op, ok := unaryOps[task.Operation]; ok {
+		return op(args[0]), nil
+	}
+
+	if len(args) > 1 {
+		if op, ok := ops[task.Operation]; ok {
+			return op(args[0], args[1]), nil
+		}
+		if op, ok := checkedOps[task.Operation]; ok {
+			return op(args[0], args[1])
 		}
 	}
+
+	return 0, fmt.Errorf("%w %q", errUnknownOperation, task.Operation)
+}
+
+func parseArgs(task resp.Task) ([]float64, error) {
+	if len(task.Operands) > 0 {
+		args := make([]float64, len(task.Operands))
+		for i, operand := range task.Operands {
+			args[i] = operand.Value
+		}
+		return args, nil
+	}
+
+	raw := legacyArgs(task)
+	args := make([]float64, len(raw))
+	for i, arg := range raw {
+		value, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q", errInvalidOperand, arg)
+		}
+		args[i] = value
+	}
+
+	return args, nil
+}
+
+func legacyArgs(task resp.Task) []string {
+	if len(task.Args) > 0 {
+		return task.Args
+	}
+
+	raw := []string{task.Arg1}
+	if task.Arg2 != "" {
+		raw = append(raw, task.Arg2)
+	}
+	return raw
 }
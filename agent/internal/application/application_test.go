@@ -0,0 +1,420 @@
+package application
+
+import (
+	"agent/internal/models/resp"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecuteIntegerOperations(t *testing.T) {
+	tests := []struct {
+		operation string
+		a, b      float64
+		expected  float64
+		err       error
+	}{
+		{operation: "%", a: -7, b: 3, expected: 2},
+		{operation: "%", a: 7.5, b: 2, expected: 1.5},
+		{operation: "%", a: 1, b: 0, err: errDivisionByZero},
+		{operation: "//", a: -7, b: 2, expected: -4},
+		{operation: "//", a: 1, b: 0, err: errDivisionByZero},
+		{operation: "&", a: 6, b: 3, expected: 2},
+		{operation: "|", a: 6, b: 3, expected: 7},
+		{operation: "xor", a: 6, b: 3, expected: 5},
+		{operation: "&", a: 1.5, b: 1, err: errNonIntegerOperand},
+		{operation: "<<", a: 3, b: 4, expected: 48},
+		{operation: ">>", a: -9, b: 1, expected: -5},
+		{operation: "<<", a: 1, b: -1, err: errInvalidShift},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.operation, func(t *testing.T) {
+			task := resp.Task{
+				Operation: tt.operation,
+				Operands:  []resp.Operand{{Value: tt.a}, {Value: tt.b}},
+			}
+
+			result, err := execute(task)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestExecuteExactIntegerOperations(t *testing.T) {
+	tests := []struct {
+		operation string
+		a, b      string
+		expected  string
+		err       error
+	}{
+		{operation: "%", a: "-7", b: "3", expected: "2"},
+		{operation: "//", a: "7/2", b: "1/2", expected: "7"},
+		{operation: "<<", a: "1", b: "100", expected: "1267650600228229401496703205376"},
+		{operation: "xor", a: "1/2", b: "1", err: errNonIntegerOperand},
+		{operation: "%", a: "1", b: "0", err: errDivisionByZero},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.operation, func(t *testing.T) {
+			task := resp.Task{
+				Operation: tt.operation,
+				Exact:     true,
+				Operands:  []resp.Operand{{Exact: tt.a}, {Exact: tt.b}},
+			}
+
+			result, err := executeExact(task)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestExecuteLogicalOperations(t *testing.T) {
+	tests := []struct {
+		operation string
+		args      []float64
+		expected  float64
+	}{
+		{operation: "<", args: []float64{1, 2}, expected: 1},
+		{operation: ">=", args: []float64{1, 2}, expected: 0},
+		{operation: "==", args: []float64{0.5, 0.5}, expected: 1},
+		{operation: "!=", args: []float64{0.5, 0.5}, expected: 0},
+		{operation: "&&", args: []float64{2, 0}, expected: 0},
+		{operation: "||", args: []float64{0, -3}, expected: 1},
+		{operation: "not", args: []float64{0}, expected: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.operation, func(t *testing.T) {
+			operands := make([]resp.Operand, len(tt.args))
+			exact := make([]resp.Operand, len(tt.args))
+			for i, arg := range tt.args {
+				operands[i] = resp.Operand{Value: arg}
+				exact[i] = resp.Operand{Value: arg, Exact: big.NewRat(int64(arg*2), 2).RatString()}
+			}
+
+			result, err := execute(resp.Task{Operation: tt.operation, Operands: operands})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+
+			exactResult, err := executeExact(resp.Task{Operation: tt.operation, Exact: true, Operands: exact})
+			require.NoError(t, err)
+			assert.Equal(t, big.NewRat(int64(tt.expected), 1).RatString(), exactResult)
+		})
+	}
+}
+
+// TestExactElementaryFunctions uses the table of the orchestrator tests.
+func TestExactElementaryFunctions(t *testing.T) {
+	tests := []struct {
+		operation string
+		args      []string
+		expected  string
+	}{
+		{"sqrt", []string{"2"}, "1.41421356237309504880168872420969807856967187537695"},
+		{"sqrt", []string{"1e60"}, "1000000000000000000000000000000"},
+		{"sqrt", []string{"123456789012345678901234567890"}, "351364182882014.42531112223816981261182604308051128447256256976262"},
+		{"log", []string{"2"}, "0.69314718055994530941723212145817656807550013436026"},
+		{"log", []string{"1"}, "0"},
+		{"log", []string{"0.001"}, "-6.90775527898213705205397436405309262280330446588632"},
+		{"log", []string{"1e100"}, "230.2585092994045684017991454684364207601101488628773"},
+		{"sin", []string{"1"}, "0.84147098480789650665250232163029899962256306079837"},
+		{"cos", []string{"1"}, "0.54030230586813971740093660744297660373231042061792"},
+		{"sin", []string{"0"}, "0"},
+		{"cos", []string{"0"}, "1"},
+		{"sin", []string{"100"}, "-0.50636564110975879365655761045978543206503272129066"},
+		{"cos", []string{"1e20"}, "0.763970404441728300400146802737881122834473441747"},
+		{"^", []string{"2", "0.5"}, "1.41421356237309504880168872420969807856967187537695"},
+		{"^", []string{"10", "2.5"}, "316.22776601683793319988935444327185337195551393252168"},
+		{"^", []string{"3", "50.5"}, "1243435789333745207971490.00407809988777135686720911848017909705693676041164"},
+		{"^", []string{"2", "1/3"}, "1.25992104989487316476721060727822835057025146470151"},
+		{"^", []string{"0.5", "-1.5"}, "2.8284271247461900976033774484193961571393437507539"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.operation+" "+tt.args[0], func(t *testing.T) {
+			operands := make([]resp.Operand, len(tt.args))
+			for i, arg := range tt.args {
+				operands[i] = resp.Operand{Exact: arg}
+			}
+			result, err := executeRational(resp.Task{Operation: tt.operation, Exact: true, Operands: operands})
+			require.NoError(t, err)
+
+			// the agent answers with the full fraction; compare 50 digits
+			digits := strings.TrimSuffix(strings.TrimRight(result.FloatString(50), "0"), ".")
+			assert.Equal(t, tt.expected, digits)
+		})
+	}
+}
+
+// TestExactDigits checks results to the requested number of digits against
+// the table the orchestrator tests use.
+func TestExactDigits(t *testing.T) {
+	tests := []struct {
+		operation string
+		args      []string
+		digits    int
+		expected  string
+	}{
+		{"sqrt", []string{"2"}, 10, "1.4142135624"},
+		{"sqrt", []string{"2"}, 30, "1.41421356237309504880168872421"},
+		{"sqrt", []string{"2"}, 100, "1.4142135623730950488016887242096980785696718753769480731766797379907324784621070388503875343276415727"},
+		{"/", []string{"1", "3"}, 1, "0.3"},
+		{"/", []string{"1", "3"}, 5, "0.33333"},
+		{"/", []string{"1", "3"}, 20, "0.33333333333333333333"},
+		{"log", []string{"2"}, 3, "0.693"},
+		{"log", []string{"2"}, 40, "0.6931471805599453094172321214581765680755"},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %d", tt.operation, tt.digits), func(t *testing.T) {
+			operands := make([]resp.Operand, len(tt.args))
+			for i, arg := range tt.args {
+				operands[i] = resp.Operand{Exact: arg}
+			}
+			result, err := executeRational(resp.Task{Operation: tt.operation, Exact: true, Digits: tt.digits, Operands: operands})
+			require.NoError(t, err)
+
+			digits := strings.TrimSuffix(strings.TrimRight(result.FloatString(tt.digits), "0"), ".")
+			assert.Equal(t, tt.expected, digits)
+		})
+	}
+}
+
+func TestExecuteRationalOperations(t *testing.T) {
+	tests := []struct {
+		operation string
+		a, b      resp.Operand
+		expected  *big.Rat
+		fails     bool
+	}{
+		{operation: "+", a: resp.Operand{Numerator: "1", Denominator: "3"}, b: resp.Operand{Numerator: "1", Denominator: "6"}, expected: big.NewRat(1, 2)},
+		{operation: "/", a: resp.Operand{Numerator: "-2", Denominator: "3"}, b: resp.Operand{Numerator: "4", Denominator: "9"}, expected: big.NewRat(-3, 2)},
+		{operation: "*", a: resp.Operand{Numerator: "2", Denominator: "3"}, b: resp.Operand{Exact: "3/4"}, expected: big.NewRat(1, 2)},
+		{operation: "-", a: resp.Operand{Numerator: "1", Denominator: "0"}, b: resp.Operand{Numerator: "1", Denominator: "1"}, fails: true},
+		{operation: "^", a: resp.Operand{Numerator: "2", Denominator: "3"}, b: resp.Operand{Numerator: "-2", Denominator: "1"}, expected: big.NewRat(9, 4)},
+		{operation: "^", a: resp.Operand{Numerator: "4", Denominator: "1"}, b: resp.Operand{Numerator: "1", Denominator: "2"}, fails: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.operation, func(t *testing.T) {
+			result, err := executeRational(resp.Task{
+				Operation: tt.operation,
+				Rational:  true,
+				Operands:  []resp.Operand{tt.a, tt.b},
+			})
+			if tt.fails {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected.RatString(), result.RatString())
+		})
+	}
+
+	// the agent approximates sqrt(2) for exact tasks but not for rational ones
+	sqrt := resp.Task{Operation: "sqrt", Exact: true, Operands: []resp.Operand{{Exact: "2"}}}
+	_, err := executeRational(sqrt)
+	require.NoError(t, err)
+
+	sqrt.Rational = true
+	_, err = executeRational(sqrt)
+	assert.ErrorIs(t, err, errIrrationalOperation)
+	assert.Equal(t, "irrational_operation", errorCode(err))
+}
+
+func TestExecuteComplexOperations(t *testing.T) {
+	tests := []struct {
+		operation string
+		operands  []resp.Operand
+		expected  any
+		err       error
+	}{
+		{operation: "*", operands: []resp.Operand{{Value: 3, Imag: 4}, {Value: 1, Imag: -2}}, expected: complex(11, -2)},
+		{operation: "sqrt", operands: []resp.Operand{{Value: -4}}, expected: complex(0, 2)},
+		{operation: "abs", operands: []resp.Operand{{Value: 3, Imag: 4}}, expected: complex(5, 0)},
+		{operation: "sqrt", operands: []resp.Operand{{Value: 4}}, expected: 2.0},
+		{operation: "/", operands: []resp.Operand{{Imag: 1}, {}}, err: errDivisionByZero},
+		{operation: "<", operands: []resp.Operand{{Imag: 1}, {Value: 2}}, err: errComplexOperand},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.operation, func(t *testing.T) {
+			result, err := executeFloat(resp.Task{Operation: tt.operation, Operands: tt.operands})
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestExecuteMatrixOperations(t *testing.T) {
+	square := &resp.Matrix{Rows: 2, Cols: 2, Values: []float64{1, 2, 3, 4}}
+	vector := &resp.Matrix{Rows: 1, Cols: 2, Values: []float64{1, 1}, Vector: true}
+
+	tests := []struct {
+		name      string
+		operation string
+		operands  []resp.Operand
+		expected  any
+		err       error
+	}{
+		{"sum", "+", []resp.Operand{{Matrix: square}, {Matrix: square}},
+			&resp.Matrix{Rows: 2, Cols: 2, Values: []float64{2, 4, 6, 8}}, nil},
+		{"scalar", "*", []resp.Operand{{Value: 2}, {Matrix: vector}},
+			&resp.Matrix{Rows: 1, Cols: 2, Values: []float64{2, 2}, Vector: true}, nil},
+		{"product", "*", []resp.Operand{{Matrix: square}, {Matrix: square}},
+			&resp.Matrix{Rows: 2, Cols: 2, Values: []float64{7, 10, 15, 22}}, nil},
+		{"column", "*", []resp.Operand{{Matrix: square}, {Matrix: vector}},
+			&resp.Matrix{Rows: 1, Cols: 2, Values: []float64{3, 7}, Vector: true}, nil},
+		{"row", "*", []resp.Operand{{Matrix: vector}, {Matrix: square}},
+			&resp.Matrix{Rows: 1, Cols: 2, Values: []float64{4, 6}, Vector: true}, nil},
+		{"transpose", "transpose", []resp.Operand{{Matrix: square}},
+			&resp.Matrix{Rows: 2, Cols: 2, Values: []float64{1, 3, 2, 4}}, nil},
+		{"dot", "dot", []resp.Operand{{Matrix: vector}, {Matrix: vector}}, 2.0, nil},
+		{"det", "det", []resp.Operand{{Matrix: square}}, -2.0, nil},
+		{"two vectors", "*", []resp.Operand{{Matrix: vector}, {Matrix: vector}}, nil, errShapeMismatch},
+		{"det of a vector", "det", []resp.Operand{{Matrix: vector}}, nil, errShapeMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := executeFloat(resp.Task{Operation: tt.operation, Operands: tt.operands})
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.InDeltaSlice(t, valuesOf(tt.expected), valuesOf(result), 1e-12)
+			if m, ok := tt.expected.(*resp.Matrix); ok {
+				assert.Equal(t, m.Vector, result.(*resp.Matrix).Vector)
+				assert.Equal(t, [2]int{m.Rows, m.Cols}, [2]int{result.(*resp.Matrix).Rows, result.(*resp.Matrix).Cols})
+			}
+		})
+	}
+}
+
+func valuesOf(value any) []float64 {
+	if m, ok := value.(*resp.Matrix); ok {
+		return m.Values
+	}
+	return []float64{value.(float64)}
+}
+
+func TestApplyNonFinite(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   string
+		result   any
+		expected any
+		err      error
+	}{
+		{"finite", "", 1.5, 1.5, nil},
+		{"default policy", "", math.Inf(1), nil, errNonFiniteResult},
+		{"error", nonFiniteError, math.Inf(-1), nil, errNonFiniteResult},
+		{"saturate", nonFiniteSaturate, math.Inf(-1), -math.MaxFloat64, nil},
+		{"saturate NaN", nonFiniteSaturate, math.NaN(), nil, errNonFiniteResult},
+		{"allow", nonFiniteAllow, math.Inf(1), math.Inf(1), nil},
+		{"complex", nonFiniteSaturate, complex(1, math.Inf(1)), complex(1, math.MaxFloat64), nil},
+		{"matrix", nonFiniteError, &resp.Matrix{Rows: 1, Cols: 2, Values: []float64{1, math.Inf(1)}}, nil, errNonFiniteResult},
+		{"exact", "", "1e400", "1e400", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := applyNonFinite(tt.policy, tt.result)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestResultUnit(t *testing.T) {
+	tests := []struct {
+		name      string
+		operation string
+		operands  []resp.Operand
+		expected  string
+		err       error
+	}{
+		{"plain numbers", "+", []resp.Operand{{Value: 1}, {Value: 2}}, "", nil},
+		{"sum", "+", []resp.Operand{{Value: 1, Unit: "m"}, {Value: 2, Unit: "m"}}, "m", nil},
+		{"speed", "/", []resp.Operand{{Value: 5000, Unit: "m"}, {Value: 1800, Unit: "s"}}, "m/s", nil},
+		{"energy", "*", []resp.Operand{{Value: 2, Unit: "m*kg/s^2"}, {Value: 3, Unit: "m"}}, "m^2*kg/s^2", nil},
+		{"frequency", "/", []resp.Operand{{Value: 1}, {Value: 2, Unit: "s"}}, "s^-1", nil},
+		{"square", "^", []resp.Operand{{Value: 3, Unit: "m"}, {Value: 2}}, "m^2", nil},
+		{"root", "sqrt", []resp.Operand{{Value: 9, Unit: "m^2"}}, "m", nil},
+		{"comparison", "<", []resp.Operand{{Value: 1, Unit: "s"}, {Value: 2, Unit: "s"}}, "", nil},
+		{"metres plus seconds", "+", []resp.Operand{{Value: 1, Unit: "m"}, {Value: 2, Unit: "s"}}, "", errDimensionMismatch},
+		{"sine", "sin", []resp.Operand{{Value: 1, Unit: "m"}}, "", errDimensionMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unit, err := resultUnit(resp.Task{Operation: tt.operation, Operands: tt.operands})
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, unit)
+		})
+	}
+}
+
+func TestExecuteUnknownOperation(t *testing.T) {
+	_, err := execute(resp.Task{Operation: "?", Operands: []resp.Operand{{Value: 1}, {Value: 2}}})
+	assert.ErrorIs(t, err, errUnknownOperation)
+}
+
+func TestErrorCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		task     resp.Task
+		expected string
+	}{
+		{"division by zero", resp.Task{Operation: "/", Exact: true, Arg1: "1", Arg2: "0"}, "division_by_zero"},
+		{"non-integer operand", resp.Task{Operation: "&", Operands: []resp.Operand{{Value: 1.5}, {Value: 2}}}, "non_integer_operand"},
+		{"unknown operation", resp.Task{Operation: "?", Operands: []resp.Operand{{Value: 1}, {Value: 2}}}, "unknown_operator"},
+		{"invalid operand", resp.Task{Operation: "+", Arg1: "x", Arg2: "1"}, "invalid_operand"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			if tt.task.Exact {
+				_, err = executeExact(tt.task)
+			} else {
+				_, err = executeFloat(tt.task)
+			}
+			require.Error(t, err)
+			assert.Equal(t, tt.expected, errorCode(err))
+		})
+	}
+
+	assert.Equal(t, "non_finite_result", errorCode(fmt.Errorf("%w: +Inf", errNonFiniteResult)))
+	assert.Equal(t, "agent_error", errorCode(errors.New("boom")))
+}
@@ -0,0 +1,217 @@
+package application
+
+import (
+	"math"
+	"math/big"
+)
+
+// Elementary functions of exact mode, computed with big.Float to the
+// requested number of decimal digits. The agent keeps an identical copy of
+// this file, apart from the package clause.
+
+// guardBits absorb the rounding errors of the intermediate steps.
+const guardBits = 64
+
+// maxExactLog bounds the logarithm of a power, as float64 does.
+var maxExactLog = math.Log(math.MaxFloat64)
+
+// precisionBits is the mantissa size that carries digits decimal digits.
+func precisionBits(digits int) uint {
+	return uint(math.Ceil(float64(digits)*math.Log2(10))) + 16
+}
+
+func newFloat(prec uint) *big.Float {
+	return new(big.Float).SetPrec(prec)
+}
+
+// bigFloat converts r keeping prec bits after the binary point as well as
+// every bit of its integer part.
+func bigFloat(r *big.Rat, prec uint) *big.Float {
+	if bits := r.Num().BitLen() - r.Denom().BitLen(); bits > 0 {
+		prec += uint(bits)
+	}
+	return newFloat(prec).SetRat(r)
+}
+
+// ratSqrt returns the square root of x, or false when x is negative.
+func ratSqrt(x *big.Rat, digits int) (*big.Rat, bool) {
+	if x.Sign() < 0 {
+		return nil, false
+	}
+	f := bigFloat(x, precisionBits(digits))
+	result, _ := f.Sqrt(f).Rat(nil)
+	return result, true
+}
+
+// ratLog returns the natural logarithm of x, or false when x is not
+// positive.
+func ratLog(x *big.Rat, digits int) (*big.Rat, bool) {
+	if x.Sign() <= 0 {
+		return nil, false
+	}
+	prec := precisionBits(digits) + guardBits
+	result, _ := bigLog(bigFloat(x, prec), prec).Rat(nil)
+	return result, true
+}
+
+// ratSin returns sin x.
+func ratSin(x *big.Rat, digits int) (*big.Rat, bool) {
+	prec := precisionBits(digits) + guardBits
+	result, _ := sinCos(bigFloat(x, prec), prec, 1).Rat(nil)
+	return result, true
+}
+
+// ratCos returns cos x.
+func ratCos(x *big.Rat, digits int) (*big.Rat, bool) {
+	prec := precisionBits(digits) + guardBits
+	result, _ := sinCos(bigFloat(x, prec), prec, 0).Rat(nil)
+	return result, true
+}
+
+// ratPower returns base^exponent as e^(exponent·ln base), or false when
+// the power is not a real number or overflows float64.
+func ratPower(base, exponent *big.Rat, digits int) (*big.Rat, bool) {
+	switch {
+	case base.Sign() < 0, base.Sign() == 0 && exponent.Sign() < 0:
+		return nil, false
+	case base.Sign() == 0:
+		return new(big.Rat), true
+	}
+
+	logPower := func(prec uint) *big.Float {
+		return newFloat(prec).Mul(bigLog(bigFloat(base, prec), prec), bigFloat(exponent, prec))
+	}
+	estimate, _ := logPower(64).Float64()
+	switch {
+	case estimate > maxExactLog:
+		return nil, false
+	case estimate < -maxExactLog:
+		return new(big.Rat), true
+	}
+
+	// the result has about estimate/ln 2 bits before the binary point
+	prec := precisionBits(digits) + guardBits
+	if estimate > 0 {
+		prec += uint(estimate/math.Ln2) + 1
+	}
+	result, _ := bigExp(logPower(prec), prec).Rat(nil)
+	return result, true
+}
+
+// negligible reports whether adding term no longer changes sum at prec
+// bits.
+func negligible(term, sum *big.Float, prec uint) bool {
+	return term.Sign() == 0 || sum.Sign() != 0 && term.MantExp(nil) < sum.MantExp(nil)-int(prec)
+}
+
+// inverseSeries returns atan(1/n) when alternating and atanh(1/n)
+// otherwise.
+func inverseSeries(n int64, prec uint, alternating bool) *big.Float {
+	sum, term := newFloat(prec), newFloat(prec)
+	power := newFloat(prec).Quo(newFloat(prec).SetInt64(1), newFloat(prec).SetInt64(n))
+	square := newFloat(prec).SetInt64(n * n)
+	for k := int64(0); ; k++ {
+		term.Quo(power, newFloat(prec).SetInt64(2*k+1))
+		if negligible(term, sum, prec) {
+			return sum
+		}
+		if alternating && k%2 == 1 {
+			sum.Sub(sum, term)
+		} else {
+			sum.Add(sum, term)
+		}
+		power.Quo(power, square)
+	}
+}
+
+// bigPi returns π by Machin's formula π = 16·atan(1/5) − 4·atan(1/239).
+func bigPi(prec uint) *big.Float {
+	p := prec + guardBits
+	a, b := inverseSeries(5, p, true), inverseSeries(239, p, true)
+	a.Mul(a, newFloat(p).SetInt64(16))
+	b.Mul(b, newFloat(p).SetInt64(4))
+	return newFloat(prec).Sub(a, b)
+}
+
+// bigLn2 returns ln 2 = 2·atanh(1/3).
+func bigLn2(prec uint) *big.Float {
+	ln2 := inverseSeries(3, prec+guardBits, false)
+	return newFloat(prec).Mul(ln2, newFloat(prec).SetInt64(2))
+}
+
+// bigLog returns ln x for x > 0. With x = m·2^k and m in [√½, √2),
+// ln x = k·ln 2 + 2·atanh((m−1)/(m+1)), whose series converges fast.
+func bigLog(x *big.Float, prec uint) *big.Float {
+	p := prec + guardBits
+	m := newFloat(p)
+	k := x.MantExp(m)
+	if m.Cmp(big.NewFloat(math.Sqrt2/2)) < 0 {
+		m.SetMantExp(m, 1)
+		k--
+	}
+
+	one := newFloat(p).SetInt64(1)
+	power := newFloat(p).Quo(newFloat(p).Sub(m, one), newFloat(p).Add(m, one))
+	square := newFloat(p).Mul(power, power)
+	sum, term := newFloat(p), newFloat(p)
+	for n := int64(1); ; n += 2 {
+		term.Quo(power, newFloat(p).SetInt64(n))
+		if negligible(term, sum, p) {
+			break
+		}
+		sum.Add(sum, term)
+		power.Mul(power, square)
+	}
+	sum.Mul(sum, newFloat(p).SetInt64(2))
+
+	shift := newFloat(p).Mul(bigLn2(p), newFloat(p).SetInt64(int64(k)))
+	return newFloat(prec).Add(sum, shift)
+}
+
+// bigExp returns e^x for |x| that fits float64 exponents. With
+// x = k·ln 2 + r and |r| < ln 2, e^x = 2^k·e^r.
+func bigExp(x *big.Float, prec uint) *big.Float {
+	p := prec + guardBits
+	ln2 := bigLn2(p)
+	k, _ := newFloat(p).Quo(x, ln2).Int64()
+	r := newFloat(p).Sub(x, newFloat(p).Mul(ln2, newFloat(p).SetInt64(k)))
+
+	sum, term := newFloat(p).SetInt64(1), newFloat(p).SetInt64(1)
+	for n := int64(1); ; n++ {
+		term.Mul(term, r)
+		term.Quo(term, newFloat(p).SetInt64(n))
+		if negligible(term, sum, p) {
+			break
+		}
+		sum.Add(sum, term)
+	}
+	return newFloat(prec).SetMantExp(sum, int(k))
+}
+
+// sinCos sums the Taylor series of sin x (first = 1) or cos x (first = 0)
+// after reducing x into (−2π, 2π). The terms stop at an absolute error of
+// 2^-prec, as the result is at most 1.
+func sinCos(x *big.Float, prec uint, first int64) *big.Float {
+	p := prec + guardBits
+	if e := x.MantExp(nil); e > 0 {
+		// the bits of the number of turns are lost in the reduction
+		p += uint(e)
+	}
+	twoPi := bigPi(p)
+	twoPi.Mul(twoPi, newFloat(p).SetInt64(2))
+	turns, _ := newFloat(p).Quo(x, twoPi).Int(nil)
+	r := newFloat(p).Sub(x, newFloat(p).Mul(twoPi, newFloat(p).SetInt(turns)))
+
+	square := newFloat(p).Mul(r, r)
+	sum, term := newFloat(p), newFloat(p).SetInt64(1)
+	if first == 1 {
+		term.Set(r)
+	}
+	for n := first; term.Sign() != 0 && term.MantExp(nil) >= -int(p); n += 2 {
+		sum.Add(sum, term)
+		term.Mul(term, square)
+		term.Quo(term, newFloat(p).SetInt64((n+1)*(n+2)))
+		term.Neg(term)
+	}
+	return newFloat(prec).Set(sum)
+}
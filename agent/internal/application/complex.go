@@ -0,0 +1,106 @@
+package application
+
+import (
+	"agent/internal/models/resp"
+	"errors"
+	"fmt"
+	"math"
+	"math/cmplx"
+)
+
+var errComplexOperand = errors.New("operand must be a real number")
+
+type complexOperation func(args []complex128) (complex128, error)
+
+var complexOps map[string]complexOperation
+
+// promotedOps continue in complex arithmetic when their real result is NaN,
+// so that sqrt(-4) is 2i.
+var promotedOps = map[string]bool{"sqrt": true, "log": true, "^": true}
+
+func init() {
+	complexOps = make(map[string]complexOperation)
+	complexOps["+"] = func(args []complex128) (complex128, error) { return args[0] + args[1], nil }
+	complexOps["-"] = func(args []complex128) (complex128, error) { return args[0] - args[1], nil }
+	complexOps["*"] = func(args []complex128) (complex128, error) { return args[0] * args[1], nil }
+	complexOps["/"] = complexDivision
+	complexOps["^"] = func(args []complex128) (complex128, error) { return cmplx.Pow(args[0], args[1]), nil }
+	complexOps["=="] = func(args []complex128) (complex128, error) { return complexBoolean(args[0] == args[1]), nil }
+	complexOps["!="] = func(args []complex128) (complex128, error) { return complexBoolean(args[0] != args[1]), nil }
+	complexOps["&&"] = func(args []complex128) (complex128, error) {
+		return complexBoolean(args[0] != 0 && args[1] != 0), nil
+	}
+	complexOps["||"] = func(args []complex128) (complex128, error) {
+		return complexBoolean(args[0] != 0 || args[1] != 0), nil
+	}
+	complexOps["neg"] = func(args []complex128) (complex128, error) { return -args[0], nil }
+	complexOps["not"] = func(args []complex128) (complex128, error) { return complexBoolean(args[0] == 0), nil }
+	complexOps["sqrt"] = complexFunction(cmplx.Sqrt)
+	complexOps["sin"] = complexFunction(cmplx.Sin)
+	complexOps["cos"] = complexFunction(cmplx.Cos)
+	complexOps["log"] = complexFunction(cmplx.Log)
+	complexOps["abs"] = func(args []complex128) (complex128, error) { return complex(cmplx.Abs(args[0]), 0), nil }
+}
+
+// isComplex reports whether any operand of task has an imaginary part.
+func isComplex(task resp.Task) bool {
+	for _, operand := range task.Operands {
+		if operand.Imag != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// executeFloat computes a float task, switching to complex arithmetic for
+// complex operands and for real operands whose result is only complex.
+// Tasks on matrices are handed to executeMatrix.
+func executeFloat(task resp.Task) (any, error) {
+	if isMatrixTask(task) {
+		return executeMatrix(task)
+	}
+	if isComplex(task) {
+		return executeComplex(task)
+	}
+
+	result, err := execute(task)
+	if err == nil && math.IsNaN(result) && promotedOps[task.Operation] {
+		return executeComplex(task)
+	}
+	return result, err
+}
+
+func executeComplex(task resp.Task) (complex128, error) {
+	op, ok := complexOps[task.Operation]
+	if !ok {
+		return 0, fmt.Errorf("%w: %q has no complex form", errComplexOperand, task.Operation)
+	}
+	if len(task.Operands) == 0 {
+		return 0, fmt.Errorf("%w: no arguments for operation %q", errInvalidOperand, task.Operation)
+	}
+
+	args := make([]complex128, len(task.Operands))
+	for i, operand := range task.Operands {
+		args[i] = complex(operand.Value, operand.Imag)
+	}
+
+	return op(args)
+}
+
+func complexDivision(args []complex128) (complex128, error) {
+	if args[1] == 0 {
+		return 0, errDivisionByZero
+	}
+	return args[0] / args[1], nil
+}
+
+func complexFunction(fn func(complex128) complex128) complexOperation {
+	return func(args []complex128) (complex128, error) { return fn(args[0]), nil }
+}
+
+func complexBoolean(b bool) complex128 {
+	if b {
+		return 1
+	}
+	return 0
+}
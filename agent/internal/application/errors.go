@@ -0,0 +1,39 @@
+package application
+
+import "errors"
+
+var (
+	errUnknownOperation = errors.New("unknown operation")
+	errInvalidOperand   = errors.New("invalid operand")
+)
+
+// codeAgentError is reported for failures without a more specific code.
+const codeAgentError = "agent_error"
+
+// errorCodes name task failures with the codes the orchestrator uses for
+// the same errors.
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{errDivisionByZero, "division_by_zero"},
+	{errNonFiniteResult, "non_finite_result"},
+	{errIrrationalOperation, "irrational_operation"},
+	{errNonIntegerOperand, "non_integer_operand"},
+	{errInvalidShift, "invalid_shift"},
+	{errComplexOperand, "complex_operand"},
+	{errShapeMismatch, "shape_mismatch"},
+	{errDimensionMismatch, "dimension_mismatch"},
+	{errUnknownOperation, "unknown_operator"},
+	{errInvalidOperand, "invalid_operand"},
+}
+
+// errorCode returns the code the failure err of a task is reported under.
+func errorCode(err error) string {
+	for _, known := range errorCodes {
+		if errors.Is(err, known.err) {
+			return known.code
+		}
+	}
+	return codeAgentError
+}
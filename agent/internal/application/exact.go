@@ -0,0 +1,209 @@
+package application
+
+import (
+	"agent/internal/models/resp"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+const (
+	defaultDigits    = 50
+	maxExactExponent = 4096
+)
+
+var (
+	errDivisionByZero      = errors.New("division by zero")
+	errNonFiniteResult     = errors.New("result is not a finite number")
+	errIrrationalOperation = errors.New("operation has no exact rational result")
+)
+
+type exactOperation func(args []*big.Rat, digits int) (*big.Rat, error)
+
+var exactOps map[string]exactOperation
+
+func init() {
+	exactOps = make(map[string]exactOperation)
+	exactOps["+"] = func(args []*big.Rat, _ int) (*big.Rat, error) { return new(big.Rat).Add(args[0], args[1]), nil }
+	exactOps["-"] = func(args []*big.Rat, _ int) (*big.Rat, error) { return new(big.Rat).Sub(args[0], args[1]), nil }
+	exactOps["*"] = func(args []*big.Rat, _ int) (*big.Rat, error) { return new(big.Rat).Mul(args[0], args[1]), nil }
+	exactOps["/"] = exactDivision
+	exactOps["^"] = exactPower
+	exactOps["%"] = exactModulo
+	exactOps["//"] = exactFloorDivision
+	exactOps["&"] = exactBitwise((*big.Int).And)
+	exactOps["|"] = exactBitwise((*big.Int).Or)
+	exactOps["xor"] = exactBitwise((*big.Int).Xor)
+	exactOps["<<"] = exactShift(true)
+	exactOps[">>"] = exactShift(false)
+	exactOps["&&"] = exactAnd
+	exactOps["||"] = exactOr
+	for op, accept := range comparisons {
+		exactOps[op] = exactComparison(accept)
+	}
+	exactOps["neg"] = func(args []*big.Rat, _ int) (*big.Rat, error) { return new(big.Rat).Neg(args[0]), nil }
+	exactOps["not"] = exactNot
+	exactOps["abs"] = func(args []*big.Rat, _ int) (*big.Rat, error) { return new(big.Rat).Abs(args[0]), nil }
+	exactOps["sqrt"] = elementary(ratSqrt)
+	exactOps["sin"] = elementary(ratSin)
+	exactOps["cos"] = elementary(ratCos)
+	exactOps["log"] = elementary(ratLog)
+	exactOps["min"] = exactExtremum(-1)
+	exactOps["max"] = exactExtremum(1)
+}
+
+func executeExact(task resp.Task) (string, error) {
+	result, err := executeRational(task)
+	if err != nil {
+		return "", err
+	}
+
+	return result.RatString(), nil
+}
+
+// executeRational computes task on fractions; the result is reduced to
+// lowest terms by big.Rat. Rational tasks fail rather than approximate an
+// irrational result.
+func executeRational(task resp.Task) (*big.Rat, error) {
+	op, ok := exactOps[task.Operation]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", errUnknownOperation, task.Operation)
+	}
+
+	args, err := parseExactArgs(task)
+	if err != nil {
+		return nil, err
+	}
+	if task.Rational && irrational(task.Operation, args) {
+		return nil, fmt.Errorf("%w %q", errIrrationalOperation, task.Operation)
+	}
+
+	digits := task.Digits
+	if digits <= 0 {
+		digits = defaultDigits
+	}
+
+	return op(args, digits)
+}
+
+// irrational reports whether operation may take rational args to an
+// irrational result: the elementary functions and powers with a non-integer
+// exponent or one too large to compute exactly.
+func irrational(operation string, args []*big.Rat) bool {
+	switch operation {
+	case "sqrt", "sin", "cos", "log":
+		return true
+	case "^":
+		exponent := args[1]
+		return !exponent.IsInt() || exponent.Num().CmpAbs(big.NewInt(maxExactExponent)) > 0
+	}
+	return false
+}
+
+func parseExactArgs(task resp.Task) ([]*big.Rat, error) {
+	if len(task.Operands) > 0 {
+		args := make([]*big.Rat, len(task.Operands))
+		for i, operand := range task.Operands {
+			if operand.Numerator != "" {
+				fraction, err := parseFraction(operand)
+				if err != nil {
+					return nil, err
+				}
+				args[i] = fraction
+				continue
+			}
+			if operand.Exact == "" {
+				args[i] = new(big.Rat).SetFloat64(operand.Value)
+				if args[i] == nil {
+					return nil, errNonFiniteResult
+				}
+				continue
+			}
+
+			value, ok := new(big.Rat).SetString(operand.Exact)
+			if !ok {
+				return nil, fmt.Errorf("%w %q", errInvalidOperand, operand.Exact)
+			}
+			args[i] = value
+		}
+		return args, nil
+	}
+
+	raw := legacyArgs(task)
+	args := make([]*big.Rat, len(raw))
+	for i, arg := range raw {
+		value, ok := new(big.Rat).SetString(arg)
+		if !ok {
+			return nil, fmt.Errorf("%w %q", errInvalidOperand, arg)
+		}
+		args[i] = value
+	}
+	return args, nil
+}
+
+func parseFraction(operand resp.Operand) (*big.Rat, error) {
+	numerator, ok := new(big.Int).SetString(operand.Numerator, 10)
+	if !ok {
+		return nil, fmt.Errorf("%w: numerator %q", errInvalidOperand, operand.Numerator)
+	}
+	denominator, ok := new(big.Int).SetString(operand.Denominator, 10)
+	if !ok || denominator.Sign() == 0 {
+		return nil, fmt.Errorf("%w: denominator %q", errInvalidOperand, operand.Denominator)
+	}
+	return new(big.Rat).SetFrac(numerator, denominator), nil
+}
+
+func exactDivision(args []*big.Rat, _ int) (*big.Rat, error) {
+	if args[1].Sign() == 0 {
+		return nil, errDivisionByZero
+	}
+	return new(big.Rat).Quo(args[0], args[1]), nil
+}
+
+func exactPower(args []*big.Rat, digits int) (*big.Rat, error) {
+	base, exponent := args[0], args[1]
+
+	if exponent.IsInt() && exponent.Num().IsInt64() {
+		e := exponent.Num().Int64()
+		if e >= -maxExactExponent && e <= maxExactExponent {
+			if e < 0 {
+				if base.Sign() == 0 {
+					return nil, errDivisionByZero
+				}
+				base, e = new(big.Rat).Inv(base), -e
+			}
+			num := new(big.Int).Exp(base.Num(), big.NewInt(e), nil)
+			den := new(big.Int).Exp(base.Denom(), big.NewInt(e), nil)
+			return new(big.Rat).SetFrac(num, den), nil
+		}
+	}
+
+	result, ok := ratPower(base, exponent, digits)
+	if !ok {
+		return nil, errNonFiniteResult
+	}
+	return result, nil
+}
+
+func exactExtremum(sign int) exactOperation {
+	return func(args []*big.Rat, _ int) (*big.Rat, error) {
+		result := args[0]
+		for _, arg := range args[1:] {
+			if arg.Cmp(result) == sign {
+				result = arg
+			}
+		}
+		return new(big.Rat).Set(result), nil
+	}
+}
+
+// elementary lifts a function of bigmath.go into exact mode.
+func elementary(fn func(x *big.Rat, digits int) (*big.Rat, bool)) exactOperation {
+	return func(args []*big.Rat, digits int) (*big.Rat, error) {
+		result, ok := fn(args[0], digits)
+		if !ok {
+			return nil, errNonFiniteResult
+		}
+		return result, nil
+	}
+}
@@ -0,0 +1,110 @@
+package application
+
+import (
+	"errors"
+	"math"
+	"math/big"
+)
+
+const maxExactShift = 1 << 16
+
+var (
+	errNonIntegerOperand = errors.New("operand must be an integer")
+	errInvalidShift      = errors.New("shift count must be a non-negative integer")
+)
+
+func modulo(a, b float64) (float64, error) {
+	if b == 0 {
+		return 0, errDivisionByZero
+	}
+	result := math.Mod(a, b)
+	if result != 0 && (result < 0) != (b < 0) {
+		result += b
+	}
+	return result, nil
+}
+
+func floorDivision(a, b float64) (float64, error) {
+	if b == 0 {
+		return 0, errDivisionByZero
+	}
+	return math.Floor(a / b), nil
+}
+
+func bitwise(operation func(x, y int64) int64) func(float64, float64) (float64, error) {
+	return func(a, b float64) (float64, error) {
+		if !isInteger(a) || !isInteger(b) {
+			return 0, errNonIntegerOperand
+		}
+		return float64(operation(int64(a), int64(b))), nil
+	}
+}
+
+func shift(left bool) func(float64, float64) (float64, error) {
+	return func(a, b float64) (float64, error) {
+		if !isInteger(a) {
+			return 0, errNonIntegerOperand
+		}
+		if !isInteger(b) || b < 0 {
+			return 0, errInvalidShift
+		}
+
+		count := int(math.Min(b, 2*maxExactShift))
+		if left {
+			return math.Ldexp(a, count), nil
+		}
+		return math.Floor(math.Ldexp(a, -count)), nil
+	}
+}
+
+func isInteger(x float64) bool {
+	return x == math.Trunc(x) && math.Abs(x) < 1<<63
+}
+
+func exactFloor(r *big.Rat) *big.Rat {
+	return new(big.Rat).SetInt(new(big.Int).Div(r.Num(), r.Denom()))
+}
+
+func exactFloorDivision(args []*big.Rat, _ int) (*big.Rat, error) {
+	if args[1].Sign() == 0 {
+		return nil, errDivisionByZero
+	}
+	return exactFloor(new(big.Rat).Quo(args[0], args[1])), nil
+}
+
+func exactModulo(args []*big.Rat, _ int) (*big.Rat, error) {
+	if args[1].Sign() == 0 {
+		return nil, errDivisionByZero
+	}
+	quotient := exactFloor(new(big.Rat).Quo(args[0], args[1]))
+	return new(big.Rat).Sub(args[0], quotient.Mul(quotient, args[1])), nil
+}
+
+func exactBitwise(operation func(z, x, y *big.Int) *big.Int) exactOperation {
+	return func(args []*big.Rat, _ int) (*big.Rat, error) {
+		if !args[0].IsInt() || !args[1].IsInt() {
+			return nil, errNonIntegerOperand
+		}
+		return new(big.Rat).SetInt(operation(new(big.Int), args[0].Num(), args[1].Num())), nil
+	}
+}
+
+func exactShift(left bool) exactOperation {
+	return func(args []*big.Rat, _ int) (*big.Rat, error) {
+		if !args[0].IsInt() {
+			return nil, errNonIntegerOperand
+		}
+		count := args[1]
+		if !count.IsInt() || count.Sign() < 0 || count.Num().Cmp(big.NewInt(maxExactShift)) > 0 {
+			return nil, errInvalidShift
+		}
+
+		result := new(big.Int)
+		if left {
+			result.Lsh(args[0].Num(), uint(count.Num().Uint64()))
+		} else {
+			result.Rsh(args[0].Num(), uint(count.Num().Uint64()))
+		}
+		return new(big.Rat).SetInt(result), nil
+	}
+}
@@ -0,0 +1,55 @@
+package application
+
+import (
+	"cmp"
+	"math/big"
+)
+
+var comparisons = map[string]func(c int) bool{
+	"==": func(c int) bool { return c == 0 },
+	"!=": func(c int) bool { return c != 0 },
+	"<":  func(c int) bool { return c < 0 },
+	"<=": func(c int) bool { return c <= 0 },
+	">":  func(c int) bool { return c > 0 },
+	">=": func(c int) bool { return c >= 0 },
+}
+
+// Truth values are carried as 1 and 0 like any other number, and any
+// non-zero operand counts as true.
+
+func boolean(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func comparison(accept func(c int) bool) func(float64, float64) float64 {
+	return func(a, b float64) float64 { return boolean(accept(cmp.Compare(a, b))) }
+}
+
+func and(a, b float64) float64 { return boolean(a != 0 && b != 0) }
+func or(a, b float64) float64  { return boolean(a != 0 || b != 0) }
+func not(a float64) float64    { return boolean(a == 0) }
+
+func exactBoolean(b bool) *big.Rat {
+	return new(big.Rat).SetFloat64(boolean(b))
+}
+
+func exactComparison(accept func(c int) bool) exactOperation {
+	return func(args []*big.Rat, _ int) (*big.Rat, error) {
+		return exactBoolean(accept(args[0].Cmp(args[1]))), nil
+	}
+}
+
+func exactAnd(args []*big.Rat, _ int) (*big.Rat, error) {
+	return exactBoolean(args[0].Sign() != 0 && args[1].Sign() != 0), nil
+}
+
+func exactOr(args []*big.Rat, _ int) (*big.Rat, error) {
+	return exactBoolean(args[0].Sign() != 0 || args[1].Sign() != 0), nil
+}
+
+func exactNot(args []*big.Rat, _ int) (*big.Rat, error) {
+	return exactBoolean(args[0].Sign() == 0), nil
+}
@@ -0,0 +1,183 @@
+package application
+
+import (
+	"agent/internal/models/resp"
+	"errors"
+	"fmt"
+	"math"
+)
+
+var errShapeMismatch = errors.New("matrix shapes do not match")
+
+// isMatrixTask reports whether task works on arrays: any operand is a matrix
+// or the operation is one of dot, transpose and det.
+func isMatrixTask(task resp.Task) bool {
+	switch task.Operation {
+	case "dot", "transpose", "det":
+		return true
+	}
+	for _, operand := range task.Operands {
+		if operand.Matrix != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// executeMatrix computes a task on matrices. The result is a *resp.Matrix,
+// or a float64 for dot and det.
+func executeMatrix(task resp.Task) (any, error) {
+	args := task.Operands
+	arity := 1
+	switch task.Operation {
+	case "+", "-", "*", "dot":
+		arity = 2
+	}
+	if len(args) != arity {
+		return nil, fmt.Errorf("%w: operation %q expects %d argument(s), got %d", errInvalidOperand, task.Operation, arity, len(args))
+	}
+
+	switch task.Operation {
+	case "neg":
+		return scaleMatrix(args[0].Matrix, -1)
+	case "transpose":
+		return transposeMatrix(args[0].Matrix)
+	case "det":
+		return determinant(args[0].Matrix)
+	case "+":
+		return elementwise(args[0].Matrix, args[1].Matrix, func(x, y float64) float64 { return x + y })
+	case "-":
+		return elementwise(args[0].Matrix, args[1].Matrix, func(x, y float64) float64 { return x - y })
+	case "dot":
+		return dotProduct(args[0].Matrix, args[1].Matrix)
+	case "*":
+		a, b := args[0].Matrix, args[1].Matrix
+		switch {
+		case a == nil:
+			return scaleMatrix(b, args[0].Value)
+		case b == nil:
+			return scaleMatrix(a, args[1].Value)
+		}
+		return multiplyMatrices(a, b)
+	}
+
+	return nil, fmt.Errorf("%w %q", errUnknownOperation, task.Operation)
+}
+
+func scaleMatrix(m *resp.Matrix, factor float64) (*resp.Matrix, error) {
+	if m == nil {
+		return nil, fmt.Errorf("%w: expected a matrix", errShapeMismatch)
+	}
+	result := &resp.Matrix{Rows: m.Rows, Cols: m.Cols, Values: make([]float64, len(m.Values)), Vector: m.Vector}
+	for i, v := range m.Values {
+		result.Values[i] = v * factor
+	}
+	return result, nil
+}
+
+func elementwise(a, b *resp.Matrix, fn func(x, y float64) float64) (*resp.Matrix, error) {
+	if a == nil || b == nil || a.Rows != b.Rows || a.Cols != b.Cols || a.Vector != b.Vector {
+		return nil, errShapeMismatch
+	}
+	result := &resp.Matrix{Rows: a.Rows, Cols: a.Cols, Values: make([]float64, len(a.Values)), Vector: a.Vector}
+	for i := range a.Values {
+		result.Values[i] = fn(a.Values[i], b.Values[i])
+	}
+	return result, nil
+}
+
+// multiplyMatrices computes a*b. A vector on the right is a column and one
+// on the left a row; the product with a vector is a vector again.
+func multiplyMatrices(a, b *resp.Matrix) (*resp.Matrix, error) {
+	left, right := a, b
+	if b.Vector {
+		right = &resp.Matrix{Rows: b.Cols, Cols: 1, Values: b.Values}
+	}
+	if a.Vector && b.Vector || left.Cols != right.Rows {
+		return nil, errShapeMismatch
+	}
+
+	result := &resp.Matrix{Rows: left.Rows, Cols: right.Cols, Values: make([]float64, left.Rows*right.Cols)}
+	for i := 0; i < left.Rows; i++ {
+		for j := 0; j < right.Cols; j++ {
+			var sum float64
+			for k := 0; k < left.Cols; k++ {
+				sum += left.Values[i*left.Cols+k] * right.Values[k*right.Cols+j]
+			}
+			result.Values[i*right.Cols+j] = sum
+		}
+	}
+
+	if a.Vector || b.Vector {
+		result.Rows, result.Cols, result.Vector = 1, len(result.Values), true
+	}
+	return result, nil
+}
+
+func transposeMatrix(m *resp.Matrix) (*resp.Matrix, error) {
+	if m == nil {
+		return nil, fmt.Errorf("%w: transpose expects a matrix", errShapeMismatch)
+	}
+	if m.Vector {
+		return m, nil
+	}
+
+	result := &resp.Matrix{Rows: m.Cols, Cols: m.Rows, Values: make([]float64, len(m.Values))}
+	for i := 0; i < m.Rows; i++ {
+		for j := 0; j < m.Cols; j++ {
+			result.Values[j*m.Rows+i] = m.Values[i*m.Cols+j]
+		}
+	}
+	return result, nil
+}
+
+func dotProduct(a, b *resp.Matrix) (float64, error) {
+	if a == nil || b == nil || !a.Vector || !b.Vector || a.Cols != b.Cols {
+		return 0, fmt.Errorf("%w: dot expects two vectors of the same length", errShapeMismatch)
+	}
+
+	var sum float64
+	for i := range a.Values {
+		sum += a.Values[i] * b.Values[i]
+	}
+	return sum, nil
+}
+
+// determinant uses Gaussian elimination with partial pivoting.
+func determinant(m *resp.Matrix) (float64, error) {
+	if m == nil || m.Vector || m.Rows != m.Cols {
+		return 0, fmt.Errorf("%w: det expects a square matrix", errShapeMismatch)
+	}
+
+	n := m.Rows
+	a := make([]float64, len(m.Values))
+	copy(a, m.Values)
+
+	det := 1.0
+	for col := 0; col < n; col++ {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(a[row*n+col]) > math.Abs(a[pivot*n+col]) {
+				pivot = row
+			}
+		}
+		if a[pivot*n+col] == 0 {
+			return 0, nil
+		}
+		if pivot != col {
+			for k := 0; k < n; k++ {
+				a[col*n+k], a[pivot*n+k] = a[pivot*n+k], a[col*n+k]
+			}
+			det = -det
+		}
+
+		det *= a[col*n+col]
+		for row := col + 1; row < n; row++ {
+			factor := a[row*n+col] / a[col*n+col]
+			for k := col; k < n; k++ {
+				a[row*n+k] -= factor * a[col*n+k]
+			}
+		}
+	}
+	return det, nil
+}
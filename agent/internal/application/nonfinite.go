@@ -0,0 +1,48 @@
+package application
+
+import (
+	"agent/internal/models/resp"
+	"fmt"
+	"math"
+)
+
+// Policies for infinite and NaN results, sent by the orchestrator with every
+// task. Tasks without one use nonFiniteError.
+const (
+	nonFiniteError    = "error"
+	nonFiniteSaturate = "saturate"
+	nonFiniteAllow    = "allow"
+)
+
+// applyNonFinite returns the result of a float task as policy lets it be
+// sent, or errNonFiniteResult. Saturation turns ±Inf into ±math.MaxFloat64;
+// NaN is rejected unless policy allows it.
+func applyNonFinite(policy string, result any) (any, error) {
+	if policy == nonFiniteAllow {
+		return result, nil
+	}
+
+	var err error
+	check := func(x float64) float64 {
+		switch {
+		case !math.IsInf(x, 0) && !math.IsNaN(x):
+		case policy == nonFiniteSaturate && math.IsInf(x, 0):
+			return math.Copysign(math.MaxFloat64, x)
+		case err == nil:
+			err = fmt.Errorf("%w: %v", errNonFiniteResult, x)
+		}
+		return x
+	}
+
+	switch v := result.(type) {
+	case float64:
+		result = check(v)
+	case complex128:
+		result = complex(check(real(v)), check(imag(v)))
+	case *resp.Matrix:
+		for i, x := range v.Values {
+			v.Values[i] = check(x)
+		}
+	}
+	return result, err
+}
@@ -0,0 +1,183 @@
+package application
+
+import (
+	"agent/internal/models/resp"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var errDimensionMismatch = errors.New("incompatible units")
+
+// baseUnits are the SI base units operand units are written in, in the
+// order the orchestrator prints them.
+var baseUnits = []string{"m", "kg", "s", "A", "K", "mol", "cd"}
+
+type dimension map[string]int
+
+// parseDimension reads an SI unit such as m^2*kg/s^2 or s^-1. The empty
+// unit is dimensionless.
+func parseDimension(unit string) (dimension, error) {
+	dim := dimension{}
+	if unit == "" {
+		return dim, nil
+	}
+
+	sign := 1
+	for rest := unit; ; {
+		end := strings.IndexAny(rest, "*/")
+		if end < 0 {
+			end = len(rest)
+		}
+		name, exponent, found := strings.Cut(rest[:end], "^")
+		n := 1
+		if found {
+			var err error
+			if n, err = strconv.Atoi(exponent); err != nil {
+				return nil, fmt.Errorf("%w: unit %q", errInvalidOperand, unit)
+			}
+		}
+		if !isBaseUnit(name) {
+			return nil, fmt.Errorf("%w: unit %q, use SI base units", errInvalidOperand, unit)
+		}
+		dim[name] += sign * n
+
+		if end == len(rest) {
+			return dim, nil
+		}
+		sign = 1
+		if rest[end] == '/' {
+			sign = -1
+		}
+		rest = rest[end+1:]
+	}
+}
+
+func isBaseUnit(name string) bool {
+	for _, base := range baseUnits {
+		if name == base {
+			return true
+		}
+	}
+	return false
+}
+
+func (d dimension) String() string {
+	var numerator, denominator []string
+	for _, name := range baseUnits {
+		switch n := d[name]; {
+		case n > 0:
+			numerator = append(numerator, unitPower(name, n))
+		case n < 0:
+			denominator = append(denominator, unitPower(name, -n))
+		}
+	}
+
+	if len(numerator) == 0 {
+		for i, name := range denominator {
+			base, exponent, found := strings.Cut(name, "^")
+			if !found {
+				exponent = "1"
+			}
+			denominator[i] = base + "^-" + exponent
+		}
+		return strings.Join(denominator, "*")
+	}
+
+	result := strings.Join(numerator, "*")
+	for _, name := range denominator {
+		result += "/" + name
+	}
+	return result
+}
+
+func unitPower(name string, n int) string {
+	if n == 1 {
+		return name
+	}
+	return name + "^" + strconv.Itoa(n)
+}
+
+func (d dimension) isZero() bool {
+	for _, n := range d {
+		if n != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (d dimension) equal(other dimension) bool {
+	return d.String() == other.String()
+}
+
+// combine returns d times other raised to sign.
+func (d dimension) combine(other dimension, sign int) dimension {
+	result := dimension{}
+	for name, n := range d {
+		result[name] += n
+	}
+	for name, n := range other {
+		result[name] += sign * n
+	}
+	return result
+}
+
+// resultUnit returns the SI unit of the result of a task on quantities,
+// whose operands are given in SI base units, or "" for plain numbers.
+func resultUnit(task resp.Task) (string, error) {
+	dims := make([]dimension, len(task.Operands))
+	plain := true
+	for i, operand := range task.Operands {
+		dim, err := parseDimension(operand.Unit)
+		if err != nil {
+			return "", err
+		}
+		dims[i] = dim
+		plain = plain && dim.isZero()
+	}
+	if plain {
+		return "", nil
+	}
+
+	sameDimension := func() error {
+		for _, dim := range dims[1:] {
+			if !dim.equal(dims[0]) {
+				return fmt.Errorf("%w: %s and %s", errDimensionMismatch, dims[0], dim)
+			}
+		}
+		return nil
+	}
+
+	switch op := task.Operation; {
+	case op == "+" || op == "-" || op == "%" || op == "min" || op == "max":
+		return dims[0].String(), sameDimension()
+	case comparisons[op] != nil || op == "//":
+		return "", sameDimension()
+	case op == "neg" || op == "abs":
+		return dims[0].String(), nil
+	case op == "*" && len(dims) == 2:
+		return dims[0].combine(dims[1], 1).String(), nil
+	case op == "/" && len(dims) == 2:
+		return dims[0].combine(dims[1], -1).String(), nil
+	case op == "^" && len(dims) == 2:
+		exponent := task.Operands[1].Value
+		if !dims[1].isZero() || exponent != math.Trunc(exponent) {
+			return "", fmt.Errorf("%w: raise a quantity to an integer power", errDimensionMismatch)
+		}
+		return dimension{}.combine(dims[0], int(exponent)).String(), nil
+	case op == "sqrt":
+		root := dimension{}
+		for name, n := range dims[0] {
+			if n%2 != 0 {
+				return "", fmt.Errorf("%w: %s has no square root", errDimensionMismatch, dims[0])
+			}
+			root[name] = n / 2
+		}
+		return root.String(), nil
+	}
+
+	return "", fmt.Errorf("%w: %s expects plain numbers", errDimensionMismatch, task.Operation)
+}
@@ -5,6 +5,7 @@ import (
 	"agent/internal/models/resp"
 	"context"
 	"fmt"
+	"math/big"
 	"time"
 
 	pb "agent/pkg/api/v1"
@@ -21,11 +22,10 @@ type GRPCClient struct {
 	logger *zap.Logger
 }
 
-func NewGRPCClient(host string, port string, logger *zap.Logger) (*GRPCClient, error) {
-	conn, err := grpc.NewClient(
-		host+":"+port,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-	)
+func NewGRPCClient(host string, port string, logger *zap.Logger, opts ...grpc.DialOption) (*GRPCClient, error) {
+	opts = append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, opts...)
+
+	conn, err := grpc.NewClient(host+":"+port, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create gRPC client: %w", err)
 	}
@@ -56,10 +56,36 @@ func (c *GRPCClient) GetTask() *resp.Task {
 		opTime = response.OperationTime.AsDuration()
 	}
 
+	operands := make([]resp.Operand, len(response.Operands))
+	for i, operand := range response.Operands {
+		operands[i] = resp.Operand{
+			Value:       operand.GetValue(),
+			Exact:       operand.GetExact(),
+			Numerator:   operand.GetFraction().GetNumerator(),
+			Denominator: operand.GetFraction().GetDenominator(),
+			Imag:        operand.GetImag(),
+			Unit:        operand.GetUnit(),
+		}
+		if m := operand.GetMatrix(); m != nil {
+			operands[i].Matrix = &resp.Matrix{
+				Rows:   int(m.GetRows()),
+				Cols:   int(m.GetCols()),
+				Values: m.GetValues(),
+				Vector: m.GetVector(),
+			}
+		}
+	}
+
 	return &resp.Task{
 		ID:            int(response.Id),
 		Arg1:          response.Arg1,
 		Arg2:          response.Arg2,
+		Args:          response.Args,
+		Operands:      operands,
+		Exact:         response.Exact,
+		Digits:        int(response.Digits),
+		Rational:      response.Rational,
+		NonFinite:     response.NonFinite,
 		Operation:     response.Operation,
 		OperationTime: opTime,
 		UserID:        response.UserId,
@@ -73,6 +99,7 @@ func (c *GRPCClient) SendResult(result req.Result, userID uint64) {
 	grpcResult := &pb.Result{
 		Id:     int32(result.ID),
 		UserId: userID,
+		Unit:   result.Unit,
 	}
 
 	switch v := result.Value.(type) {
@@ -80,11 +107,31 @@ func (c *GRPCClient) SendResult(result req.Result, userID uint64) {
 		grpcResult.Value = &pb.Result_IntResult{IntResult: int64(v)}
 	case float64:
 		grpcResult.Value = &pb.Result_FloatResult{FloatResult: v}
+	case string:
+		grpcResult.Value = &pb.Result_ExactResult{ExactResult: v}
+	case complex128:
+		grpcResult.Value = &pb.Result_ComplexResult{ComplexResult: &pb.Complex{Real: real(v), Imag: imag(v)}}
+	case *big.Rat:
+		grpcResult.Value = &pb.Result_RationalResult{RationalResult: &pb.Fraction{
+			Numerator:   v.Num().String(),
+			Denominator: v.Denom().String(),
+		}}
+	case *resp.Matrix:
+		grpcResult.Value = &pb.Result_MatrixResult{MatrixResult: &pb.Matrix{
+			Rows:   int32(v.Rows),
+			Cols:   int32(v.Cols),
+			Values: v.Values,
+			Vector: v.Vector,
+		}}
 	case error:
 		grpcResult.Value = &pb.Result_Error{Error: v.Error()}
+		grpcResult.ErrorCode = result.ErrorCode
 	default:
+		// report the task as failed so that its expression does not wait
+		// for a result that never comes
 		c.logger.Error("unsupported result type", zap.Any("type", v))
-		return
+		grpcResult.Value = &pb.Result_Error{Error: fmt.Sprintf("unsupported result type %T", v)}
+		grpcResult.ErrorCode = "invalid_result"
 	}
 
 	_, err := c.client.SendResult(ctx, grpcResult)
@@ -2,7 +2,11 @@ package client_test
 
 import (
 	"agent/internal/models/req"
+	"agent/internal/models/resp"
 	"context"
+	"errors"
+	"math"
+	"math/big"
 	"net"
 	"testing"
 
@@ -160,3 +164,191 @@ func TestGRPCClient_Close(t *testing.T) {
 	assert.NoError(t, grpcClient.Close())
 	assert.Error(t, grpcClient.Close(), "second close should return error")
 }
+
+func newBufconnClient(t *testing.T, server *mockOrchestratorServer) *client.GRPCClient {
+	s, lis := startMockServer(t)
+	pb.RegisterOrchestratorServiceServer(s, server)
+
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Errorf("Server exited with error: %v", err)
+		}
+	}()
+	t.Cleanup(s.Stop)
+
+	grpcClient, err := client.NewGRPCClient("passthrough:///bufnet", "", zap.NewNop(),
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return lis.Dial()
+		}),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { grpcClient.Close() })
+
+	return grpcClient
+}
+
+var roundTripValues = []struct {
+	name  string
+	value float64
+}{
+	{"tiny", 1e-9},
+	{"smallest subnormal", math.SmallestNonzeroFloat64},
+	{"huge", 1e300},
+	{"max float", math.MaxFloat64},
+	{"negative", -123.456789012345678},
+	{"negative zero", math.Copysign(0, -1)},
+	{"positive infinity", math.Inf(1)},
+	{"negative infinity", math.Inf(-1)},
+	{"not a number", math.NaN()},
+}
+
+func TestGRPCClient_GetTaskOperandsRoundTrip(t *testing.T) {
+	for _, tt := range roundTripValues {
+		t.Run(tt.name, func(t *testing.T) {
+			grpcClient := newBufconnClient(t, &mockOrchestratorServer{
+				getTaskHandler: func(context.Context, *emptypb.Empty) (*pb.Task, error) {
+					return &pb.Task{
+						Id:        7,
+						Operation: "+",
+						Operands: []*pb.Number{
+							{Value: tt.value},
+							{Value: 1.0 / 3, Exact: "1/3"},
+						},
+					}, nil
+				},
+			})
+
+			task := grpcClient.GetTask()
+			require.NotNil(t, task)
+			require.Len(t, task.Operands, 2)
+
+			assert.Equal(t, math.Float64bits(tt.value), math.Float64bits(task.Operands[0].Value))
+			assert.Empty(t, task.Operands[0].Exact)
+			assert.Equal(t, resp.Operand{Value: 1.0 / 3, Exact: "1/3"}, task.Operands[1])
+		})
+	}
+}
+
+func TestGRPCClient_SendResultRoundTrip(t *testing.T) {
+	for _, tt := range roundTripValues {
+		t.Run(tt.name, func(t *testing.T) {
+			received := make(chan *pb.Result, 1)
+			grpcClient := newBufconnClient(t, &mockOrchestratorServer{
+				sendResultHandler: func(_ context.Context, res *pb.Result) (*emptypb.Empty, error) {
+					received <- res
+					return &emptypb.Empty{}, nil
+				},
+			})
+
+			grpcClient.SendResult(req.Result{ID: 3, Value: tt.value}, 42)
+
+			res := <-received
+			assert.Equal(t, int32(3), res.Id)
+			assert.Equal(t, uint64(42), res.UserId)
+			require.IsType(t, &pb.Result_FloatResult{}, res.Value)
+			assert.Equal(t, math.Float64bits(tt.value), math.Float64bits(res.GetFloatResult()))
+		})
+	}
+}
+
+func TestGRPCClient_SendResultKinds(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		code  string
+		check func(t *testing.T, res *pb.Result)
+	}{
+		{
+			name:  "exact result",
+			value: "12345678901234567890123/1000",
+			check: func(t *testing.T, res *pb.Result) {
+				assert.Equal(t, "12345678901234567890123/1000", res.GetExactResult())
+			},
+		},
+		{
+			name:  "rational result",
+			value: big.NewRat(-6, 4),
+			check: func(t *testing.T, res *pb.Result) {
+				assert.Equal(t, "-3", res.GetRationalResult().GetNumerator())
+				assert.Equal(t, "2", res.GetRationalResult().GetDenominator())
+			},
+		},
+		{
+			name:  "complex result",
+			value: complex(11, -2),
+			check: func(t *testing.T, res *pb.Result) {
+				assert.Equal(t, 11.0, res.GetComplexResult().GetReal())
+				assert.Equal(t, -2.0, res.GetComplexResult().GetImag())
+			},
+		},
+		{
+			name:  "matrix result",
+			value: &resp.Matrix{Rows: 1, Cols: 2, Values: []float64{3, 7}, Vector: true},
+			check: func(t *testing.T, res *pb.Result) {
+				assert.Equal(t, int32(2), res.GetMatrixResult().GetCols())
+				assert.Equal(t, []float64{3, 7}, res.GetMatrixResult().GetValues())
+				assert.True(t, res.GetMatrixResult().GetVector())
+			},
+		},
+		{
+			name:  "error result",
+			value: errors.New("division by zero"),
+			code:  "division_by_zero",
+			check: func(t *testing.T, res *pb.Result) {
+				assert.Equal(t, "division by zero", res.GetError())
+				assert.Equal(t, "division_by_zero", res.GetErrorCode())
+			},
+		},
+		{
+			name:  "unsupported result",
+			value: struct{}{},
+			check: func(t *testing.T, res *pb.Result) {
+				assert.Equal(t, "unsupported result type struct {}", res.GetError())
+				assert.Equal(t, "invalid_result", res.GetErrorCode())
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received := make(chan *pb.Result, 1)
+			grpcClient := newBufconnClient(t, &mockOrchestratorServer{
+				sendResultHandler: func(_ context.Context, res *pb.Result) (*emptypb.Empty, error) {
+					received <- res
+					return &emptypb.Empty{}, nil
+				},
+			})
+
+			grpcClient.SendResult(req.Result{ID: 1, Value: tt.value, ErrorCode: tt.code}, 1)
+
+			tt.check(t, <-received)
+		})
+	}
+}
+
+func TestGRPCClient_QuantityUnits(t *testing.T) {
+	received := make(chan *pb.Result, 1)
+	grpcClient := newBufconnClient(t, &mockOrchestratorServer{
+		getTaskHandler: func(context.Context, *emptypb.Empty) (*pb.Task, error) {
+			return &pb.Task{
+				Id:        5,
+				Operation: "/",
+				Operands:  []*pb.Number{{Value: 5000, Unit: "m"}, {Value: 1800, Unit: "s"}},
+			}, nil
+		},
+		sendResultHandler: func(_ context.Context, res *pb.Result) (*emptypb.Empty, error) {
+			received <- res
+			return &emptypb.Empty{}, nil
+		},
+	})
+
+	task := grpcClient.GetTask()
+	require.NotNil(t, task)
+	assert.Equal(t, "m", task.Operands[0].Unit)
+	assert.Equal(t, "s", task.Operands[1].Unit)
+
+	grpcClient.SendResult(req.Result{ID: 5, Value: 5000.0 / 1800, Unit: "m/s"}, 1)
+
+	res := <-received
+	assert.Equal(t, "m/s", res.GetUnit())
+}
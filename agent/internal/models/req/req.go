@@ -1,9 +1,11 @@
 package req
 
 type Result struct {
-	ID     int    `json:"id"`
-	Value  any    `json:"result"`
-	UserID uint64 `json:"user_id"`
+	ID        int    `json:"id"`
+	Value     any    `json:"result"`
+	Unit      string `json:"unit,omitempty"`       // SI unit of a quantity result
+	ErrorCode string `json:"error_code,omitempty"` // set when Value is an error
+	UserID    uint64 `json:"user_id"`
 }
 
 type ExpressionRequest struct {
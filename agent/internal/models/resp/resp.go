@@ -17,11 +17,40 @@ type Task struct {
 	ID            int           `json:"id"`
 	Arg1          string        `json:"arg1"`
 	Arg2          string        `json:"arg2"`
+	Args          []string      `json:"args,omitempty"`
+	Operands      []Operand     `json:"-"`
+	Exact         bool          `json:"exact,omitempty"`
+	Digits        int           `json:"digits,omitempty"`
+	Rational      bool          `json:"rational,omitempty"`
+	NonFinite     string        `json:"non_finite,omitempty"`
 	Operation     string        `json:"operation"`
 	OperationTime time.Duration `json:"operation_time"`
 	UserID        uint64        `json:"user_id"`
 }
 
+type Operand struct {
+	Value float64
+	Exact string
+	// Numerator and Denominator are set for the operands of rational tasks.
+	Numerator   string
+	Denominator string
+	// Imag is the imaginary part of a complex operand, Value its real part.
+	Imag float64
+	// Matrix is set for array operands.
+	Matrix *Matrix
+	// Unit is the SI unit of a quantity, whose Value is given in that unit.
+	Unit string
+}
+
+// Matrix holds its values row by row. A vector is a single row with Vector
+// set; it acts as a column on the right of a matrix product.
+type Matrix struct {
+	Rows   int
+	Cols   int
+	Values []float64
+	Vector bool
+}
+
 type Expression struct {
 	*list.List
 	ID         int    `json:"id"`
@@ -23,6 +23,267 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// Fraction is a rational number in lowest terms with a positive denominator.
+type Fraction struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Numerator     string                 `protobuf:"bytes,1,opt,name=numerator,proto3" json:"numerator,omitempty"`
+	Denominator   string                 `protobuf:"bytes,2,opt,name=denominator,proto3" json:"denominator,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Fraction) Reset() {
+	*x = Fraction{}
+	mi := &file_service_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Fraction) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Fraction) ProtoMessage() {}
+
+func (x *Fraction) ProtoReflect() protoreflect.Message {
+	mi := &file_service_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Fraction.ProtoReflect.Descriptor instead.
+func (*Fraction) Descriptor() ([]byte, []int) {
+	return file_service_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Fraction) GetNumerator() string {
+	if x != nil {
+		return x.Numerator
+	}
+	return ""
+}
+
+func (x *Fraction) GetDenominator() string {
+	if x != nil {
+		return x.Denominator
+	}
+	return ""
+}
+
+// Complex is a result with an imaginary part.
+type Complex struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Real          float64                `protobuf:"fixed64,1,opt,name=real,proto3" json:"real,omitempty"`
+	Imag          float64                `protobuf:"fixed64,2,opt,name=imag,proto3" json:"imag,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Complex) Reset() {
+	*x = Complex{}
+	mi := &file_service_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Complex) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Complex) ProtoMessage() {}
+
+func (x *Complex) ProtoReflect() protoreflect.Message {
+	mi := &file_service_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Complex.ProtoReflect.Descriptor instead.
+func (*Complex) Descriptor() ([]byte, []int) {
+	return file_service_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Complex) GetReal() float64 {
+	if x != nil {
+		return x.Real
+	}
+	return 0
+}
+
+func (x *Complex) GetImag() float64 {
+	if x != nil {
+		return x.Imag
+	}
+	return 0
+}
+
+// Matrix is a row-major array of values; a vector is a single row.
+type Matrix struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Rows          int32                  `protobuf:"varint,1,opt,name=rows,proto3" json:"rows,omitempty"`
+	Cols          int32                  `protobuf:"varint,2,opt,name=cols,proto3" json:"cols,omitempty"`
+	Values        []float64              `protobuf:"fixed64,3,rep,packed,name=values,proto3" json:"values,omitempty"`
+	Vector        bool                   `protobuf:"varint,4,opt,name=vector,proto3" json:"vector,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Matrix) Reset() {
+	*x = Matrix{}
+	mi := &file_service_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Matrix) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Matrix) ProtoMessage() {}
+
+func (x *Matrix) ProtoReflect() protoreflect.Message {
+	mi := &file_service_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Matrix.ProtoReflect.Descriptor instead.
+func (*Matrix) Descriptor() ([]byte, []int) {
+	return file_service_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Matrix) GetRows() int32 {
+	if x != nil {
+		return x.Rows
+	}
+	return 0
+}
+
+func (x *Matrix) GetCols() int32 {
+	if x != nil {
+		return x.Cols
+	}
+	return 0
+}
+
+func (x *Matrix) GetValues() []float64 {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+func (x *Matrix) GetVector() bool {
+	if x != nil {
+		return x.Vector
+	}
+	return false
+}
+
+type Number struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Value    float64                `protobuf:"fixed64,1,opt,name=value,proto3" json:"value,omitempty"`
+	Exact    string                 `protobuf:"bytes,2,opt,name=exact,proto3" json:"exact,omitempty"`
+	Fraction *Fraction              `protobuf:"bytes,3,opt,name=fraction,proto3" json:"fraction,omitempty"`
+	// imag is the imaginary part of a complex operand, value its real part.
+	Imag   float64 `protobuf:"fixed64,4,opt,name=imag,proto3" json:"imag,omitempty"`
+	Matrix *Matrix `protobuf:"bytes,5,opt,name=matrix,proto3" json:"matrix,omitempty"`
+	// unit is the SI unit of a quantity, whose value is given in that unit.
+	Unit          string `protobuf:"bytes,6,opt,name=unit,proto3" json:"unit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Number) Reset() {
+	*x = Number{}
+	mi := &file_service_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Number) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Number) ProtoMessage() {}
+
+func (x *Number) ProtoReflect() protoreflect.Message {
+	mi := &file_service_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Number.ProtoReflect.Descriptor instead.
+func (*Number) Descriptor() ([]byte, []int) {
+	return file_service_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Number) GetValue() float64 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+func (x *Number) GetExact() string {
+	if x != nil {
+		return x.Exact
+	}
+	return ""
+}
+
+func (x *Number) GetFraction() *Fraction {
+	if x != nil {
+		return x.Fraction
+	}
+	return nil
+}
+
+func (x *Number) GetImag() float64 {
+	if x != nil {
+		return x.Imag
+	}
+	return 0
+}
+
+func (x *Number) GetMatrix() *Matrix {
+	if x != nil {
+		return x.Matrix
+	}
+	return nil
+}
+
+func (x *Number) GetUnit() string {
+	if x != nil {
+		return x.Unit
+	}
+	return ""
+}
+
 type Task struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Id            int32                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
@@ -31,13 +292,22 @@ type Task struct {
 	Operation     string                 `protobuf:"bytes,4,opt,name=operation,proto3" json:"operation,omitempty"`
 	OperationTime *durationpb.Duration   `protobuf:"bytes,5,opt,name=operation_time,json=operationTime,proto3" json:"operation_time,omitempty"`
 	UserId        uint64                 `protobuf:"varint,6,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Args          []string               `protobuf:"bytes,7,rep,name=args,proto3" json:"args,omitempty"`
+	Exact         bool                   `protobuf:"varint,8,opt,name=exact,proto3" json:"exact,omitempty"`
+	Digits        int32                  `protobuf:"varint,9,opt,name=digits,proto3" json:"digits,omitempty"`
+	// arg1, arg2 and args duplicate operands as text for agents that predate them.
+	Operands []*Number `protobuf:"bytes,10,rep,name=operands,proto3" json:"operands,omitempty"`
+	// rational tasks carry fraction operands and expect a rational_result.
+	Rational bool `protobuf:"varint,11,opt,name=rational,proto3" json:"rational,omitempty"`
+	// non_finite is the policy for infinite and NaN results: error, saturate or allow.
+	NonFinite     string `protobuf:"bytes,12,opt,name=non_finite,json=nonFinite,proto3" json:"non_finite,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Task) Reset() {
 	*x = Task{}
-	mi := &file_service_proto_msgTypes[0]
+	mi := &file_service_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -49,7 +319,7 @@ func (x *Task) String() string {
 func (*Task) ProtoMessage() {}
 
 func (x *Task) ProtoReflect() protoreflect.Message {
-	mi := &file_service_proto_msgTypes[0]
+	mi := &file_service_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -62,7 +332,7 @@ func (x *Task) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Task.ProtoReflect.Descriptor instead.
 func (*Task) Descriptor() ([]byte, []int) {
-	return file_service_proto_rawDescGZIP(), []int{0}
+	return file_service_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *Task) GetId() int32 {
@@ -107,6 +377,48 @@ func (x *Task) GetUserId() uint64 {
 	return 0
 }
 
+func (x *Task) GetArgs() []string {
+	if x != nil {
+		return x.Args
+	}
+	return nil
+}
+
+func (x *Task) GetExact() bool {
+	if x != nil {
+		return x.Exact
+	}
+	return false
+}
+
+func (x *Task) GetDigits() int32 {
+	if x != nil {
+		return x.Digits
+	}
+	return 0
+}
+
+func (x *Task) GetOperands() []*Number {
+	if x != nil {
+		return x.Operands
+	}
+	return nil
+}
+
+func (x *Task) GetRational() bool {
+	if x != nil {
+		return x.Rational
+	}
+	return false
+}
+
+func (x *Task) GetNonFinite() string {
+	if x != nil {
+		return x.NonFinite
+	}
+	return ""
+}
+
 type Result struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	Id    int32                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
@@ -115,15 +427,23 @@ type Result struct {
 	//	*Result_IntResult
 	//	*Result_FloatResult
 	//	*Result_Error
-	Value         isResult_Value `protobuf_oneof:"value"`
-	UserId        uint64         `protobuf:"varint,5,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	//	*Result_ExactResult
+	//	*Result_RationalResult
+	//	*Result_ComplexResult
+	//	*Result_MatrixResult
+	Value  isResult_Value `protobuf_oneof:"value"`
+	UserId uint64         `protobuf:"varint,5,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	// unit is the SI unit of a quantity result.
+	Unit string `protobuf:"bytes,10,opt,name=unit,proto3" json:"unit,omitempty"`
+	// error_code classifies an error result, such as division_by_zero.
+	ErrorCode     string `protobuf:"bytes,11,opt,name=error_code,json=errorCode,proto3" json:"error_code,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Result) Reset() {
 	*x = Result{}
-	mi := &file_service_proto_msgTypes[1]
+	mi := &file_service_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -135,7 +455,7 @@ func (x *Result) String() string {
 func (*Result) ProtoMessage() {}
 
 func (x *Result) ProtoReflect() protoreflect.Message {
-	mi := &file_service_proto_msgTypes[1]
+	mi := &file_service_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -148,7 +468,7 @@ func (x *Result) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Result.ProtoReflect.Descriptor instead.
 func (*Result) Descriptor() ([]byte, []int) {
-	return file_service_proto_rawDescGZIP(), []int{1}
+	return file_service_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *Result) GetId() int32 {
@@ -192,6 +512,42 @@ func (x *Result) GetError() string {
 	return ""
 }
 
+func (x *Result) GetExactResult() string {
+	if x != nil {
+		if x, ok := x.Value.(*Result_ExactResult); ok {
+			return x.ExactResult
+		}
+	}
+	return ""
+}
+
+func (x *Result) GetRationalResult() *Fraction {
+	if x != nil {
+		if x, ok := x.Value.(*Result_RationalResult); ok {
+			return x.RationalResult
+		}
+	}
+	return nil
+}
+
+func (x *Result) GetComplexResult() *Complex {
+	if x != nil {
+		if x, ok := x.Value.(*Result_ComplexResult); ok {
+			return x.ComplexResult
+		}
+	}
+	return nil
+}
+
+func (x *Result) GetMatrixResult() *Matrix {
+	if x != nil {
+		if x, ok := x.Value.(*Result_MatrixResult); ok {
+			return x.MatrixResult
+		}
+	}
+	return nil
+}
+
 func (x *Result) GetUserId() uint64 {
 	if x != nil {
 		return x.UserId
@@ -199,6 +555,20 @@ func (x *Result) GetUserId() uint64 {
 	return 0
 }
 
+func (x *Result) GetUnit() string {
+	if x != nil {
+		return x.Unit
+	}
+	return ""
+}
+
+func (x *Result) GetErrorCode() string {
+	if x != nil {
+		return x.ErrorCode
+	}
+	return ""
+}
+
 type isResult_Value interface {
 	isResult_Value()
 }
@@ -215,12 +585,36 @@ type Result_Error struct {
 	Error string `protobuf:"bytes,4,opt,name=error,proto3,oneof"`
 }
 
+type Result_ExactResult struct {
+	ExactResult string `protobuf:"bytes,6,opt,name=exact_result,json=exactResult,proto3,oneof"`
+}
+
+type Result_RationalResult struct {
+	RationalResult *Fraction `protobuf:"bytes,7,opt,name=rational_result,json=rationalResult,proto3,oneof"`
+}
+
+type Result_ComplexResult struct {
+	ComplexResult *Complex `protobuf:"bytes,8,opt,name=complex_result,json=complexResult,proto3,oneof"`
+}
+
+type Result_MatrixResult struct {
+	MatrixResult *Matrix `protobuf:"bytes,9,opt,name=matrix_result,json=matrixResult,proto3,oneof"`
+}
+
 func (*Result_IntResult) isResult_Value() {}
 
 func (*Result_FloatResult) isResult_Value() {}
 
 func (*Result_Error) isResult_Value() {}
 
+func (*Result_ExactResult) isResult_Value() {}
+
+func (*Result_RationalResult) isResult_Value() {}
+
+func (*Result_ComplexResult) isResult_Value() {}
+
+func (*Result_MatrixResult) isResult_Value() {}
+
 type ExpressionRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Expression    string                 `protobuf:"bytes,1,opt,name=expression,proto3" json:"expression,omitempty"`
@@ -231,7 +625,7 @@ type ExpressionRequest struct {
 
 func (x *ExpressionRequest) Reset() {
 	*x = ExpressionRequest{}
-	mi := &file_service_proto_msgTypes[2]
+	mi := &file_service_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -243,7 +637,7 @@ func (x *ExpressionRequest) String() string {
 func (*ExpressionRequest) ProtoMessage() {}
 
 func (x *ExpressionRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_service_proto_msgTypes[2]
+	mi := &file_service_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -256,7 +650,7 @@ func (x *ExpressionRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ExpressionRequest.ProtoReflect.Descriptor instead.
 func (*ExpressionRequest) Descriptor() ([]byte, []int) {
-	return file_service_proto_rawDescGZIP(), []int{2}
+	return file_service_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *ExpressionRequest) GetExpression() string {
@@ -282,7 +676,7 @@ type ExpressionResponse struct {
 
 func (x *ExpressionResponse) Reset() {
 	*x = ExpressionResponse{}
-	mi := &file_service_proto_msgTypes[3]
+	mi := &file_service_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -294,7 +688,7 @@ func (x *ExpressionResponse) String() string {
 func (*ExpressionResponse) ProtoMessage() {}
 
 func (x *ExpressionResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_service_proto_msgTypes[3]
+	mi := &file_service_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -307,7 +701,7 @@ func (x *ExpressionResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ExpressionResponse.ProtoReflect.Descriptor instead.
 func (*ExpressionResponse) Descriptor() ([]byte, []int) {
-	return file_service_proto_rawDescGZIP(), []int{3}
+	return file_service_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *ExpressionResponse) GetTaskId() string {
@@ -327,7 +721,7 @@ type ResultRequest struct {
 
 func (x *ResultRequest) Reset() {
 	*x = ResultRequest{}
-	mi := &file_service_proto_msgTypes[4]
+	mi := &file_service_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -339,7 +733,7 @@ func (x *ResultRequest) String() string {
 func (*ResultRequest) ProtoMessage() {}
 
 func (x *ResultRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_service_proto_msgTypes[4]
+	mi := &file_service_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -352,7 +746,7 @@ func (x *ResultRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ResultRequest.ProtoReflect.Descriptor instead.
 func (*ResultRequest) Descriptor() ([]byte, []int) {
-	return file_service_proto_rawDescGZIP(), []int{4}
+	return file_service_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *ResultRequest) GetTaskId() string {
@@ -383,7 +777,7 @@ type ResultResponse struct {
 
 func (x *ResultResponse) Reset() {
 	*x = ResultResponse{}
-	mi := &file_service_proto_msgTypes[5]
+	mi := &file_service_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -395,7 +789,7 @@ func (x *ResultResponse) String() string {
 func (*ResultResponse) ProtoMessage() {}
 
 func (x *ResultResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_service_proto_msgTypes[5]
+	mi := &file_service_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -408,7 +802,7 @@ func (x *ResultResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ResultResponse.ProtoReflect.Descriptor instead.
 func (*ResultResponse) Descriptor() ([]byte, []int) {
-	return file_service_proto_rawDescGZIP(), []int{5}
+	return file_service_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *ResultResponse) GetResult() isResultResponse_Result {
@@ -469,7 +863,7 @@ type HealthResponse struct {
 
 func (x *HealthResponse) Reset() {
 	*x = HealthResponse{}
-	mi := &file_service_proto_msgTypes[6]
+	mi := &file_service_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -481,7 +875,7 @@ func (x *HealthResponse) String() string {
 func (*HealthResponse) ProtoMessage() {}
 
 func (x *HealthResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_service_proto_msgTypes[6]
+	mi := &file_service_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -494,7 +888,7 @@ func (x *HealthResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use HealthResponse.ProtoReflect.Descriptor instead.
 func (*HealthResponse) Descriptor() ([]byte, []int) {
-	return file_service_proto_rawDescGZIP(), []int{6}
+	return file_service_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *HealthResponse) GetReady() bool {
@@ -515,21 +909,55 @@ var File_service_proto protoreflect.FileDescriptor
 
 const file_service_proto_rawDesc = "" +
 	"\n" +
-	"\rservice.proto\x12\rcalculator.v1\x1a\x1bgoogle/protobuf/empty.proto\x1a\x1egoogle/protobuf/duration.proto\"\xb7\x01\n" +
+	"\rservice.proto\x12\rcalculator.v1\x1a\x1bgoogle/protobuf/empty.proto\x1a\x1egoogle/protobuf/duration.proto\"J\n" +
+	"\bFraction\x12\x1c\n" +
+	"\tnumerator\x18\x01 \x01(\tR\tnumerator\x12 \n" +
+	"\vdenominator\x18\x02 \x01(\tR\vdenominator\"1\n" +
+	"\aComplex\x12\x12\n" +
+	"\x04real\x18\x01 \x01(\x01R\x04real\x12\x12\n" +
+	"\x04imag\x18\x02 \x01(\x01R\x04imag\"`\n" +
+	"\x06Matrix\x12\x12\n" +
+	"\x04rows\x18\x01 \x01(\x05R\x04rows\x12\x12\n" +
+	"\x04cols\x18\x02 \x01(\x05R\x04cols\x12\x16\n" +
+	"\x06values\x18\x03 \x03(\x01R\x06values\x12\x16\n" +
+	"\x06vector\x18\x04 \x01(\bR\x06vector\"\xc0\x01\n" +
+	"\x06Number\x12\x14\n" +
+	"\x05value\x18\x01 \x01(\x01R\x05value\x12\x14\n" +
+	"\x05exact\x18\x02 \x01(\tR\x05exact\x123\n" +
+	"\bfraction\x18\x03 \x01(\v2\x17.calculator.v1.FractionR\bfraction\x12\x12\n" +
+	"\x04imag\x18\x04 \x01(\x01R\x04imag\x12-\n" +
+	"\x06matrix\x18\x05 \x01(\v2\x15.calculator.v1.MatrixR\x06matrix\x12\x12\n" +
+	"\x04unit\x18\x06 \x01(\tR\x04unit\"\xe7\x02\n" +
 	"\x04Task\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\x05R\x02id\x12\x12\n" +
 	"\x04arg1\x18\x02 \x01(\tR\x04arg1\x12\x12\n" +
 	"\x04arg2\x18\x03 \x01(\tR\x04arg2\x12\x1c\n" +
 	"\toperation\x18\x04 \x01(\tR\toperation\x12@\n" +
 	"\x0eoperation_time\x18\x05 \x01(\v2\x19.google.protobuf.DurationR\roperationTime\x12\x17\n" +
-	"\auser_id\x18\x06 \x01(\x04R\x06userId\"\x98\x01\n" +
+	"\auser_id\x18\x06 \x01(\x04R\x06userId\x12\x12\n" +
+	"\x04args\x18\a \x03(\tR\x04args\x12\x14\n" +
+	"\x05exact\x18\b \x01(\bR\x05exact\x12\x16\n" +
+	"\x06digits\x18\t \x01(\x05R\x06digits\x121\n" +
+	"\boperands\x18\n" +
+	" \x03(\v2\x15.calculator.v1.NumberR\boperands\x12\x1a\n" +
+	"\brational\x18\v \x01(\bR\brational\x12\x1d\n" +
+	"\n" +
+	"non_finite\x18\f \x01(\tR\tnonFinite\"\xb3\x03\n" +
 	"\x06Result\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\x05R\x02id\x12\x1f\n" +
 	"\n" +
 	"int_result\x18\x02 \x01(\x03H\x00R\tintResult\x12#\n" +
 	"\ffloat_result\x18\x03 \x01(\x01H\x00R\vfloatResult\x12\x16\n" +
-	"\x05error\x18\x04 \x01(\tH\x00R\x05error\x12\x17\n" +
-	"\auser_id\x18\x05 \x01(\x04R\x06userIdB\a\n" +
+	"\x05error\x18\x04 \x01(\tH\x00R\x05error\x12#\n" +
+	"\fexact_result\x18\x06 \x01(\tH\x00R\vexactResult\x12B\n" +
+	"\x0frational_result\x18\a \x01(\v2\x17.calculator.v1.FractionH\x00R\x0erationalResult\x12?\n" +
+	"\x0ecomplex_result\x18\b \x01(\v2\x16.calculator.v1.ComplexH\x00R\rcomplexResult\x12<\n" +
+	"\rmatrix_result\x18\t \x01(\v2\x15.calculator.v1.MatrixH\x00R\fmatrixResult\x12\x17\n" +
+	"\auser_id\x18\x05 \x01(\x04R\x06userId\x12\x12\n" +
+	"\x04unit\x18\n" +
+	" \x01(\tR\x04unit\x12\x1d\n" +
+	"\n" +
+	"error_code\x18\v \x01(\tR\terrorCodeB\a\n" +
 	"\x05value\"L\n" +
 	"\x11ExpressionRequest\x12\x1e\n" +
 	"\n" +
@@ -568,31 +996,41 @@ func file_service_proto_rawDescGZIP() []byte {
 	return file_service_proto_rawDescData
 }
 
-var file_service_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_service_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
 var file_service_proto_goTypes = []any{
-	(*Task)(nil),                // 0: calculator.v1.Task
-	(*Result)(nil),              // 1: calculator.v1.Result
-	(*ExpressionRequest)(nil),   // 2: calculator.v1.ExpressionRequest
-	(*ExpressionResponse)(nil),  // 3: calculator.v1.ExpressionResponse
-	(*ResultRequest)(nil),       // 4: calculator.v1.ResultRequest
-	(*ResultResponse)(nil),      // 5: calculator.v1.ResultResponse
-	(*HealthResponse)(nil),      // 6: calculator.v1.HealthResponse
-	(*durationpb.Duration)(nil), // 7: google.protobuf.Duration
-	(*emptypb.Empty)(nil),       // 8: google.protobuf.Empty
+	(*Fraction)(nil),            // 0: calculator.v1.Fraction
+	(*Complex)(nil),             // 1: calculator.v1.Complex
+	(*Matrix)(nil),              // 2: calculator.v1.Matrix
+	(*Number)(nil),              // 3: calculator.v1.Number
+	(*Task)(nil),                // 4: calculator.v1.Task
+	(*Result)(nil),              // 5: calculator.v1.Result
+	(*ExpressionRequest)(nil),   // 6: calculator.v1.ExpressionRequest
+	(*ExpressionResponse)(nil),  // 7: calculator.v1.ExpressionResponse
+	(*ResultRequest)(nil),       // 8: calculator.v1.ResultRequest
+	(*ResultResponse)(nil),      // 9: calculator.v1.ResultResponse
+	(*HealthResponse)(nil),      // 10: calculator.v1.HealthResponse
+	(*durationpb.Duration)(nil), // 11: google.protobuf.Duration
+	(*emptypb.Empty)(nil),       // 12: google.protobuf.Empty
 }
 var file_service_proto_depIdxs = []int32{
-	7, // 0: calculator.v1.Task.operation_time:type_name -> google.protobuf.Duration
-	8, // 1: calculator.v1.OrchestratorService.GetTask:input_type -> google.protobuf.Empty
-	1, // 2: calculator.v1.OrchestratorService.SendResult:input_type -> calculator.v1.Result
-	8, // 3: calculator.v1.AgentService.HealthCheck:input_type -> google.protobuf.Empty
-	0, // 4: calculator.v1.OrchestratorService.GetTask:output_type -> calculator.v1.Task
-	8, // 5: calculator.v1.OrchestratorService.SendResult:output_type -> google.protobuf.Empty
-	6, // 6: calculator.v1.AgentService.HealthCheck:output_type -> calculator.v1.HealthResponse
-	4, // [4:7] is the sub-list for method output_type
-	1, // [1:4] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	0,  // 0: calculator.v1.Number.fraction:type_name -> calculator.v1.Fraction
+	2,  // 1: calculator.v1.Number.matrix:type_name -> calculator.v1.Matrix
+	11, // 2: calculator.v1.Task.operation_time:type_name -> google.protobuf.Duration
+	3,  // 3: calculator.v1.Task.operands:type_name -> calculator.v1.Number
+	0,  // 4: calculator.v1.Result.rational_result:type_name -> calculator.v1.Fraction
+	1,  // 5: calculator.v1.Result.complex_result:type_name -> calculator.v1.Complex
+	2,  // 6: calculator.v1.Result.matrix_result:type_name -> calculator.v1.Matrix
+	12, // 7: calculator.v1.OrchestratorService.GetTask:input_type -> google.protobuf.Empty
+	5,  // 8: calculator.v1.OrchestratorService.SendResult:input_type -> calculator.v1.Result
+	12, // 9: calculator.v1.AgentService.HealthCheck:input_type -> google.protobuf.Empty
+	4,  // 10: calculator.v1.OrchestratorService.GetTask:output_type -> calculator.v1.Task
+	12, // 11: calculator.v1.OrchestratorService.SendResult:output_type -> google.protobuf.Empty
+	10, // 12: calculator.v1.AgentService.HealthCheck:output_type -> calculator.v1.HealthResponse
+	10, // [10:13] is the sub-list for method output_type
+	7,  // [7:10] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
 }
 
 func init() { file_service_proto_init() }
@@ -600,12 +1038,16 @@ func file_service_proto_init() {
 	if File_service_proto != nil {
 		return
 	}
-	file_service_proto_msgTypes[1].OneofWrappers = []any{
+	file_service_proto_msgTypes[5].OneofWrappers = []any{
 		(*Result_IntResult)(nil),
 		(*Result_FloatResult)(nil),
 		(*Result_Error)(nil),
+		(*Result_ExactResult)(nil),
+		(*Result_RationalResult)(nil),
+		(*Result_ComplexResult)(nil),
+		(*Result_MatrixResult)(nil),
 	}
-	file_service_proto_msgTypes[5].OneofWrappers = []any{
+	file_service_proto_msgTypes[9].OneofWrappers = []any{
 		(*ResultResponse_Value)(nil),
 		(*ResultResponse_Error)(nil),
 	}
@@ -615,7 +1057,7 @@ func file_service_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_service_proto_rawDesc), len(file_service_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   7,
+			NumMessages:   11,
 			NumExtensions: 0,
 			NumServices:   2,
 		},
@@ -11,12 +11,27 @@ document.getElementById('calculateForm').addEventListener('submit', function(eve
     })
     .then(response => response.json())
     .then(data => {
+        if (data.error) {
+            alert(formatError(data.error));
+            return;
+        }
         alert('Выражение отправлено на вычисление. ID: ' + data.id);
         fetchAllExpressions();
     })
     .catch(error => console.error('Ошибка:', error));
 });
 
+function formatError(error) {
+    if (typeof error === 'string') {
+        return 'Ошибка: ' + error;
+    }
+    let message = 'Ошибка: ' + error.message + '\n\n' + error.snippet + '\n' + error.caret;
+    if (error.suggestion) {
+        message += '\n\nПодсказка: ' + error.suggestion;
+    }
+    return message;
+}
+
 function fetchExpressionById() {
     const expressionId = document.getElementById('expressionId').value;
     if (!expressionId) {
@@ -39,7 +54,7 @@ function fetchExpressionById() {
                 <strong>ID:</strong> ${expr.id}<br>
                 <strong>Выражение:</strong> ${expr.expression}<br>
                 <strong>Статус:</strong> ${expr.status}<br>
-                <strong>Результат:</strong> ${expr.result || 'В процессе вычисления'}
+                <strong>Результат:</strong> ${expr.result || 'В процессе вычисления'}${expr.decimal ? ' ≈ ' + expr.decimal : ''}
             </div>
         `;
     })
@@ -73,7 +88,7 @@ function fetchAllExpressions() {
                 <strong>ID:</strong> ${item.id}<br>
                 <strong>Выражение:</strong> ${item.expression}<br>
                 <strong>Статус:</strong> ${item.status}<br>
-                <strong>Результат:</strong> ${item.result || 'В процессе вычисления'}
+                <strong>Результат:</strong> ${item.result || 'В процессе вычисления'}${item.decimal ? ' ≈ ' + item.decimal : ''}
             `;
             resultsList.appendChild(li);
         });
@@ -94,8 +109,7 @@ function fetchTask() {
         taskResultDiv.innerHTML = `
             <div class="alert alert-secondary">
                 <strong>ID:</strong> ${task.id}<br>
-                <strong>Аргумент 1:</strong> ${task.arg1}<br>
-                <strong>Аргумент 2:</strong> ${task.arg2}<br>
+                <strong>Аргументы:</strong> ${(task.args || [task.arg1, task.arg2]).join(', ')}<br>
                 <strong>Операция:</strong> ${task.operation}<br>
                 <strong>Время выполнения:</strong> ${task.operation_time} сек
             </div>
@@ -7,6 +7,7 @@ import (
 
 	"github.com/DobryySoul/orchestrator/internal/config"
 	"github.com/DobryySoul/orchestrator/internal/controllers/http/server"
+	"github.com/DobryySoul/orchestrator/pkg/calculation"
 	"github.com/DobryySoul/orchestrator/pkg/logger"
 	"go.uber.org/zap"
 )
@@ -27,6 +28,16 @@ func NewApplicationOrchestrator(cfg *config.Config) *Application {
 func (a *Application) Run(ctx context.Context) int {
 	defer a.logger.Sync()
 
+	if err := calculation.DefineUnits(a.cfg.Units); err != nil {
+		a.logger.Error("Invalid units", zap.String("error", err.Error()))
+		return 1
+	}
+
+	if !calculation.ValidNonFinite(a.cfg.NonFinite) {
+		a.logger.Error("Invalid non-finite policy", zap.String("policy", a.cfg.NonFinite))
+		return 1
+	}
+
 	shutDownFunc, err := server.Run(ctx, a.logger, a.cfg)
 	if err != nil {
 		a.logger.Error("Run server error", zap.String("error", err.Error()))
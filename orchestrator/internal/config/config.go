@@ -8,16 +8,39 @@ import (
 )
 
 type Config struct {
-	Host           string `env:"HOST" default:"orchestrator"`
-	Port           string `env:"PORT" default:"9090"`
-	GRPCPort       string `env:"GRPC_PORT" default:"50051"`
-	ComputingPOWER int    `env:"COMPUTING_POWER" default:"3"`
-	PostgresConfig PostgresConfig
-	JWTConfig      JWTConfig
-	TIME_ADDITION  time.Duration
-	TIME_SUBTRACT  time.Duration
-	TIME_MULTIPLY  time.Duration
-	TIME_DIVISION  time.Duration
+	Host                string `env:"HOST" default:"orchestrator"`
+	Port                string `env:"PORT" default:"9090"`
+	GRPCPort            string `env:"GRPC_PORT" default:"50051"`
+	ComputingPOWER      int    `env:"COMPUTING_POWER" default:"3"`
+	ExactDigits         int    `env:"EXACT_DIGITS" default:"50"`
+	MatrixBlockSize     int    `env:"MATRIX_BLOCK_SIZE" default:"32"`
+	Units               string `env:"UNITS"`
+	NonFinite           string `env:"NON_FINITE" default:"error"`
+	PostgresConfig      PostgresConfig
+	JWTConfig           JWTConfig
+	CacheConfig         CacheConfig
+	TIME_ADDITION       time.Duration
+	TIME_SUBTRACT       time.Duration
+	TIME_MULTIPLY       time.Duration
+	TIME_DIVISION       time.Duration
+	TIME_POWER          time.Duration
+	TIME_SQRT           time.Duration
+	TIME_SIN            time.Duration
+	TIME_COS            time.Duration
+	TIME_LOG            time.Duration
+	TIME_ABS            time.Duration
+	TIME_MIN            time.Duration
+	TIME_MAX            time.Duration
+	TIME_MODULO         time.Duration
+	TIME_FLOOR_DIVISION time.Duration
+	TIME_BITWISE_AND    time.Duration
+	TIME_BITWISE_OR     time.Duration
+	TIME_BITWISE_XOR    time.Duration
+	TIME_SHIFT_LEFT     time.Duration
+	TIME_SHIFT_RIGHT    time.Duration
+	TIME_COMPARISON     time.Duration
+	TIME_LOGICAL        time.Duration
+	TIME_MATRIX         time.Duration
 }
 
 type PostgresConfig struct {
@@ -35,11 +58,37 @@ type JWTConfig struct {
 	TTL    string `env:"JWT_TTL" default:"2h"`
 }
 
+// CacheConfig controls the cache of task results shared by all users.
+type CacheConfig struct {
+	Enabled bool   `env:"RESULT_CACHE_ENABLED" default:"true"`
+	Size    int    `env:"RESULT_CACHE_SIZE" default:"10000"`
+	TTL_MS  string `env:"RESULT_CACHE_TTL_MS" default:"600000"`
+	TTL     time.Duration
+}
+
 type Time struct {
-	TIME_ADDITION string `env:"TIME_ADDITION_MS" default:"2000"`
-	TIME_SUBTRACT string `env:"TIME_SUBTRACTION_MS" default:"2000"`
-	TIME_MULTIPLY string `env:"TIME_MULTIPLICATIONS_MS" default:"4000"`
-	TIME_DIVISION string `env:"TIME_DIVISIONS_MS" default:"4000"`
+	TIME_ADDITION       string `env:"TIME_ADDITION_MS" default:"2000"`
+	TIME_SUBTRACT       string `env:"TIME_SUBTRACTION_MS" default:"2000"`
+	TIME_MULTIPLY       string `env:"TIME_MULTIPLICATIONS_MS" default:"4000"`
+	TIME_DIVISION       string `env:"TIME_DIVISIONS_MS" default:"4000"`
+	TIME_POWER          string `env:"TIME_POWER_MS" default:"6000"`
+	TIME_SQRT           string `env:"TIME_SQRT_MS" default:"3000"`
+	TIME_SIN            string `env:"TIME_SIN_MS" default:"3000"`
+	TIME_COS            string `env:"TIME_COS_MS" default:"3000"`
+	TIME_LOG            string `env:"TIME_LOG_MS" default:"3000"`
+	TIME_ABS            string `env:"TIME_ABS_MS" default:"1000"`
+	TIME_MIN            string `env:"TIME_MIN_MS" default:"2000"`
+	TIME_MAX            string `env:"TIME_MAX_MS" default:"2000"`
+	TIME_MODULO         string `env:"TIME_MODULO_MS" default:"4000"`
+	TIME_FLOOR_DIVISION string `env:"TIME_FLOOR_DIVISION_MS" default:"4000"`
+	TIME_BITWISE_AND    string `env:"TIME_BITWISE_AND_MS" default:"1000"`
+	TIME_BITWISE_OR     string `env:"TIME_BITWISE_OR_MS" default:"1000"`
+	TIME_BITWISE_XOR    string `env:"TIME_BITWISE_XOR_MS" default:"1000"`
+	TIME_SHIFT_LEFT     string `env:"TIME_SHIFT_LEFT_MS" default:"1000"`
+	TIME_SHIFT_RIGHT    string `env:"TIME_SHIFT_RIGHT_MS" default:"1000"`
+	TIME_COMPARISON     string `env:"TIME_COMPARISON_MS" default:"1000"`
+	TIME_LOGICAL        string `env:"TIME_LOGICAL_MS" default:"1000"`
+	TIME_MATRIX         string `env:"TIME_MATRIX_MS" default:"2000"`
 }
 
 func LoadConfigEnv() (*Config, error) {
@@ -64,6 +113,11 @@ func LoadConfigEnv() (*Config, error) {
 		return nil, fmt.Errorf("failed to unmarshal env: %w", err)
 	}
 
+	var CacheConfig CacheConfig
+	if err := env.Unmarshal("", &CacheConfig); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal env: %w", err)
+	}
+
 	var Time Time
 	if err := env.Unmarshal("", &Time); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal env: %w", err)
@@ -73,9 +127,30 @@ func LoadConfigEnv() (*Config, error) {
 	cfg.TIME_SUBTRACT, _ = time.ParseDuration(Time.TIME_SUBTRACT + "ms")
 	cfg.TIME_MULTIPLY, _ = time.ParseDuration(Time.TIME_MULTIPLY + "ms")
 	cfg.TIME_DIVISION, _ = time.ParseDuration(Time.TIME_DIVISION + "ms")
+	cfg.TIME_POWER, _ = time.ParseDuration(Time.TIME_POWER + "ms")
+	cfg.TIME_SQRT, _ = time.ParseDuration(Time.TIME_SQRT + "ms")
+	cfg.TIME_SIN, _ = time.ParseDuration(Time.TIME_SIN + "ms")
+	cfg.TIME_COS, _ = time.ParseDuration(Time.TIME_COS + "ms")
+	cfg.TIME_LOG, _ = time.ParseDuration(Time.TIME_LOG + "ms")
+	cfg.TIME_ABS, _ = time.ParseDuration(Time.TIME_ABS + "ms")
+	cfg.TIME_MIN, _ = time.ParseDuration(Time.TIME_MIN + "ms")
+	cfg.TIME_MAX, _ = time.ParseDuration(Time.TIME_MAX + "ms")
+	cfg.TIME_MODULO, _ = time.ParseDuration(Time.TIME_MODULO + "ms")
+	cfg.TIME_FLOOR_DIVISION, _ = time.ParseDuration(Time.TIME_FLOOR_DIVISION + "ms")
+	cfg.TIME_BITWISE_AND, _ = time.ParseDuration(Time.TIME_BITWISE_AND + "ms")
+	cfg.TIME_BITWISE_OR, _ = time.ParseDuration(Time.TIME_BITWISE_OR + "ms")
+	cfg.TIME_BITWISE_XOR, _ = time.ParseDuration(Time.TIME_BITWISE_XOR + "ms")
+	cfg.TIME_SHIFT_LEFT, _ = time.ParseDuration(Time.TIME_SHIFT_LEFT + "ms")
+	cfg.TIME_SHIFT_RIGHT, _ = time.ParseDuration(Time.TIME_SHIFT_RIGHT + "ms")
+	cfg.TIME_COMPARISON, _ = time.ParseDuration(Time.TIME_COMPARISON + "ms")
+	cfg.TIME_LOGICAL, _ = time.ParseDuration(Time.TIME_LOGICAL + "ms")
+	cfg.TIME_MATRIX, _ = time.ParseDuration(Time.TIME_MATRIX + "ms")
 
 	cfg.PostgresConfig = PostgresConfig
 	cfg.JWTConfig = JWTConfig
 
+	CacheConfig.TTL, _ = time.ParseDuration(CacheConfig.TTL_MS + "ms")
+	cfg.CacheConfig = CacheConfig
+
 	return &cfg, nil
 }
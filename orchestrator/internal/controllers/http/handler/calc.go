@@ -9,6 +9,7 @@ import (
 	"github.com/DobryySoul/orchestrator/internal/controllers/http/models/req"
 	"github.com/DobryySoul/orchestrator/internal/controllers/http/models/resp"
 	"github.com/DobryySoul/orchestrator/internal/service"
+	"github.com/DobryySoul/orchestrator/pkg/calculation"
 	"go.uber.org/zap"
 )
 
@@ -79,7 +80,14 @@ func (cs *calcHandlers) Calculate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, err := cs.CalcService.AddExpression(expr.Expression, userID)
+	id, err := cs.CalcService.AddExpression(expr, userID)
+
+	if details := service.ErrorDetails(err); details != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+
+		_ = json.NewEncoder(w).Encode(resp.ExpressionError{Error: *details})
+		return
+	}
 
 	if err != nil {
 		w.WriteHeader(http.StatusUnprocessableEntity)
@@ -107,6 +115,46 @@ func (cs *calcHandlers) Calculate(w http.ResponseWriter, r *http.Request) {
 
 }
 
+// Parse returns the syntax tree of an expression without calculating it.
+func (cs *calcHandlers) Parse(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	var (
+		expr          req.ExpressionRequest
+		responseError resp.ResponseError
+	)
+
+	err := json.NewDecoder(r.Body).Decode(&expr)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+
+		responseError.Error = invalidExpression
+
+		_ = json.NewEncoder(w).Encode(responseError)
+		return
+	}
+
+	tree, err := calculation.Parse(expr.Expression)
+	if details := service.ErrorDetails(err); details != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+
+		_ = json.NewEncoder(w).Encode(resp.ExpressionError{Error: *details})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+
+		responseError.Error = err.Error()
+
+		_ = json.NewEncoder(w).Encode(responseError)
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(resp.Tree{Tree: tree})
+}
+
 func (cs *calcHandlers) ListAll(w http.ResponseWriter, r *http.Request) {
 	cookie, err := r.Cookie("user_id")
 	if err != nil {
@@ -193,6 +241,103 @@ func (cs *calcHandlers) ListByID(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// Graph returns the task graph of an expression with the status, agent and
+// timing of every node.
+func (cs *calcHandlers) Graph(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie("user_id")
+	if err != nil {
+		cs.log.Warn("could not find user id")
+		return
+	}
+
+	userID, err := strconv.ParseUint(cookie.Value, 10, 64)
+	if err != nil {
+		cs.log.Warn("could not convert string to int0", zap.String("value", cookie.Value))
+		return
+	}
+
+	defer r.Body.Close()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	var responseError resp.ResponseError
+
+	ID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+
+		responseError.Error = invalidId
+
+		_ = json.NewEncoder(w).Encode(responseError)
+		return
+	}
+
+	graph, err := cs.CalcService.FindGraph(ID, userID)
+	if err != nil {
+		cs.log.Error("expression not found by id", zap.Int("id", ID), zap.Error(err))
+		w.WriteHeader(http.StatusNotFound)
+
+		responseError.Error = expressionNotFound
+
+		_ = json.NewEncoder(w).Encode(responseError)
+		return
+	}
+
+	if err = json.NewEncoder(w).Encode(graph); err != nil {
+		cs.log.Error("could not encode graph", zap.Int("id", ID), zap.Error(err))
+	}
+}
+
+// GetFormat returns the default result format of the user.
+func (cs *calcHandlers) GetFormat(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cs.formatUser(w, r)
+	if !ok {
+		return
+	}
+
+	defer r.Body.Close()
+
+	_ = json.NewEncoder(w).Encode(cs.CalcService.UserFormat(userID))
+}
+
+// SetFormat replaces the default result format of the user. An empty
+// object restores the default.
+func (cs *calcHandlers) SetFormat(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cs.formatUser(w, r)
+	if !ok {
+		return
+	}
+
+	defer r.Body.Close()
+
+	var (
+		format        calculation.Format
+		responseError resp.ResponseError
+	)
+
+	if err := json.NewDecoder(r.Body).Decode(&format); err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+
+		responseError.Error = invalidFormat
+
+		_ = json.NewEncoder(w).Encode(responseError)
+		return
+	}
+
+	if err := cs.CalcService.SetUserFormat(userID, format); err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+
+		responseError.Error = err.Error()
+
+		_ = json.NewEncoder(w).Encode(responseError)
+		return
+	}
+
+	cs.log.Info("result format changed", zap.Uint64("user_id", userID))
+
+	_ = json.NewEncoder(w).Encode(format)
+}
+
 func (cs *calcHandlers) SendTask(w http.ResponseWriter, r *http.Request) {
 	cookie, err := r.Cookie("user_id")
 	if err != nil {
@@ -214,7 +359,7 @@ func (cs *calcHandlers) SendTask(w http.ResponseWriter, r *http.Request) {
 
 	cs.log.Info("fetching new task from queue")
 
-	newTask := cs.CalcService.GetTaskUser(userID)
+	newTask := cs.CalcService.GetTaskUser(userID, r.RemoteAddr)
 	if newTask == nil {
 		cs.log.Warn("no tasks in queue")
 		w.WriteHeader(http.StatusNotFound)
@@ -281,7 +426,12 @@ func (cs *calcHandlers) ReceiveResult(w http.ResponseWriter, r *http.Request) {
 
 	cs.log.Info("received result", zap.Int("id", res.ID), zap.Any("value", res.Value))
 
-	if err = cs.CalcService.PutResultUser(res.ID, res.Value, userID); err != nil {
+	if res.Error != "" {
+		err = cs.CalcService.FailTaskUser(res.ID, res.ErrorCode, res.Error, userID)
+	} else {
+		err = cs.CalcService.PutResultUser(res.ID, res.Value, userID)
+	}
+	if err != nil {
 		cs.log.Error("can't put result", zap.Int("id", res.ID), zap.Error(err))
 		w.WriteHeader(http.StatusNotFound)
 
@@ -323,13 +473,33 @@ func (cs *calcHandlers) GetStatistics(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	stats := resp.Statistics{
-		Operations: map[string]int{
-			"+": cs.CalcService.GetOperationCount("+"),
-			"-": cs.CalcService.GetOperationCount("-"),
-			"*": cs.CalcService.GetOperationCount("*"),
-			"/": cs.CalcService.GetOperationCount("/"),
-		},
+		Operations: cs.CalcService.GetOperationsCount(),
+		Cache:      cs.CalcService.CacheStatistics(),
 	}
 
 	_ = json.NewEncoder(w).Encode(stats)
 }
+
+// formatUser returns the user whose format settings are asked for. Without
+// the user_id cookie it answers 401, with a malformed one 400.
+func (cs *calcHandlers) formatUser(w http.ResponseWriter, r *http.Request) (uint64, bool) {
+	w.Header().Set("Content-Type", "application/json")
+
+	cookie, err := r.Cookie("user_id")
+	if err != nil {
+		cs.log.Warn("could not find user id")
+		w.WriteHeader(http.StatusUnauthorized)
+		_ = json.NewEncoder(w).Encode(resp.ResponseError{Error: unauthorized})
+		return 0, false
+	}
+
+	userID, err := strconv.ParseUint(cookie.Value, 10, 64)
+	if err != nil {
+		cs.log.Warn("could not convert string to int0", zap.String("value", cookie.Value))
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(resp.ResponseError{Error: invalidUserID})
+		return 0, false
+	}
+
+	return userID, true
+}
@@ -9,6 +9,9 @@ const (
 	expressionNotFound = "expression not found"
 	emptyQueue         = "no tasks in queue"
 	invalidResultInput = "invalid result"
+	invalidFormat      = "invalid format"
+	unauthorized       = "unauthorized"
+	invalidUserID      = "invalid user id"
 )
 
 var (
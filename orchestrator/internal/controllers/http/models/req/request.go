@@ -1,11 +1,25 @@
 package req
 
+import "github.com/DobryySoul/orchestrator/pkg/calculation"
+
 type Result struct {
-	ID     int    `json:"id"`
-	Value  any    `json:"result"`
-	UserID uint64 `json:"user_id"`
+	ID        int    `json:"id"`
+	Value     any    `json:"result"`
+	Error     string `json:"error,omitempty"` // set instead of Value when the task failed
+	ErrorCode string `json:"error_code,omitempty"`
+	UserID    uint64 `json:"user_id"`
 }
 
 type ExpressionRequest struct {
-	Expression string `json:"expression"`
+	Expression       string              `json:"expression"`
+	Variables        map[string]float64  `json:"variables,omitempty"`
+	Precision        string              `json:"precision,omitempty"`
+	Digits           int                 `json:"digits,omitempty"`
+	NumberMode       string              `json:"number_mode,omitempty"`
+	DecimalSeparator string              `json:"decimal_separator,omitempty"`
+	Locale           string              `json:"locale,omitempty"`
+	Format           *calculation.Format `json:"format,omitempty"`
+	NonFinite        string              `json:"non_finite,omitempty"`
+	Optimize         *bool               `json:"optimize,omitempty"`
+	Rebalance        *bool               `json:"rebalance,omitempty"`
 }
@@ -1,14 +1,36 @@
 package resp
 
 import (
-	"container/list"
 	"time"
+
+	"github.com/DobryySoul/orchestrator/pkg/calculation"
+	"github.com/DobryySoul/orchestrator/pkg/calculation/ast"
 )
 
 type ResponseError struct {
 	Error string `json:"error"`
 }
 
+// ErrorDetails locates an error in the submitted expression; Caret printed
+// under Snippet underlines the offending token.
+type ErrorDetails struct {
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	Position   int    `json:"position"`
+	Token      string `json:"token,omitempty"`
+	Snippet    string `json:"snippet"`
+	Caret      string `json:"caret"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+type ExpressionError struct {
+	Error ErrorDetails `json:"error"`
+}
+
+type Tree struct {
+	Tree *ast.Node `json:"tree"`
+}
+
 type Created struct {
 	Id int `json:"id"`
 }
@@ -17,18 +39,100 @@ type Task struct {
 	ID            int           `json:"id"`
 	Arg1          string        `json:"arg1"`
 	Arg2          string        `json:"arg2"`
+	Args          []string      `json:"args,omitempty"`
+	Operands      []Operand     `json:"-"`
+	Exact         bool          `json:"exact,omitempty"`
+	Digits        int           `json:"digits,omitempty"`
+	Rational      bool          `json:"rational,omitempty"`
+	NonFinite     string        `json:"non_finite,omitempty"`
 	Operation     string        `json:"operation"`
 	OperationTime time.Duration `json:"operation_time"`
 	UserID        uint64        `json:"user_id"`
 }
 
+type Operand struct {
+	Value  float64
+	Exact  string
+	Imag   float64             // imaginary part of a complex operand
+	Matrix *calculation.Matrix // value of an array operand
+	Unit   string              // unit a quantity is shown in; Value is in SI base units
+}
+
 type Expression struct {
-	*list.List
-	UserID     uint64 `json:"user_id"`
-	ID         int    `json:"id"`
-	Status     string `json:"status"`
-	Result     string `json:"result"`
-	Expression string `json:"expression"`
+	Graph      *Graph              `json:"-"`
+	UserID     uint64              `json:"user_id"`
+	ID         int                 `json:"id"`
+	Status     string              `json:"status"`
+	Result     string              `json:"result"`
+	Value      string              `json:"-"` // unformatted result, for references
+	Decimal    string              `json:"decimal,omitempty"`
+	Error      *ErrorDetails       `json:"error,omitempty"`
+	FailedTask *FailedTask         `json:"failed_task,omitempty"`
+	Expression string              `json:"expression"`
+	Variables  map[string]float64  `json:"variables,omitempty"`
+	References map[string]string   `json:"references,omitempty"`
+	WaitingOn  []int               `json:"waiting_on,omitempty"`
+	Precision  string              `json:"precision,omitempty"`
+	Digits     int                 `json:"digits,omitempty"`
+	NumberMode string              `json:"number_mode,omitempty"`
+	Separator  string              `json:"decimal_separator,omitempty"`
+	Locale     string              `json:"locale,omitempty"`
+	Format     *calculation.Format `json:"format,omitempty"`
+	NonFinite  string              `json:"non_finite,omitempty"`
+	Optimize   *bool               `json:"optimize,omitempty"`
+	Rebalance  *bool               `json:"rebalance,omitempty"`
+	SavedTasks int                 `json:"saved_tasks,omitempty"`
+
+	Simplifications []calculation.Simplification `json:"simplifications,omitempty"`
+}
+
+// FailedTask is the task whose failure ended an expression. Code
+// classifies the failure, such as division_by_zero.
+type FailedTask struct {
+	ID        int      `json:"id"`
+	Node      int      `json:"node"`
+	Operation string   `json:"operation"`
+	Args      []string `json:"args,omitempty"`
+	Agent     string   `json:"agent,omitempty"`
+	Code      string   `json:"code"`
+	Reason    string   `json:"reason"`
+}
+
+// Graph is the task DAG of an expression. Every operation is a node whose
+// Inputs are the IDs of the nodes it takes its operands from; Root is the
+// node that yields the result. Shared counts the operations that were not
+// scheduled because an identical subexpression is computed elsewhere.
+type Graph struct {
+	Nodes  []*GraphNode `json:"nodes"`
+	Root   int          `json:"root"`
+	Shared int          `json:"shared,omitempty"`
+}
+
+type GraphNode struct {
+	ID         int        `json:"id"`
+	Operation  string     `json:"operation,omitempty"`
+	Inputs     []int      `json:"inputs,omitempty"`
+	Status     string     `json:"status"`
+	Result     string     `json:"result,omitempty"`
+	TaskID     *int       `json:"task_id,omitempty"`
+	Agent      string     `json:"agent,omitempty"`
+	Cached     bool       `json:"cached,omitempty"`
+	QueuedAt   *time.Time `json:"queued_at,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Blocks     int        `json:"blocks,omitempty"` // tasks a matrix product is split into
+
+	Value      Operand `json:"-"`
+	Boolean    bool    `json:"-"`
+	Source     string  `json:"-"` // subexpression the node computes
+	Remaining  int     `json:"-"` // blocks still being computed
+	Dependents []int   `json:"-"` // nodes that take this node as an input
+}
+
+type ExpressionGraph struct {
+	ID     int    `json:"id"`
+	Status string `json:"status"`
+	Graph  *Graph `json:"graph,omitempty"`
 }
 
 type ExpressionUnit struct {
@@ -42,4 +146,12 @@ type ExpressionList struct {
 type Statistics struct {
 	Operations map[string]int   `json:"operations"`
 	AvgTime    map[string]int64 `json:"avg_time"`
+	Cache      *CacheStatistics `json:"cache,omitempty"`
+}
+
+// CacheStatistics counts lookups in the task result cache since start.
+type CacheStatistics struct {
+	Hits   int `json:"hits"`
+	Misses int `json:"misses"`
+	Size   int `json:"size"`
 }
@@ -71,8 +71,12 @@ func Run(ctx context.Context, logger *zap.Logger, cfg *config.Config) (func(cont
 			http.ServeFile(w, r, filepath.Join(frontendDir, "index.html"))
 		})
 		r.Post("/api/v1/calculate", calcHandler.Calculate)
+		r.Post("/api/v1/parse", calcHandler.Parse)
 		r.Get("/api/v1/expressions", calcHandler.ListAll)
 		r.Get("/api/v1/expressions/{id}", calcHandler.ListByID)
+		r.Get("/api/v1/expressions/{id}/graph", calcHandler.Graph)
+		r.Get("/api/v1/settings/format", calcHandler.GetFormat)
+		r.Put("/api/v1/settings/format", calcHandler.SetFormat)
 	})
 
 	httpServer := &http.Server{
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"container/list"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/DobryySoul/orchestrator/internal/controllers/http/models/resp"
+)
+
+// commutative operations get their operands sorted in cache keys, so that
+// 2+3 and 3+2 share an entry.
+var commutative = map[string]bool{
+	"+": true, "*": true, "&": true, "|": true, "xor": true,
+	"==": true, "!=": true, "&&": true, "||": true,
+	"min": true, "max": true,
+}
+
+// resultCache is a bounded LRU of task results shared by all users. It is
+// not safe for concurrent use; CalcService guards it with its mutex.
+type resultCache struct {
+	size    int
+	ttl     time.Duration // zero keeps entries until they are evicted
+	entries map[string]*list.Element
+	order   *list.List // most recently used first
+	hits    int
+	misses  int
+	now     func() time.Time
+}
+
+type cacheEntry struct {
+	key     string
+	value   resp.Operand
+	expires time.Time
+}
+
+func newResultCache(size int, ttl time.Duration) *resultCache {
+	return &resultCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+// taskKey identifies a task by its operation and operands. Exact tasks are
+// keyed on their precision as well, since it bounds irrational results, all
+// tasks on their number mode, since a rational task rejects what an exact one
+// approximates, and on their non-finite policy, since it decides saturated
+// results.
+func taskKey(task *resp.Task) string {
+	args := task.Args
+	if commutative[task.Operation] {
+		args = slices.Clone(args)
+		slices.Sort(args)
+	}
+
+	digits := 0
+	if task.Exact {
+		digits = task.Digits
+	}
+	return fmt.Sprintf("%s(%s) exact=%t rational=%t digits=%d non_finite=%s", task.Operation, strings.Join(args, ","), task.Exact, task.Rational, digits, task.NonFinite)
+}
+
+func (c *resultCache) get(key string) (resp.Operand, bool) {
+	element, ok := c.entries[key]
+	if ok && c.expired(element.Value.(*cacheEntry)) {
+		c.remove(element)
+		ok = false
+	}
+	if !ok {
+		c.misses++
+		return resp.Operand{}, false
+	}
+
+	c.hits++
+	c.order.MoveToFront(element)
+	return element.Value.(*cacheEntry).value, true
+}
+
+func (c *resultCache) put(key string, value resp.Operand) {
+	var expires time.Time
+	if c.ttl > 0 {
+		expires = c.now().Add(c.ttl)
+	}
+
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*cacheEntry)
+		entry.value, entry.expires = value, expires
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, value: value, expires: expires})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *resultCache) expired(entry *cacheEntry) bool {
+	return !entry.expires.IsZero() && !c.now().Before(entry.expires)
+}
+
+func (c *resultCache) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*cacheEntry).key)
+}
+
+func (c *resultCache) statistics() *resp.CacheStatistics {
+	return &resp.CacheStatistics{
+		Hits:   c.hits,
+		Misses: c.misses,
+		Size:   c.order.Len(),
+	}
+}
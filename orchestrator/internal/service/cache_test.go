@@ -0,0 +1,59 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DobryySoul/orchestrator/internal/controllers/http/models/resp"
+)
+
+func TestResultCache(t *testing.T) {
+	now := time.Unix(0, 0)
+	cache := newResultCache(2, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	cache.put("a", resp.Operand{Value: 1})
+	cache.put("b", resp.Operand{Value: 2})
+	if _, ok := cache.get("a"); !ok {
+		t.Fatal("get(a) missed a fresh entry")
+	}
+
+	// b is now the least recently used entry.
+	cache.put("c", resp.Operand{Value: 3})
+	if _, ok := cache.get("b"); ok {
+		t.Error("get(b) hit an evicted entry")
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := cache.get("a"); ok {
+		t.Error("get(a) hit an expired entry")
+	}
+
+	want := resp.CacheStatistics{Hits: 1, Misses: 2, Size: 1}
+	if got := *cache.statistics(); got != want {
+		t.Errorf("statistics() = %+v, want %+v", got, want)
+	}
+}
+
+func TestTaskKey(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b *resp.Task
+		same bool
+	}{
+		{"commutative", &resp.Task{Operation: "+", Args: []string{"2", "3"}}, &resp.Task{Operation: "+", Args: []string{"3", "2"}}, true},
+		{"ordered", &resp.Task{Operation: "-", Args: []string{"2", "3"}}, &resp.Task{Operation: "-", Args: []string{"3", "2"}}, false},
+		{"precision", &resp.Task{Operation: "+", Args: []string{"2", "3"}}, &resp.Task{Operation: "+", Args: []string{"2", "3"}, Exact: true, Digits: 50}, false},
+		{"number mode", &resp.Task{Operation: "/", Args: []string{"1", "3"}, Exact: true, Rational: true, Digits: 50}, &resp.Task{Operation: "/", Args: []string{"1", "3"}, Exact: true, Digits: 50}, false},
+		{"non-finite policy", &resp.Task{Operation: "^", Args: []string{"10", "400"}, NonFinite: "saturate"}, &resp.Task{Operation: "^", Args: []string{"10", "400"}}, false},
+		{"float digits", &resp.Task{Operation: "+", Args: []string{"2", "3"}, Digits: 10}, &resp.Task{Operation: "+", Args: []string{"2", "3"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if same := taskKey(tt.a) == taskKey(tt.b); same != tt.same {
+				t.Errorf("taskKey(%v) == taskKey(%v) is %t, want %t", tt.a.Args, tt.b.Args, same, tt.same)
+			}
+		})
+	}
+}
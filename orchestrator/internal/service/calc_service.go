@@ -2,23 +2,31 @@ package service
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"slices"
 	"sync"
 	"time"
 
 	"github.com/DobryySoul/orchestrator/internal/config"
+	"github.com/DobryySoul/orchestrator/internal/controllers/http/models/req"
 	"github.com/DobryySoul/orchestrator/internal/controllers/http/models/resp"
 	"github.com/DobryySoul/orchestrator/internal/timeout"
 	pb "github.com/DobryySoul/orchestrator/pkg/api/v1"
+	"github.com/DobryySoul/orchestrator/pkg/calculation"
 	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
 
+// Codes of task failures that agents do not classify themselves.
+const (
+	codeAgentError    = "agent_error"
+	codeInvalidResult = "invalid_result"
+)
+
 type OrchestratorServiceServer interface {
 	pb.UnimplementedOrchestratorServiceServer
 	GetTask(context.Context, *emptypb.Empty) (*pb.Task, error)
@@ -31,9 +39,11 @@ type CalcService struct {
 	taskID        int
 	userTaskTable map[uint64]map[int]ExprElement
 	userTasks     map[uint64][]*resp.Task
+	userFormats   map[uint64]calculation.Format
 	timeTable     map[string]time.Duration
 	timeoutsTable map[int]*timeout.Timeout
 	Operations    map[string]int
+	cache         *resultCache
 	mutex         sync.RWMutex
 	logger        *zap.Logger
 }
@@ -44,31 +54,60 @@ func NewCalcService(cfg *config.Config, logger *zap.Logger) *CalcService {
 		userExprTable: make(map[uint64]map[int]*resp.Expression),
 		userTaskTable: make(map[uint64]map[int]ExprElement),
 		userTasks:     make(map[uint64][]*resp.Task),
+		userFormats:   make(map[uint64]calculation.Format),
 		timeTable:     make(map[string]time.Duration),
 		timeoutsTable: make(map[int]*timeout.Timeout),
 		mutex:         sync.RWMutex{},
 		logger:        logger,
-		Operations: map[string]int{
-			"+": 0,
-			"-": 0,
-			"*": 0,
-			"/": 0,
-		},
+		Operations:    make(map[string]int),
 	}
 
 	CS.timeTable["+"] = cfg.TIME_ADDITION
 	CS.timeTable["-"] = cfg.TIME_SUBTRACT
 	CS.timeTable["*"] = cfg.TIME_MULTIPLY
 	CS.timeTable["/"] = cfg.TIME_DIVISION
+	CS.timeTable["^"] = cfg.TIME_POWER
+	CS.timeTable[calculation.UnaryMinus] = cfg.TIME_SUBTRACT
+	CS.timeTable["sqrt"] = cfg.TIME_SQRT
+	CS.timeTable["sin"] = cfg.TIME_SIN
+	CS.timeTable["cos"] = cfg.TIME_COS
+	CS.timeTable["log"] = cfg.TIME_LOG
+	CS.timeTable["abs"] = cfg.TIME_ABS
+	CS.timeTable["min"] = cfg.TIME_MIN
+	CS.timeTable["max"] = cfg.TIME_MAX
+	CS.timeTable["%"] = cfg.TIME_MODULO
+	CS.timeTable["//"] = cfg.TIME_FLOOR_DIVISION
+	CS.timeTable["&"] = cfg.TIME_BITWISE_AND
+	CS.timeTable["|"] = cfg.TIME_BITWISE_OR
+	CS.timeTable["xor"] = cfg.TIME_BITWISE_XOR
+	CS.timeTable["<<"] = cfg.TIME_SHIFT_LEFT
+	CS.timeTable[">>"] = cfg.TIME_SHIFT_RIGHT
+	for _, op := range []string{"==", "!=", "<", "<=", ">", ">="} {
+		CS.timeTable[op] = cfg.TIME_COMPARISON
+	}
+	CS.timeTable["&&"] = cfg.TIME_LOGICAL
+	CS.timeTable["||"] = cfg.TIME_LOGICAL
+	CS.timeTable[calculation.UnaryNot] = cfg.TIME_LOGICAL
+	CS.timeTable[calculation.Dot] = cfg.TIME_MATRIX
+	CS.timeTable[calculation.Transpose] = cfg.TIME_MATRIX
+	CS.timeTable[calculation.Det] = cfg.TIME_MATRIX
+
+	for op := range CS.timeTable {
+		CS.Operations[op] = 0
+	}
+
+	if cfg.CacheConfig.Enabled && cfg.CacheConfig.Size > 0 {
+		CS.cache = newResultCache(cfg.CacheConfig.Size, cfg.CacheConfig.TTL)
+	}
 
 	return CS
 }
 
-func (cs *CalcService) AddExpression(expr string, userID uint64) (int, error) {
+func (cs *CalcService) AddExpression(request req.ExpressionRequest, userID uint64) (int, error) {
 	cs.mutex.Lock()
 	defer cs.mutex.Unlock()
 
-	if len(expr) == 0 {
+	if len(request.Expression) == 0 {
 		return 0, nil
 	}
 
@@ -87,25 +126,144 @@ func (cs *CalcService) AddExpression(expr string, userID uint64) (int, error) {
 		id = maxID + 1
 	}
 
-	operations := extractOperations(expr)
+	if request.Format == nil {
+		if format, ok := cs.userFormats[userID]; ok {
+			request.Format = &format
+		}
+	}
+
+	if request.NonFinite == "" {
+		request.NonFinite = cs.cfg.NonFinite
+	}
 
-	expression, err := NewExpression(id, expr)
-	expression.UserID = userID
+	exact := request.Precision == calculation.PrecisionExact || request.NumberMode == calculation.NumberModeRational
+	if exact && request.Digits <= 0 {
+		request.Digits = cs.cfg.ExactDigits
+	}
+
+	expression, err := cs.startExpression(id, request, userID, nil)
+
+	cs.logger.Info("adding", zap.Int("id", id), zap.String("expression", request.Expression), zap.String("status", expression.Status))
+
+	return id, err
+}
 
-	cs.logger.Info("adding", zap.Int("id", id), zap.String("expression", expr), zap.String("status", expression.Status))
+// startExpression builds the expression, stores it and schedules its tasks.
+// Expressions that reference unfinished ones are stored without tasks and
+// started again by resolveDependents.
+func (cs *CalcService) startExpression(id int, request req.ExpressionRequest, userID uint64, references map[string]string) (*resp.Expression, error) {
+	if references == nil {
+		references = make(map[string]string)
+	}
 
-	for _, op := range operations {
-		cs.Operations[op]++
+	var waitingOn []int
+	expression, err := NewExpression(id, request, cs.resolver(id, userID, references, &waitingOn))
+	expression.UserID = userID
+	expression.WaitingOn = waitingOn
+	if len(references) > 0 {
+		expression.References = references
 	}
 
 	cs.userExprTable[userID][id] = expression
-	if err == nil && expression.Status == StatusWaiting {
-		cs.extractTasksFromExpression(expression, userID)
+	if err == nil && expression.Graph != nil && expression.Status == StatusWaiting {
+		cs.activate(expression, expression.Graph.Nodes[expression.Graph.Root])
+	} else {
+		cs.resolveDependents(expression)
+	}
+
+	return expression, err
+}
+
+// resolver looks up ans and $N in the user's history. Resolved values are
+// remembered in references so that a restarted expression sees the same ans.
+func (cs *CalcService) resolver(id int, userID uint64, references map[string]string, waitingOn *[]int) calculation.Resolver {
+	return func(reference string) (string, error) {
+		if value, ok := references[reference]; ok {
+			return value, nil
+		}
+
+		var referenced *resp.Expression
+		if refID, ok := calculation.ReferenceID(reference); ok && refID < id {
+			referenced = cs.userExprTable[userID][refID]
+		} else if reference == calculation.LastResult {
+			for exprID, expr := range cs.userExprTable[userID] {
+				if exprID < id && expr.Status == StatusDone && (referenced == nil || exprID > referenced.ID) {
+					referenced = expr
+				}
+			}
+		}
+
+		switch {
+		case referenced == nil:
+			return "", calculation.ErrUnknownReference
+		case referenced.Status == StatusError:
+			return "", calculation.ErrFailedReference
+		case referenced.Status != StatusDone:
+			*waitingOn = append(*waitingOn, referenced.ID)
+			return "", calculation.ErrPendingReference
+		}
+
+		value := referenceValue(referenced)
+		references[reference] = value
+		return value, nil
+	}
+}
+
+// resolveDependents restarts the expressions waiting on a finished one.
+func (cs *CalcService) resolveDependents(finished *resp.Expression) {
+	var dependents []*resp.Expression
+	for _, expr := range cs.userExprTable[finished.UserID] {
+		if slices.Contains(expr.WaitingOn, finished.ID) {
+			dependents = append(dependents, expr)
+		}
+	}
+	slices.SortFunc(dependents, func(a, b *resp.Expression) int { return a.ID - b.ID })
+
+	for _, expr := range dependents {
+		cs.logger.Info("starting dependent expression", zap.Int("id", expr.ID), zap.Int("finished_id", finished.ID))
+
+		request := req.ExpressionRequest{
+			Expression:       expr.Expression,
+			Variables:        expr.Variables,
+			Precision:        expr.Precision,
+			Digits:           expr.Digits,
+			NumberMode:       expr.NumberMode,
+			DecimalSeparator: expr.Separator,
+			Locale:           expr.Locale,
+			Format:           expr.Format,
+			NonFinite:        expr.NonFinite,
+			Optimize:         expr.Optimize,
+			Rebalance:        expr.Rebalance,
+		}
+		_, _ = cs.startExpression(expr.ID, request, expr.UserID, expr.References)
+	}
+}
+
+// UserFormat returns the format applied to the results of requests of the
+// user that do not set one.
+func (cs *CalcService) UserFormat(userID uint64) calculation.Format {
+	cs.mutex.RLock()
+	defer cs.mutex.RUnlock()
+
+	return cs.userFormats[userID]
+}
 
-		return id, err
+// SetUserFormat changes the default result format of the user. Results of
+// earlier expressions keep their format.
+func (cs *CalcService) SetUserFormat(userID uint64, format calculation.Format) error {
+	if err := format.Validate(); err != nil {
+		return err
 	}
 
-	return id, nil
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	if format.IsZero() {
+		delete(cs.userFormats, userID)
+	} else {
+		cs.userFormats[userID] = format
+	}
+	return nil
 }
 
 func (cs *CalcService) ListAll(userID uint64) resp.ExpressionList {
@@ -150,6 +308,24 @@ func (cs *CalcService) FindById(exprID int, userID uint64) (*resp.ExpressionUnit
 	return &resp.ExpressionUnit{Expr: *expr}, nil
 }
 
+// FindGraph returns a snapshot of the task graph of an expression.
+func (cs *CalcService) FindGraph(exprID int, userID uint64) (*resp.ExpressionGraph, error) {
+	cs.mutex.RLock()
+	defer cs.mutex.RUnlock()
+
+	expr, found := cs.userExprTable[userID][exprID]
+	if !found {
+		cs.logger.Error("expression not found", zap.Int("id", exprID))
+		return nil, fmt.Errorf("id %d not found", exprID)
+	}
+
+	return &resp.ExpressionGraph{
+		ID:     expr.ID,
+		Status: expr.Status,
+		Graph:  copyGraph(expr.Graph),
+	}, nil
+}
+
 func (cs *CalcService) GetTask(ctx context.Context, _ *emptypb.Empty) (*pb.Task, error) {
 	const defaultTimeout = 10 * time.Second
 
@@ -170,6 +346,12 @@ func (cs *CalcService) GetTask(ctx context.Context, _ *emptypb.Empty) (*pb.Task,
 				defaultTimeout + newtask.OperationTime,
 			)
 
+			agent := ""
+			if p, ok := peer.FromContext(ctx); ok {
+				agent = p.Addr.String()
+			}
+			cs.startTask(newtask, agent)
+
 			go func(task *resp.Task, userID uint64) {
 				cs.mutex.Lock()
 				timeout, found := cs.timeoutsTable[task.ID]
@@ -194,6 +376,12 @@ func (cs *CalcService) GetTask(ctx context.Context, _ *emptypb.Empty) (*pb.Task,
 				Id:            int32(newtask.ID),
 				Arg1:          newtask.Arg1,
 				Arg2:          newtask.Arg2,
+				Args:          newtask.Args,
+				Operands:      numbersOf(newtask.Operands, newtask.Rational),
+				Exact:         newtask.Exact,
+				Digits:        int32(newtask.Digits),
+				Rational:      newtask.Rational,
+				NonFinite:     newtask.NonFinite,
 				Operation:     newtask.Operation,
 				OperationTime: durationpb.New(newtask.OperationTime),
 				UserId:        userID,
@@ -205,6 +393,42 @@ func (cs *CalcService) GetTask(ctx context.Context, _ *emptypb.Empty) (*pb.Task,
 	return nil, status.Error(codes.NotFound, "no tasks available")
 }
 
+func numbersOf(operands []resp.Operand, rational bool) []*pb.Number {
+	numbers := make([]*pb.Number, len(operands))
+	for i, operand := range operands {
+		numbers[i] = &pb.Number{
+			Value:  operand.Value,
+			Exact:  operand.Exact,
+			Imag:   operand.Imag,
+			Matrix: pbMatrix(operand.Matrix),
+			Unit:   calculation.SIUnit(operand.Unit),
+		}
+		if exact, ok := exactOf(operand); ok && rational {
+			numbers[i].Fraction = &pb.Fraction{
+				Numerator:   exact.Num().String(),
+				Denominator: exact.Denom().String(),
+			}
+		}
+	}
+	return numbers
+}
+
+func pbMatrix(m *calculation.Matrix) *pb.Matrix {
+	if m == nil {
+		return nil
+	}
+	return &pb.Matrix{Rows: int32(m.Rows), Cols: int32(m.Cols), Values: m.Values, Vector: m.Vector}
+}
+
+// matrixOf converts a matrix sent by an agent, or returns nil if its values
+// do not fill its shape.
+func matrixOf(m *pb.Matrix) *calculation.Matrix {
+	if m.GetRows() <= 0 || m.GetCols() <= 0 || len(m.GetValues()) != int(m.GetRows()*m.GetCols()) {
+		return nil
+	}
+	return &calculation.Matrix{Rows: int(m.GetRows()), Cols: int(m.GetCols()), Values: m.GetValues(), Vector: m.GetVector()}
+}
+
 func (cs *CalcService) handleTaskTimeout(task *resp.Task, userID uint64) {
 	cs.mutex.Lock()
 	defer cs.mutex.Unlock()
@@ -213,8 +437,16 @@ func (cs *CalcService) handleTaskTimeout(task *resp.Task, userID uint64) {
 		zap.Int("task_id", task.ID),
 		zap.Uint64("userID", userID))
 
-	cs.userTasks[userID] = append(cs.userTasks[userID], task)
 	delete(cs.timeoutsTable, task.ID)
+	if _, found := cs.userTaskTable[userID][task.ID]; !found {
+		// the task was cancelled while the timeout fired
+		return
+	}
+	cs.userTasks[userID] = append(cs.userTasks[userID], task)
+
+	if node := cs.taskNode(task.ID, userID); node != nil && node.Status == NodeRunning {
+		node.Status = NodeQueued
+	}
 }
 
 func (cs *CalcService) SendResult(ctx context.Context, res *pb.Result) (*emptypb.Empty, error) {
@@ -230,51 +462,60 @@ func (cs *CalcService) SendResult(ctx context.Context, res *pb.Result) (*emptypb
 		delete(cs.timeoutsTable, taskID)
 	}
 
-	if _, found := cs.userTaskTable[userID][taskID]; !found {
+	element, found := cs.userTaskTable[userID][taskID]
+	if !found {
 		cs.logger.Warn("task not found", zap.Int("task_id", taskID))
 		return nil, status.Errorf(codes.NotFound, "task id %d not found", taskID)
 	}
 
+	delete(cs.userTaskTable[userID], taskID)
+
+	expr, found := cs.userExprTable[userID][element.ID]
+	if !found || expr.Graph == nil {
+		cs.logger.Warn("expression not found", zap.Int("task_id", taskID))
+		return nil, status.Errorf(codes.NotFound, "expression for task %d not found", taskID)
+	}
+
 	var resultValue interface{}
 	switch v := res.Value.(type) {
 	case *pb.Result_IntResult:
 		resultValue = v.IntResult
 	case *pb.Result_FloatResult:
 		resultValue = v.FloatResult
+	case *pb.Result_ExactResult:
+		resultValue = v.ExactResult
+	case *pb.Result_ComplexResult:
+		resultValue = complex(v.ComplexResult.GetReal(), v.ComplexResult.GetImag())
+	case *pb.Result_RationalResult:
+		resultValue = v.RationalResult.GetNumerator() + "/" + v.RationalResult.GetDenominator()
+	case *pb.Result_MatrixResult:
+		resultValue = matrixOf(v.MatrixResult)
 	case *pb.Result_Error:
-		resultValue = errors.New(v.Error)
+		cs.logger.Info("task failed", zap.Int("task_id", taskID), zap.String("code", res.GetErrorCode()), zap.String("error", v.Error))
+		cs.failTask(expr, taskID, element, res.GetErrorCode(), v.Error)
+		return &emptypb.Empty{}, nil
 	default:
+		// fail the task rather than leave its expression waiting forever
 		cs.logger.Warn("unsupported result type", zap.Any("type", res.Value))
+		cs.failTask(expr, taskID, element, codeInvalidResult, "unsupported result type")
 		return nil, status.Error(codes.InvalidArgument, "unsupported result type")
 	}
 
-	el := cs.userTaskTable[userID][taskID].Ptr
-	exprID := cs.userTaskTable[userID][taskID].ID
-
-	delete(cs.userTaskTable[userID], taskID)
-
-	expr, found := cs.userExprTable[userID][exprID]
-	if !found {
-		cs.logger.Warn("expression not found", zap.Int("task_id", taskID))
-		return nil, status.Errorf(codes.NotFound, "expression for task %d not found", taskID)
+	value, ok := newOperand(resultValue, isExact(expr))
+	if !ok {
+		cs.logger.Warn("non-numeric task result", zap.Int("task_id", taskID), zap.Any("value", resultValue))
+		cs.failTask(expr, taskID, element, codeInvalidResult, fmt.Sprintf("invalid result %v", resultValue))
+		return &emptypb.Empty{}, nil
 	}
+	value.Unit = res.GetUnit()
 
-	if expr.Len() == 1 {
-		expr.Result = fmt.Sprintf("%v", resultValue)
-		expr.Status = StatusDone
-		expr.Remove(el)
-	} else {
-		numToken := NumToken{Value: resultValue.(float64)}
-		expr.InsertBefore(numToken, el)
-		expr.Remove(el)
-
-		cs.extractTasksFromExpression(expr, userID)
-	}
+	cs.complete(expr, element, value)
 
 	return &emptypb.Empty{}, nil
 }
 
-func (cs *CalcService) GetTaskUser(userID uint64) *resp.Task {
+// GetTaskUser hands the next task of userID to agent.
+func (cs *CalcService) GetTaskUser(userID uint64, agent string) *resp.Task {
 	const defaultTimeout = 10 * time.Second
 
 	cs.mutex.Lock()
@@ -292,8 +533,9 @@ func (cs *CalcService) GetTaskUser(userID uint64) *resp.Task {
 		cs.timeoutsTable[newtask.ID] = timeout.NewTimeout(
 			defaultTimeout + newtask.OperationTime,
 		)
+		cs.startTask(newtask, agent)
 
-		go func(task resp.Task) {
+		go func(task *resp.Task) {
 			cs.mutex.Lock()
 			timeout, found := cs.timeoutsTable[task.ID]
 			cs.mutex.Unlock()
@@ -304,19 +546,14 @@ func (cs *CalcService) GetTaskUser(userID uint64) *resp.Task {
 
 			select {
 			case <-timeout.Timer.C:
-				cs.logger.Info("task timeout has been reached",
-					zap.Int("task_id", task.ID),
-					zap.Uint64("userID", userID))
-				cs.mutex.Lock()
-				cs.userTasks[userID] = append(cs.userTasks[userID], &task)
-				cs.mutex.Unlock()
+				cs.handleTaskTimeout(task, userID)
 			case <-timeout.Ctx.Done():
 				cs.logger.Info("task completed before timeout",
 					zap.Int("task_id", task.ID),
 					zap.Uint64("userID", userID))
 				return
 			}
-		}(*newtask)
+		}(newtask)
 
 		return newtask
 	}
@@ -333,6 +570,7 @@ func (cs *CalcService) PutResultUser(id int, value any, userID uint64) error {
 	if found {
 		cs.logger.Info("cancelling timeout for task", zap.Int("task_id", id))
 		timeout.Cancel()
+		delete(cs.timeoutsTable, id)
 	}
 
 	_, found = cs.userTaskTable[userID][id]
@@ -341,109 +579,522 @@ func (cs *CalcService) PutResultUser(id int, value any, userID uint64) error {
 		return fmt.Errorf("task id %d not found", id)
 	}
 
-	el := cs.userTaskTable[userID][id].Ptr
-	exprID := cs.userTaskTable[userID][id].ID
+	element := cs.userTaskTable[userID][id]
 
 	cs.logger.Info("deleting task from task table", zap.Int("task_id", id))
 
 	delete(cs.userTaskTable[userID], id)
 
-	expr, found := cs.userExprTable[userID][exprID]
-	if !found {
+	expr, found := cs.userExprTable[userID][element.ID]
+	if !found || expr.Graph == nil {
 		cs.logger.Warn("expression for task %d not found", zap.Int("task_id", id))
 		return fmt.Errorf("expression for task %d not found", id)
 	}
 
-	if expr.Len() == 1 {
-		expr.Result = fmt.Sprintf("%g", value)
-		expr.Status = StatusDone
-		expr.Remove(el)
-	} else {
-		numToken := NumToken{value.(float64)}
-		expr.InsertBefore(numToken, el)
-		expr.Remove(el)
+	result, ok := newOperand(value, isExact(expr))
+	if !ok {
+		cs.logger.Warn("invalid task result", zap.Int("task_id", id), zap.Any("value", value))
+		// fail the task rather than leave its expression waiting forever
+		cs.failTask(expr, id, element, codeInvalidResult, fmt.Sprintf("invalid result %v", value))
+		return fmt.Errorf("invalid result for task %d", id)
+	}
+
+	cs.complete(expr, element, result)
+
+	return nil
+}
+
+// FailTaskUser fails the expression of task id with the error an agent
+// reported for it over HTTP.
+func (cs *CalcService) FailTaskUser(id int, code, message string, userID uint64) error {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
 
-		cs.extractTasksFromExpression(expr, userID)
+	if timeout, found := cs.timeoutsTable[id]; found {
+		timeout.Cancel()
+		delete(cs.timeoutsTable, id)
+	}
+
+	element, found := cs.userTaskTable[userID][id]
+	if !found {
+		cs.logger.Warn("task not found", zap.Int("task_id", id))
+		return fmt.Errorf("task id %d not found", id)
+	}
+
+	delete(cs.userTaskTable[userID], id)
+
+	expr, found := cs.userExprTable[userID][element.ID]
+	if !found || expr.Graph == nil {
+		cs.logger.Warn("expression not found", zap.Int("task_id", id))
+		return fmt.Errorf("expression for task %d not found", id)
 	}
 
+	cs.failTask(expr, id, element, code, message)
+
 	return nil
 }
 
-func (cs *CalcService) extractTasksFromExpression(expr *resp.Expression, userID uint64) int {
-	cs.logger.Info("extracting tasks from expression", zap.Int("expr_id", expr.ID), zap.Uint64("user_id", userID))
+// activate marks node as needed and schedules it. Only the condition of an
+// if() is needed right away; its branches wait for the condition.
+func (cs *CalcService) activate(expr *resp.Expression, node *resp.GraphNode) {
+	if node.Status != NodeIdle && node.Status != NodeSkipped {
+		return
+	}
+	node.Status = NodePending
+	// the statistics count the operations an expression needs, however
+	// many tasks they take and whether the cache answers them
+	if _, ok := cs.Operations[node.Operation]; ok {
+		cs.Operations[node.Operation]++
+	}
+
+	inputs := node.Inputs
+	if node.Operation == calculation.If {
+		inputs = inputs[:1]
+	}
+	for _, input := range inputs {
+		cs.activate(expr, expr.Graph.Nodes[input])
+	}
+
+	cs.advance(expr, node)
+}
+
+// advance enqueues a pending node whose inputs are all done.
+func (cs *CalcService) advance(expr *resp.Expression, node *resp.GraphNode) {
+	if node.Status != NodePending || expr.Status != StatusWaiting {
+		return
+	}
+	nodes := expr.Graph.Nodes
+
+	if node.Operation == calculation.If {
+		condition := nodes[node.Inputs[0]]
+		if condition.Status != NodeDone {
+			return
+		}
+
+		chosen, other := nodes[node.Inputs[1]], nodes[node.Inputs[2]]
+		if !isTrue(condition.Value) {
+			chosen, other = other, chosen
+		}
+		cs.skip(expr, other)
+		cs.activate(expr, chosen)
+
+		if chosen.Status == NodeDone {
+			node.Boolean = chosen.Boolean
+			cs.finish(expr, node, chosen.Value)
+		}
+		return
+	}
+
+	operands := make([]resp.Operand, len(node.Inputs))
+	for i, input := range node.Inputs {
+		if nodes[input].Status != NodeDone {
+			return
+		}
+		operands[i] = nodes[input].Value
+	}
+
+	// a conversion only changes the unit the quantity is shown in
+	if node.Operation == calculation.ConvertTo {
+		value, target := operands[0], operands[1]
+		if calculation.SIUnit(value.Unit) != calculation.SIUnit(target.Unit) {
+			cs.fail(expr, node, fmt.Sprintf("%v: %s cannot be converted to %s", calculation.ErrDimensionMismatch, formatOperand(value), target.Unit))
+			return
+		}
+		value.Unit = target.Unit
+		cs.finish(expr, node, value)
+		return
+	}
+
+	cs.addTask(expr, node, operands)
+}
+
+// skip marks the idle nodes of a branch that is not taken.
+func (cs *CalcService) skip(expr *resp.Expression, node *resp.GraphNode) {
+	if node.Status != NodeIdle {
+		return
+	}
+	node.Status = NodeSkipped
+
+	for _, input := range node.Inputs {
+		cs.skip(expr, expr.Graph.Nodes[input])
+	}
+}
+
+// finish stores the result of node and advances the nodes waiting for it.
+func (cs *CalcService) finish(expr *resp.Expression, node *resp.GraphNode, value resp.Operand) {
+	now := time.Now()
+	node.Status = NodeDone
+	node.Value = value
+	node.FinishedAt = &now
+	node.Result = formatOperand(value)
+	if node.Boolean {
+		node.Result = formatResult(expr, node, calculation.Format{})
+	}
+
+	if node.ID == expr.Graph.Root {
+		if expr.Status == StatusWaiting {
+			setResult(expr, node)
+			cs.logger.Info("expression calculated", zap.Int("expr_id", expr.ID), zap.String("result", expr.Result))
+			cs.resolveDependents(expr)
+		}
+		return
+	}
+
+	for _, dependent := range node.Dependents {
+		cs.advance(expr, expr.Graph.Nodes[dependent])
+	}
+}
+
+// fail marks node and the whole expression as failed with message and
+// cancels the other tasks of the expression.
+func (cs *CalcService) fail(expr *resp.Expression, node *resp.GraphNode, message string) {
+	now := time.Now()
+	node.Status = NodeError
+	node.Result = message
+	node.FinishedAt = &now
+
+	if expr.Status == StatusWaiting {
+		expr.Result = message
+		expr.Status = StatusError
+		cs.cancelTasks(expr)
+		cs.resolveDependents(expr)
+	}
+}
+
+// failTask fails expr with the error reason an agent reported for task
+// taskID. Agents that do not classify their errors report no code.
+func (cs *CalcService) failTask(expr *resp.Expression, taskID int, element ExprElement, code, reason string) {
+	node := expr.Graph.Nodes[element.Node]
+	if node.Status == NodeError || node.Status == NodeDone {
+		return
+	}
+	if code == "" {
+		code = codeAgentError
+	}
+
+	if expr.Status == StatusWaiting {
+		args := make([]string, len(node.Inputs))
+		for i, input := range node.Inputs {
+			args[i] = formatOperand(expr.Graph.Nodes[input].Value)
+		}
+		expr.FailedTask = &resp.FailedTask{
+			ID:        taskID,
+			Node:      node.ID,
+			Operation: node.Operation,
+			Args:      args,
+			Agent:     node.Agent,
+			Code:      code,
+			Reason:    reason,
+		}
+	}
+
+	cs.fail(expr, node, failure(node, reason))
+}
+
+// cancelTasks removes the queued and running tasks of expr, so that agents
+// do not compute results nobody waits for. Results that still arrive for
+// them are rejected as unknown tasks.
+func (cs *CalcService) cancelTasks(expr *resp.Expression) {
+	userID := expr.UserID
+
+	cancelled := make(map[int]bool)
+	for taskID, element := range cs.userTaskTable[userID] {
+		if element.ID != expr.ID {
+			continue
+		}
+		cancelled[taskID] = true
+		delete(cs.userTaskTable[userID], taskID)
+
+		if timeout, found := cs.timeoutsTable[taskID]; found {
+			timeout.Cancel()
+			delete(cs.timeoutsTable, taskID)
+		}
+		if node := expr.Graph.Nodes[element.Node]; node.Status == NodeQueued || node.Status == NodeRunning {
+			node.Status = NodeCancelled
+		}
+	}
+	if len(cancelled) == 0 {
+		return
+	}
+
+	cs.userTasks[userID] = slices.DeleteFunc(cs.userTasks[userID], func(task *resp.Task) bool {
+		return cancelled[task.ID]
+	})
+	cs.logger.Info("tasks cancelled", zap.Int("expr_id", expr.ID), zap.Int("count", len(cancelled)))
+}
 
-	var taskCount int
-	el := expr.Front()
+func (cs *CalcService) addTask(expr *resp.Expression, node *resp.GraphNode, operands []resp.Operand) {
+	userID := expr.UserID
 
 	if _, ok := cs.userTaskTable[userID]; !ok {
-		cs.logger.Debug("creating user task table entry", zap.Uint64("user_id", userID))
 		cs.userTaskTable[userID] = make(map[int]ExprElement)
 	}
-	if _, ok := cs.userTasks[userID]; !ok {
-		cs.logger.Debug("creating user tasks slice entry", zap.Uint64("user_id", userID))
-		cs.userTasks[userID] = make([]*resp.Task, 0)
+
+	args := make([]string, len(operands))
+	for i, operand := range operands {
+		args[i] = formatOperand(operand)
 	}
 
-	for el != nil {
-		el1 := el
-		if el1.Value.(Token).Type() != TokenTypeNumber {
-			cs.logger.Debug("skipping non-number token", zap.Any("token", el1.Value), zap.Int("expr_id", expr.ID))
-			el = el.Next()
-			continue
+	task := &resp.Task{
+		ID:            cs.taskID,
+		Args:          args,
+		Operands:      operands,
+		Operation:     node.Operation,
+		OperationTime: cs.timeTable[node.Operation] / 1e6,
+		UserID:        userID,
+		Arg1:          args[0],
+		Exact:         isExact(expr),
+		Digits:        expr.Digits,
+		Rational:      isRational(expr),
+		NonFinite:     expr.NonFinite,
+	}
+	if len(args) > 1 {
+		task.Arg2 = args[1]
+	}
+
+	if cs.splitProduct(expr, node, task) {
+		return
+	}
+
+	unit, err := resultUnit(node.Operation, operands)
+	if err != nil {
+		cs.fail(expr, node, err.Error())
+		return
+	}
+
+	// matrices would make huge keys and are rarely repeated
+	key := ""
+	if !hasMatrixOperand(operands) {
+		key = taskKey(task)
+	}
+	if cs.cache != nil && key != "" {
+		if value, ok := cs.cache.get(key); ok {
+			cs.logger.Info("task result found in cache", zap.Int("expr_id", expr.ID), zap.String("task", key))
+			node.Cached = true
+			if value, ok = cs.finite(expr, node, value); ok {
+				cs.finish(expr, node, value)
+			}
+			return
 		}
+	}
 
-		el2 := el1.Next()
-		if el2 == nil || el2.Value.(Token).Type() != TokenTypeNumber {
-			cs.logger.Debug("skipping, second token is not a number or end of list", zap.Any("token", el2.Value), zap.Int("expr_id", expr.ID))
-			el = el.Next()
-			continue
+	now := time.Now()
+	taskID := task.ID
+	node.Status = NodeQueued
+	node.TaskID = &taskID
+	node.QueuedAt = &now
+
+	cs.enqueue(expr, task, ExprElement{
+		ID:     expr.ID,
+		Node:   node.ID,
+		UserID: userID,
+		Key:    key,
+		Unit:   unit,
+	})
+}
+
+// resultUnit returns the unit of the result of operation on operands, or
+// "" when none of them carries one.
+func resultUnit(operation string, operands []resp.Operand) (string, error) {
+	values := make([]float64, len(operands))
+	units := make([]string, len(operands))
+	hasUnit := false
+	for i, operand := range operands {
+		values[i], units[i] = operand.Value, operand.Unit
+		hasUnit = hasUnit || operand.Unit != ""
+	}
+	if !hasUnit {
+		return "", nil
+	}
+	return calculation.ResultUnit(operation, values, units)
+}
+
+func (cs *CalcService) enqueue(expr *resp.Expression, task *resp.Task, element ExprElement) {
+	userID := expr.UserID
+
+	cs.userTaskTable[userID][task.ID] = element
+	cs.userTasks[userID] = append(cs.userTasks[userID], task)
+
+	cs.taskID++
+
+	cs.logger.Info("new task created",
+		zap.Int("task_id", task.ID),
+		zap.Uint64("user_id", userID),
+		zap.Int("expr_id", expr.ID),
+		zap.String("operation", task.Operation))
+}
+
+// splitProduct turns the product of two matrices larger than the block size
+// into one task per block of rows of the left matrix and block of columns of
+// the right one, so that agents compute the blocks in parallel.
+func (cs *CalcService) splitProduct(expr *resp.Expression, node *resp.GraphNode, task *resp.Task) bool {
+	size := cs.cfg.MatrixBlockSize
+	if task.Operation != "*" || size <= 0 {
+		return false
+	}
+	a, b := task.Operands[0].Matrix, task.Operands[1].Matrix
+	if a == nil || b == nil || a.Vector || b.Vector || a.Cols != b.Rows || a.Rows <= size && b.Cols <= size {
+		return false
+	}
+
+	now := time.Now()
+	firstID := cs.taskID
+	node.Status = NodeQueued
+	node.TaskID = &firstID
+	node.QueuedAt = &now
+	node.Value = resp.Operand{Matrix: calculation.NewMatrix(a.Rows, b.Cols)}
+	node.Blocks, node.Remaining = 0, 0
+
+	for row := 0; row < a.Rows; row += size {
+		rows := a.Block(row, min(row+size, a.Rows), 0, a.Cols)
+		for col := 0; col < b.Cols; col += size {
+			cols := b.Block(0, b.Rows, col, min(col+size, b.Cols))
+
+			block := *task
+			block.ID = cs.taskID
+			block.Operands = []resp.Operand{{Matrix: rows}, {Matrix: cols}}
+			block.Args = []string{rows.String(), cols.String()}
+			block.Arg1, block.Arg2 = block.Args[0], block.Args[1]
+
+			node.Blocks++
+			node.Remaining++
+			cs.enqueue(expr, &block, ExprElement{
+				ID:      expr.ID,
+				Node:    node.ID,
+				UserID:  expr.UserID,
+				Blocked: true,
+				Row:     row,
+				Col:     col,
+			})
 		}
+	}
 
-		op := el2.Next()
-		if op == nil || op.Value.(Token).Type() != TokenTypeOperation {
-			cs.logger.Debug("skipping, third token is not an operation or end of list", zap.Any("token", op.Value), zap.Int("expr_id", expr.ID))
-			el = el.Next()
-			continue
+	return true
+}
+
+func hasMatrixOperand(operands []resp.Operand) bool {
+	for _, operand := range operands {
+		if operand.Matrix != nil {
+			return true
 		}
+	}
+	return false
+}
 
-		task := &resp.Task{
-			ID:            cs.taskID,
-			Arg1:          fmt.Sprintf("%f", el1.Value.(NumToken).Value),
-			Arg2:          fmt.Sprintf("%f", el2.Value.(NumToken).Value),
-			Operation:     op.Value.(OpToken).Value,
-			OperationTime: cs.timeTable[op.Value.(OpToken).Value] / 1e6,
-			UserID:        userID,
+// complete stores the result of the task of element. The blocks of a split
+// product are assembled into the node value, which is finished with the
+// last block.
+func (cs *CalcService) complete(expr *resp.Expression, element ExprElement, value resp.Operand) {
+	node := expr.Graph.Nodes[element.Node]
+	if node.Status == NodeError || node.Status == NodeDone {
+		return
+	}
+	value, ok := cs.finite(expr, node, value)
+	if !ok {
+		return
+	}
+
+	if !element.Blocked {
+		if got, want := calculation.SIUnit(value.Unit), calculation.SIUnit(element.Unit); value.Unit != "" && got != want {
+			cs.fail(expr, node, fmt.Sprintf("%v: agent returned %s, expected %s", calculation.ErrDimensionMismatch, got, want))
+			return
 		}
+		value.Unit = element.Unit
+		cs.remember(element, value)
+		cs.finish(expr, node, value)
+		return
+	}
 
-		taskElement := expr.InsertBefore(&TaskToken{ID: task.ID}, el)
+	product, block := node.Value.Matrix, value.Matrix
+	if block == nil || element.Row+block.Rows > product.Rows || element.Col+block.Cols > product.Cols {
+		cs.fail(expr, node, fmt.Sprintf("invalid block result %s", formatOperand(value)))
+		return
+	}
+	product.SetBlock(element.Row, element.Col, block)
+
+	node.Remaining--
+	if node.Remaining == 0 {
+		cs.finish(expr, node, node.Value)
+	}
+}
 
-		cs.userTaskTable[userID][task.ID] = ExprElement{
-			ID:     expr.ID,
-			Ptr:    taskElement,
-			UserID: userID,
+// finite applies the non-finite policy of expr to a task result, which
+// agents that predate the policy do not. It fails node when the policy
+// rejects the value.
+func (cs *CalcService) finite(expr *resp.Expression, node *resp.GraphNode, value resp.Operand) (resp.Operand, bool) {
+	if value.Exact != "" {
+		return value, true
+	}
+
+	var err error
+	check := func(x float64) float64 {
+		x, xErr := calculation.ApplyNonFinite(expr.NonFinite, x)
+		if err == nil {
+			err = xErr
+		}
+		return x
+	}
+	value.Value, value.Imag = check(value.Value), check(value.Imag)
+	// the matrix may be shared with a cache entry, so it is copied
+	if value.Matrix != nil {
+		matrix := *value.Matrix
+		matrix.Values = make([]float64, len(value.Matrix.Values))
+		for i, x := range value.Matrix.Values {
+			matrix.Values[i] = check(x)
 		}
+		value.Matrix = &matrix
+	}
+
+	if err != nil {
+		cs.fail(expr, node, failure(node, err))
+		return value, false
+	}
+	return value, true
+}
 
-		cs.userTasks[userID] = append(cs.userTasks[userID], task)
+// failure describes why the task of node failed, naming the subexpression
+// it computes.
+func failure(node *resp.GraphNode, reason any) string {
+	if node.Source == "" {
+		return fmt.Sprint(reason)
+	}
+	return fmt.Sprintf("%v in %s", reason, node.Source)
+}
+
+// remember stores the result of a finished task in the result cache.
+func (cs *CalcService) remember(element ExprElement, value resp.Operand) {
+	if cs.cache != nil && element.Key != "" {
+		cs.cache.put(element.Key, value)
+	}
+}
 
-		cs.taskID++
-		taskCount++
+// startTask records that agent has taken task.
+func (cs *CalcService) startTask(task *resp.Task, agent string) {
+	node := cs.taskNode(task.ID, task.UserID)
+	if node == nil {
+		return
+	}
 
-		el = op.Next()
-		expr.Remove(el1)
-		expr.Remove(el2)
-		expr.Remove(op)
+	now := time.Now()
+	node.Status = NodeRunning
+	node.Agent = agent
+	node.StartedAt = &now
+}
 
-		cs.logger.Info("new task created",
-			zap.Int("task_id", task.ID),
-			zap.Uint64("user_id", userID),
-			zap.Int("expr_id", expr.ID),
-			zap.String("operation", task.Operation))
+func (cs *CalcService) taskNode(taskID int, userID uint64) *resp.GraphNode {
+	element, found := cs.userTaskTable[userID][taskID]
+	if !found {
+		return nil
 	}
 
-	cs.logger.Info("finished extracting tasks from expression", zap.Int("expr_id", expr.ID), zap.Uint64("user_id", userID), zap.Int("task_count", taskCount))
+	expr, found := cs.userExprTable[userID][element.ID]
+	if !found || expr.Graph == nil {
+		return nil
+	}
 
-	return taskCount
+	return expr.Graph.Nodes[element.Node]
 }
+
 func (cs *CalcService) GetOperationCount(operation string) int {
 	cs.mutex.Lock()
 	defer cs.mutex.Unlock()
@@ -457,17 +1108,26 @@ func (cs *CalcService) GetOperationCount(operation string) int {
 	return count
 }
 
-func extractOperations(expression string) []string {
-	operators := []string{"+", "-", "*", "/"}
-	foundOperators := []string{}
+func (cs *CalcService) GetOperationsCount() map[string]int {
+	cs.mutex.RLock()
+	defer cs.mutex.RUnlock()
 
-	for _, char := range expression {
-		for _, op := range operators {
-			if string(char) == op {
-				foundOperators = append(foundOperators, op)
-			}
-		}
+	operations := make(map[string]int, len(cs.Operations))
+	for op, count := range cs.Operations {
+		operations[op] = count
 	}
 
-	return foundOperators
+	return operations
+}
+
+// CacheStatistics reports the result cache counters, or nil when the cache
+// is disabled.
+func (cs *CalcService) CacheStatistics() *resp.CacheStatistics {
+	cs.mutex.RLock()
+	defer cs.mutex.RUnlock()
+
+	if cs.cache == nil {
+		return nil
+	}
+	return cs.cache.statistics()
 }
@@ -0,0 +1,1055 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DobryySoul/orchestrator/internal/config"
+	"github.com/DobryySoul/orchestrator/internal/controllers/http/models/req"
+	"github.com/DobryySoul/orchestrator/internal/controllers/http/models/resp"
+	pb "github.com/DobryySoul/orchestrator/pkg/api/v1"
+	"github.com/DobryySoul/orchestrator/pkg/calculation"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func newTestCalcService() *CalcService {
+	return NewCalcService(&config.Config{ExactDigits: 50}, zap.NewNop())
+}
+
+// distributed keeps constant parts of an expression for the agents.
+var distributed = new(bool)
+
+func TestGetTaskLosslessOperands(t *testing.T) {
+	tests := []struct {
+		name string
+		x, y float64
+	}{
+		{"tiny", 1e-9, 3},
+		{"huge", 1e300, 2},
+		{"negative", -0.000001234, -987654321.123456789},
+		{"smallest subnormal", math.SmallestNonzeroFloat64, 1},
+		{"max float", math.MaxFloat64, 0.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := newTestCalcService()
+
+			_, err := cs.AddExpression(req.ExpressionRequest{
+				Expression: "x * y",
+				Variables:  map[string]float64{"x": tt.x, "y": tt.y},
+			}, 1)
+			if err != nil {
+				t.Fatalf("AddExpression() error = %v", err)
+			}
+
+			task, err := cs.GetTask(context.Background(), &emptypb.Empty{})
+			if err != nil {
+				t.Fatalf("GetTask() error = %v", err)
+			}
+
+			if len(task.Operands) != 2 {
+				t.Fatalf("GetTask() operands = %v, want 2", task.Operands)
+			}
+
+			for i, want := range []float64{tt.x, tt.y} {
+				if got := task.Operands[i].Value; math.Float64bits(got) != math.Float64bits(want) {
+					t.Errorf("operand %d = %v, want %v", i, got, want)
+				}
+
+				parsed, err := strconv.ParseFloat(task.Args[i], 64)
+				if err != nil || parsed != want {
+					t.Errorf("arg %d = %q, want %v", i, task.Args[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestGetTaskExactOperands(t *testing.T) {
+	cs := newTestCalcService()
+
+	_, err := cs.AddExpression(req.ExpressionRequest{
+		Expression: "0.1 + 10000000000000000000001",
+		Precision:  "exact",
+		Optimize:   distributed,
+	}, 1)
+	if err != nil {
+		t.Fatalf("AddExpression() error = %v", err)
+	}
+
+	task, err := cs.GetTask(context.Background(), &emptypb.Empty{})
+	if err != nil {
+		t.Fatalf("GetTask() error = %v", err)
+	}
+
+	if !task.Exact || task.Digits != 50 {
+		t.Errorf("GetTask() exact = %v, digits = %d", task.Exact, task.Digits)
+	}
+	if task.Operands[0].Exact != "1/10" || task.Operands[1].Exact != "10000000000000000000001" {
+		t.Errorf("GetTask() exact operands = %q, %q", task.Operands[0].Exact, task.Operands[1].Exact)
+	}
+}
+
+// completeTasks plays the agent for userID until no tasks are left and
+// returns the operations it has executed.
+func completeTasks(t *testing.T, cs *CalcService, userID uint64) []string {
+	t.Helper()
+
+	var operations []string
+	for task := cs.GetTaskUser(userID, "test"); task != nil; task = cs.GetTaskUser(userID, "test") {
+		a, _ := strconv.ParseFloat(task.Args[0], 64)
+		b, _ := strconv.ParseFloat(task.Arg2, 64)
+
+		var result float64
+		switch task.Operation {
+		case "+":
+			result = a + b
+		case "-":
+			result = a - b
+		case "*":
+			result = a * b
+		case "/":
+			result = a / b
+		case ">":
+			if a > b {
+				result = 1
+			}
+		case "!=":
+			if a != b {
+				result = 1
+			}
+		default:
+			t.Fatalf("unexpected operation %q", task.Operation)
+		}
+
+		if err := cs.PutResultUser(task.ID, result, userID); err != nil {
+			t.Fatalf("PutResultUser() error = %v", err)
+		}
+		operations = append(operations, task.Operation)
+	}
+
+	return operations
+}
+
+func TestReferences(t *testing.T) {
+	cs := newTestCalcService()
+
+	add := func(expression string) (int, error) {
+		return cs.AddExpression(req.ExpressionRequest{Expression: expression, Optimize: distributed}, 1)
+	}
+	find := func(id int) resp.Expression {
+		unit, err := cs.FindById(id, 1)
+		if err != nil {
+			t.Fatalf("FindById(%d) error = %v", id, err)
+		}
+		return unit.Expr
+	}
+
+	if _, err := add("ans + 1"); !errors.Is(err, calculation.ErrUnknownReference) {
+		t.Errorf("ans without history: error = %v, want %v", err, calculation.ErrUnknownReference)
+	}
+
+	first, _ := add("2 + 3")
+	second, err := add(fmt.Sprintf("$%d * 2", first))
+	if err != nil {
+		t.Fatalf("AddExpression() error = %v", err)
+	}
+	if expr := find(second); expr.Status != StatusWaiting || !slices.Equal(expr.WaitingOn, []int{first}) {
+		t.Errorf("dependent expression = %s waiting on %v, want waiting on [%d]", expr.Status, expr.WaitingOn, first)
+	}
+
+	completeTasks(t, cs, 1)
+
+	if expr := find(second); expr.Status != StatusDone || expr.Result != "10" {
+		t.Errorf("dependent expression = %s %q, want Done 10", expr.Status, expr.Result)
+	}
+
+	third, _ := add("ans * tau / pi")
+	completeTasks(t, cs, 1)
+	if expr := find(third); expr.Status != StatusDone || expr.Result != "20" || expr.References["ans"] != "10" {
+		t.Errorf("ans expression = %s %q with %v, want Done 20", expr.Status, expr.Result, expr.References)
+	}
+
+	// a comparison is referred to as 1 or 0
+	if _, err := add("2 > 1"); err != nil {
+		t.Fatalf("AddExpression() error = %v", err)
+	}
+	completeTasks(t, cs, 1)
+	boolean, err := add("ans + 1")
+	if err != nil {
+		t.Fatalf("reference to a comparison: error = %v", err)
+	}
+	completeTasks(t, cs, 1)
+	if expr := find(boolean); expr.Status != StatusDone || expr.Result != "2" {
+		t.Errorf("reference to a comparison = %s %q, want Done 2", expr.Status, expr.Result)
+	}
+
+	// a matrix result is referred to as the matrix itself
+	if _, err := add("[1, 2] + [1, 1]"); err != nil {
+		t.Fatalf("AddExpression() error = %v", err)
+	}
+	task := cs.GetTaskUser(1, "test")
+	if task == nil {
+		t.Fatal("GetTaskUser() = nil, want the matrix sum")
+	}
+	if err := cs.PutResultUser(task.ID, "[2,3]", 1); err != nil {
+		t.Fatalf("PutResultUser() error = %v", err)
+	}
+	matrix, err := add("ans * 2")
+	if err != nil {
+		t.Fatalf("reference to a matrix: error = %v", err)
+	}
+	task = cs.GetTaskUser(1, "test")
+	if task == nil || task.Operation != "*" || task.Args[0] != "[2,3]" {
+		t.Fatalf("task = %+v, want [2,3] * 2", task)
+	}
+	if err := cs.PutResultUser(task.ID, "[4,6]", 1); err != nil {
+		t.Fatalf("PutResultUser() error = %v", err)
+	}
+	if expr := find(matrix); expr.Status != StatusDone || expr.Result != "[4,6]" {
+		t.Errorf("reference to a matrix = %s %q, want Done [4,6]", expr.Status, expr.Result)
+	}
+
+	failed, _ := add("1 / 0")
+	if _, err := add(fmt.Sprintf("$%d + 1", failed)); !errors.Is(err, calculation.ErrFailedReference) {
+		t.Errorf("reference to failed expression: error = %v, want %v", err, calculation.ErrFailedReference)
+	}
+	if _, err := add("$99 + 1"); !errors.Is(err, calculation.ErrUnknownReference) {
+		t.Errorf("reference to missing expression: error = %v, want %v", err, calculation.ErrUnknownReference)
+	}
+}
+
+func TestConditional(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		x          float64
+		operations []string
+		want       string
+	}{
+		{"then branch", "if(x > 10, x * 0.9, x - 1)", 20, []string{">", "*"}, "18"},
+		{"else branch", "if(x > 10, x * 0.9, x - 1)", 5, []string{">", "-"}, "4"},
+		{"skipped division by zero", "if(x != 0, 1 / x, 0)", 0, []string{"!="}, "0"},
+		{"nested", "if(x > 1, if(x > 10, 2, 3) + 1, 0)", 5, []string{">", ">", "+"}, "4"},
+		{"constant condition", "if(1, 2 + 3, x / 0)", 0, []string{"+"}, "5"},
+		{"boolean result", "x > 10", 20, []string{">"}, "true"},
+		{"boolean false", "x > 10", 5, []string{">"}, "false"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := newTestCalcService()
+
+			id, err := cs.AddExpression(req.ExpressionRequest{
+				Expression: tt.expression,
+				Variables:  map[string]float64{"x": tt.x},
+				Optimize:   distributed,
+			}, 1)
+			if err != nil {
+				t.Fatalf("AddExpression() error = %v", err)
+			}
+
+			if operations := completeTasks(t, cs, 1); !slices.Equal(operations, tt.operations) {
+				t.Errorf("dispatched operations = %v, want %v", operations, tt.operations)
+			}
+
+			unit, err := cs.FindById(id, 1)
+			if err != nil {
+				t.Fatalf("FindById() error = %v", err)
+			}
+			if unit.Expr.Status != StatusDone || unit.Expr.Result != tt.want {
+				t.Errorf("expression = %s %q, want Done %q", unit.Expr.Status, unit.Expr.Result, tt.want)
+			}
+		})
+	}
+}
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		name            string
+		optimize        *bool
+		operations      []string
+		simplifications int
+	}{
+		{"optimized", nil, []string{"+"}, 3},
+		{"distributed", distributed, []string{"*", "*", "+", "+"}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := newTestCalcService()
+
+			id, err := cs.AddExpression(req.ExpressionRequest{
+				Expression: "2*3 + x*1 + 0",
+				Variables:  map[string]float64{"x": 4},
+				Optimize:   tt.optimize,
+			}, 1)
+			if err != nil {
+				t.Fatalf("AddExpression() error = %v", err)
+			}
+
+			if operations := completeTasks(t, cs, 1); !slices.Equal(operations, tt.operations) {
+				t.Errorf("dispatched operations = %v, want %v", operations, tt.operations)
+			}
+
+			unit, _ := cs.FindById(id, 1)
+			if unit.Expr.Status != StatusDone || unit.Expr.Result != "10" {
+				t.Errorf("expression = %s %q, want Done 10", unit.Expr.Status, unit.Expr.Result)
+			}
+			if len(unit.Expr.Simplifications) != tt.simplifications {
+				t.Errorf("simplifications = %v, want %d", unit.Expr.Simplifications, tt.simplifications)
+			}
+		})
+	}
+
+	cs := newTestCalcService()
+	id, _ := cs.AddExpression(req.ExpressionRequest{Expression: "2 < 3"}, 1)
+	if unit, _ := cs.FindById(id, 1); unit.Expr.Status != StatusDone || unit.Expr.Result != "true" {
+		t.Errorf("folded comparison = %s %q, want Done true", unit.Expr.Status, unit.Expr.Result)
+	}
+}
+
+// chain is a sum of eight variables, a left-deep chain of seven additions.
+var chain = req.ExpressionRequest{
+	Expression: "a + b + c + d + w + x + y + z",
+	Variables:  map[string]float64{"a": 1, "b": 2, "c": 3, "d": 4, "w": 5, "x": 6, "y": 7, "z": 8},
+}
+
+func TestRebalance(t *testing.T) {
+	tests := []struct {
+		name      string
+		rebalance *bool
+		ready     int
+	}{
+		{"balanced", nil, 4},
+		{"strict order", new(bool), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := newTestCalcService()
+
+			request := chain
+			request.Rebalance = tt.rebalance
+			id, err := cs.AddExpression(request, 1)
+			if err != nil {
+				t.Fatalf("AddExpression() error = %v", err)
+			}
+
+			if ready := len(cs.userTasks[1]); ready != tt.ready {
+				t.Errorf("tasks ready after start = %d, want %d", ready, tt.ready)
+			}
+
+			completeTasks(t, cs, 1)
+			if unit, _ := cs.FindById(id, 1); unit.Expr.Status != StatusDone || unit.Expr.Result != "36" {
+				t.Errorf("expression = %s %q, want Done 36", unit.Expr.Status, unit.Expr.Result)
+			}
+		})
+	}
+}
+
+func TestCommonSubexpressions(t *testing.T) {
+	cs := newTestCalcService()
+
+	id, err := cs.AddExpression(req.ExpressionRequest{
+		Expression: "(a+b)*(a+b) - (a+b)/2",
+		Variables:  map[string]float64{"a": 3, "b": 5},
+	}, 1)
+	if err != nil {
+		t.Fatalf("AddExpression() error = %v", err)
+	}
+
+	operations := completeTasks(t, cs, 1)
+	if want := []string{"+", "*", "/", "-"}; !slices.Equal(operations, want) {
+		t.Errorf("executed operations = %v, want %v", operations, want)
+	}
+
+	unit, _ := cs.FindById(id, 1)
+	if unit.Expr.Status != StatusDone || unit.Expr.Result != "60" {
+		t.Errorf("expression = %s %q, want Done 60", unit.Expr.Status, unit.Expr.Result)
+	}
+	if unit.Expr.SavedTasks != 2 {
+		t.Errorf("SavedTasks = %d, want 2", unit.Expr.SavedTasks)
+	}
+}
+
+func TestCachedResults(t *testing.T) {
+	cs := NewCalcService(&config.Config{
+		ExactDigits: 50,
+		CacheConfig: config.CacheConfig{Enabled: true, Size: 16},
+	}, zap.NewNop())
+
+	if _, err := cs.AddExpression(req.ExpressionRequest{
+		Expression: "x * y",
+		Variables:  map[string]float64{"x": 2, "y": 3},
+	}, 1); err != nil {
+		t.Fatalf("AddExpression() error = %v", err)
+	}
+	completeTasks(t, cs, 1)
+
+	// Another user asking for the same product gets it without a task.
+	id, err := cs.AddExpression(req.ExpressionRequest{
+		Expression: "b * a + 1",
+		Variables:  map[string]float64{"a": 2, "b": 3},
+	}, 2)
+	if err != nil {
+		t.Fatalf("AddExpression() error = %v", err)
+	}
+
+	if operations := completeTasks(t, cs, 2); !slices.Equal(operations, []string{"+"}) {
+		t.Errorf("executed operations = %v, want [+]", operations)
+	}
+	if unit, _ := cs.FindById(id, 2); unit.Expr.Status != StatusDone || unit.Expr.Result != "7" {
+		t.Errorf("expression = %s %q, want Done 7", unit.Expr.Status, unit.Expr.Result)
+	}
+
+	// the statistics count the product the cache answered too
+	if got := cs.GetOperationCount("*"); got != 2 {
+		t.Errorf("GetOperationCount(*) = %d, want 2", got)
+	}
+
+	want := resp.CacheStatistics{Hits: 1, Misses: 2, Size: 2}
+	if got := cs.CacheStatistics(); got == nil || *got != want {
+		t.Errorf("CacheStatistics() = %+v, want %+v", got, want)
+	}
+}
+
+func TestRationalMode(t *testing.T) {
+	cs := newTestCalcService()
+
+	id, err := cs.AddExpression(req.ExpressionRequest{
+		Expression: "1/3 + 1/6",
+		NumberMode: calculation.NumberModeRational,
+		Optimize:   distributed,
+	}, 1)
+	if err != nil {
+		t.Fatalf("AddExpression() error = %v", err)
+	}
+
+	complete := func() {
+		t.Helper()
+		for task := cs.GetTaskUser(1, "test"); task != nil; task = cs.GetTaskUser(1, "test") {
+			if !task.Rational {
+				t.Fatalf("task %d is not rational", task.ID)
+			}
+
+			numbers := numbersOf(task.Operands, task.Rational)
+			args := make([]*big.Rat, len(numbers))
+			for i, number := range numbers {
+				if number.Fraction == nil {
+					t.Fatalf("operand %d of task %d has no fraction", i, task.ID)
+				}
+				args[i], _ = calculation.ParseExact(number.Fraction.Numerator + "/" + number.Fraction.Denominator)
+			}
+
+			result, err := calculation.ApplyExact(task.Operation, args, task.Digits)
+			if err != nil {
+				t.Fatalf("ApplyExact(%q) error = %v", task.Operation, err)
+			}
+			if err := cs.PutResultUser(task.ID, result.RatString(), 1); err != nil {
+				t.Fatalf("PutResultUser() error = %v", err)
+			}
+		}
+	}
+	complete()
+
+	unit, _ := cs.FindById(id, 1)
+	if unit.Expr.Status != StatusDone || unit.Expr.Result != "1/2" || unit.Expr.Decimal != "0.5" {
+		t.Errorf("expression = %s %q (%q), want Done 1/2 (0.5)", unit.Expr.Status, unit.Expr.Result, unit.Expr.Decimal)
+	}
+
+	// references see the fraction rather than its rounded decimal
+	third, err := cs.AddExpression(req.ExpressionRequest{
+		Expression: "1 / 3",
+		NumberMode: calculation.NumberModeRational,
+		Digits:     5,
+	}, 1)
+	if err != nil {
+		t.Fatalf("AddExpression() error = %v", err)
+	}
+	complete()
+
+	id, err = cs.AddExpression(req.ExpressionRequest{
+		Expression: fmt.Sprintf("$%d * 3 - ans", third),
+		NumberMode: calculation.NumberModeRational,
+	}, 1)
+	if err != nil {
+		t.Fatalf("AddExpression() error = %v", err)
+	}
+	complete()
+
+	if unit, _ := cs.FindById(id, 1); unit.Expr.Status != StatusDone || unit.Expr.Result != "2/3" {
+		t.Errorf("expression = %s %q, want Done 2/3", unit.Expr.Status, unit.Expr.Result)
+	}
+
+	if _, err := cs.AddExpression(req.ExpressionRequest{
+		Expression: "2 ^ 0.5",
+		NumberMode: calculation.NumberModeRational,
+	}, 1); !errors.Is(err, calculation.ErrIrrationalOperation) {
+		t.Errorf("AddExpression(2 ^ 0.5) error = %v, want %v", err, calculation.ErrIrrationalOperation)
+	}
+}
+
+func TestComplexOperands(t *testing.T) {
+	cs := newTestCalcService()
+
+	id, err := cs.AddExpression(req.ExpressionRequest{
+		Expression: "(x+4i)*(1-2i)",
+		Variables:  map[string]float64{"x": 3},
+	}, 1)
+	if err != nil {
+		t.Fatalf("AddExpression() error = %v", err)
+	}
+
+	for {
+		task, err := cs.GetTask(context.Background(), &emptypb.Empty{})
+		if err != nil {
+			break
+		}
+
+		args := make([]complex128, len(task.Operands))
+		for i, operand := range task.Operands {
+			args[i] = complex(operand.Value, operand.Imag)
+		}
+		var result complex128
+		switch task.Operation {
+		case "+":
+			result = args[0] + args[1]
+		case "*":
+			result = args[0] * args[1]
+		default:
+			t.Fatalf("unexpected operation %q", task.Operation)
+		}
+
+		_, err = cs.SendResult(context.Background(), &pb.Result{
+			Id:     task.Id,
+			UserId: task.UserId,
+			Value:  &pb.Result_ComplexResult{ComplexResult: &pb.Complex{Real: real(result), Imag: imag(result)}},
+		})
+		if err != nil {
+			t.Fatalf("SendResult() error = %v", err)
+		}
+	}
+
+	if unit, _ := cs.FindById(id, 1); unit.Expr.Status != StatusDone || unit.Expr.Result != "11-2i" {
+		t.Errorf("expression = %s %q, want Done 11-2i", unit.Expr.Status, unit.Expr.Result)
+	}
+}
+
+func TestMatrixBlocks(t *testing.T) {
+	cs := NewCalcService(&config.Config{ExactDigits: 50, MatrixBlockSize: 1}, zap.NewNop())
+
+	id, err := cs.AddExpression(req.ExpressionRequest{
+		Expression: "[[1, 2], [3, 4]] * [[5, 6], [7, 8]]",
+		Optimize:   distributed,
+	}, 1)
+	if err != nil {
+		t.Fatalf("AddExpression() error = %v", err)
+	}
+
+	var tasks []*pb.Task
+	for {
+		task, err := cs.GetTask(context.Background(), &emptypb.Empty{})
+		if err != nil {
+			break
+		}
+		tasks = append(tasks, task)
+	}
+	if len(tasks) != 4 {
+		t.Fatalf("got %d tasks, want one per block of the 2x2 product", len(tasks))
+	}
+
+	// answer out of order, as agents finishing at different times would
+	for _, task := range slices.Backward(tasks) {
+		a, b := matrixOf(task.Operands[0].Matrix), matrixOf(task.Operands[1].Matrix)
+		if task.Operation != "*" || a.Rows != 1 || b.Cols != 1 {
+			t.Fatalf("task %s %v %v, want a row by a column", task.Operation, a, b)
+		}
+
+		_, err := cs.SendResult(context.Background(), &pb.Result{
+			Id:     task.Id,
+			UserId: task.UserId,
+			Value:  &pb.Result_MatrixResult{MatrixResult: pbMatrix(calculation.Multiply(a, b))},
+		})
+		if err != nil {
+			t.Fatalf("SendResult() error = %v", err)
+		}
+	}
+
+	if unit, _ := cs.FindById(id, 1); unit.Expr.Status != StatusDone || unit.Expr.Result != "[[19,22],[43,50]]" {
+		t.Errorf("expression = %s %q, want Done [[19,22],[43,50]]", unit.Expr.Status, unit.Expr.Result)
+	}
+	graph, _ := cs.FindGraph(id, 1)
+	if root := graph.Graph.Nodes[graph.Graph.Root]; root.Blocks != 4 {
+		t.Errorf("root blocks = %d, want 4", root.Blocks)
+	}
+	if got := cs.GetOperationCount("*"); got != 1 {
+		t.Errorf("GetOperationCount(*) = %d, want one product however many blocks", got)
+	}
+}
+
+func TestUnits(t *testing.T) {
+	cs := newTestCalcService()
+
+	id, err := cs.AddExpression(req.ExpressionRequest{
+		Expression: "5 km / 30 min to km/h",
+		Optimize:   distributed,
+	}, 1)
+	if err != nil {
+		t.Fatalf("AddExpression() error = %v", err)
+	}
+
+	task, err := cs.GetTask(context.Background(), &emptypb.Empty{})
+	if err != nil {
+		t.Fatalf("GetTask() error = %v", err)
+	}
+	a, b := task.Operands[0], task.Operands[1]
+	if task.Operation != "/" || a.Value != 5000 || a.Unit != "m" || b.Value != 1800 || b.Unit != "s" {
+		t.Fatalf("task %s %v %v, want 5000 m / 1800 s", task.Operation, a, b)
+	}
+
+	_, err = cs.SendResult(context.Background(), &pb.Result{
+		Id:     task.Id,
+		UserId: task.UserId,
+		Value:  &pb.Result_FloatResult{FloatResult: a.Value / b.Value},
+		Unit:   "m/s",
+	})
+	if err != nil {
+		t.Fatalf("SendResult() error = %v", err)
+	}
+
+	// the conversion is done without an agent
+	if _, err := cs.GetTask(context.Background(), &emptypb.Empty{}); err == nil {
+		t.Error("GetTask() found a task for the conversion")
+	}
+	if unit, _ := cs.FindById(id, 1); unit.Expr.Status != StatusDone || unit.Expr.Result != "10 km/h" {
+		t.Errorf("expression = %s %q, want Done 10 km/h", unit.Expr.Status, unit.Expr.Result)
+	}
+
+	id, _ = cs.AddExpression(req.ExpressionRequest{Expression: "2 m * 3 s", Optimize: distributed}, 1)
+	task, _ = cs.GetTask(context.Background(), &emptypb.Empty{})
+	cs.SendResult(context.Background(), &pb.Result{
+		Id:     task.Id,
+		UserId: task.UserId,
+		Value:  &pb.Result_FloatResult{FloatResult: 6},
+		Unit:   "m/s",
+	})
+	if unit, _ := cs.FindById(id, 1); unit.Expr.Status != StatusError {
+		t.Errorf("expression with a wrong result unit = %s %q, want Error", unit.Expr.Status, unit.Expr.Result)
+	}
+}
+
+func TestResultFormat(t *testing.T) {
+	cs := newTestCalcService()
+
+	two := 2
+	if err := cs.SetUserFormat(1, calculation.Format{Rounding: "nearest"}); !errors.Is(err, calculation.ErrInvalidFormat) {
+		t.Errorf("SetUserFormat() error = %v, want %v", err, calculation.ErrInvalidFormat)
+	}
+	if err := cs.SetUserFormat(1, calculation.Format{Decimals: &two, Locale: "de"}); err != nil {
+		t.Fatalf("SetUserFormat() error = %v", err)
+	}
+
+	// the result sent over HTTP takes the default format of the user
+	first, _ := cs.AddExpression(req.ExpressionRequest{Expression: "1 / 3", Optimize: distributed}, 1)
+	completeTasks(t, cs, 1)
+	if unit, _ := cs.FindById(first, 1); unit.Expr.Result != "0,33" {
+		t.Errorf("result = %q, want 0,33", unit.Expr.Result)
+	}
+
+	// references see the unformatted result
+	second, _ := cs.AddExpression(req.ExpressionRequest{
+		Expression: "ans * 3000",
+		Optimize:   distributed,
+		Format:     &calculation.Format{ThousandsSeparator: "'"},
+	}, 1)
+	task, _ := cs.GetTask(context.Background(), &emptypb.Empty{})
+	cs.SendResult(context.Background(), &pb.Result{
+		Id:     task.Id,
+		UserId: task.UserId,
+		Value:  &pb.Result_FloatResult{FloatResult: task.Operands[0].Value * task.Operands[1].Value},
+	})
+	if unit, _ := cs.FindById(second, 1); unit.Expr.Result != "1'000" {
+		t.Errorf("result = %q, want 1'000", unit.Expr.Result)
+	}
+}
+
+func TestNonFiniteResults(t *testing.T) {
+	inf := &pb.Result{Value: &pb.Result_FloatResult{FloatResult: math.Inf(1)}}
+
+	tests := []struct {
+		name       string
+		expression string
+		policy     string
+		result     *pb.Result
+		status     string
+		want       string
+	}{
+		// the policy is enforced even if the agent does not
+		{"error", "2 * 3", "", inf, StatusError, "result is not a finite number: +Inf in 2 * 3"},
+		{"agent error", "2 * 3", "", &pb.Result{Value: &pb.Result_Error{Error: "result is not a finite number: +Inf"}}, StatusError, "result is not a finite number: +Inf in 2 * 3"},
+		{"saturate", "1e300 * 1e300", calculation.NonFiniteSaturate, inf, StatusDone, "1.7976931348623157e+308"},
+		{"saturate NaN", "1e300 * 1e300", calculation.NonFiniteSaturate, &pb.Result{Value: &pb.Result_FloatResult{FloatResult: math.NaN()}}, StatusError, "result is not a finite number: NaN in 1e300 * 1e300"},
+		{"allow", "1e300 * 1e300", calculation.NonFiniteAllow, inf, StatusDone, "+Inf"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := NewCalcService(&config.Config{NonFinite: calculation.NonFiniteError}, zap.NewNop())
+
+			id, err := cs.AddExpression(req.ExpressionRequest{Expression: tt.expression, NonFinite: tt.policy, Optimize: distributed}, 1)
+			if err != nil {
+				t.Fatalf("AddExpression() error = %v", err)
+			}
+
+			task, err := cs.GetTask(context.Background(), &emptypb.Empty{})
+			if err != nil {
+				t.Fatalf("GetTask() error = %v", err)
+			}
+			tt.result.Id, tt.result.UserId = task.Id, task.UserId
+			if _, err := cs.SendResult(context.Background(), tt.result); err != nil {
+				t.Fatalf("SendResult() error = %v", err)
+			}
+
+			if unit, _ := cs.FindById(id, 1); unit.Expr.Status != tt.status || unit.Expr.Result != tt.want {
+				t.Errorf("expression = %s %q, want %s %q", unit.Expr.Status, unit.Expr.Result, tt.status, tt.want)
+			}
+		})
+	}
+
+	cs := NewCalcService(&config.Config{NonFinite: calculation.NonFiniteError}, zap.NewNop())
+	if _, err := cs.AddExpression(req.ExpressionRequest{Expression: "1e300 * 1e300"}, 1); !errors.Is(err, calculation.ErrNonFiniteResult) {
+		t.Errorf("AddExpression() error = %v, want %v", err, calculation.ErrNonFiniteResult)
+	}
+
+	// saturating a matrix must not change the cached value it came from
+	cached := resp.Operand{Matrix: &calculation.Matrix{Rows: 1, Cols: 2, Values: []float64{1, math.Inf(1)}}}
+	expr := &resp.Expression{NonFinite: calculation.NonFiniteSaturate}
+	value, ok := cs.finite(expr, &resp.GraphNode{}, cached)
+	if !ok || value.Matrix.Values[1] != math.MaxFloat64 {
+		t.Errorf("finite() = %v, %t, want the infinity saturated", value.Matrix, ok)
+	}
+	if !math.IsInf(cached.Matrix.Values[1], 1) {
+		t.Errorf("finite() changed its argument to %v", cached.Matrix)
+	}
+}
+
+func TestSendResultKinds(t *testing.T) {
+	tests := []struct {
+		name       string
+		request    req.ExpressionRequest
+		result     *pb.Result
+		status     string
+		want       string
+		code       string
+		invalidArg bool
+	}{
+		{"int", req.ExpressionRequest{Expression: "2 * 3"}, &pb.Result{Value: &pb.Result_IntResult{IntResult: 6}}, StatusDone, "6", "", false},
+		{"float", req.ExpressionRequest{Expression: "6 / 4"}, &pb.Result{Value: &pb.Result_FloatResult{FloatResult: 1.5}}, StatusDone, "1.5", "", false},
+		{"exact", req.ExpressionRequest{Expression: "6 / 4"}, &pb.Result{Value: &pb.Result_ExactResult{ExactResult: "3/2"}}, StatusDone, "1.5", "", false},
+		{"rational", req.ExpressionRequest{Expression: "6 / 4", NumberMode: calculation.NumberModeRational}, &pb.Result{Value: &pb.Result_RationalResult{RationalResult: &pb.Fraction{Numerator: "3", Denominator: "2"}}}, StatusDone, "3/2", "", false},
+		{"complex", req.ExpressionRequest{Expression: "2 * 3"}, &pb.Result{Value: &pb.Result_ComplexResult{ComplexResult: &pb.Complex{Real: 6, Imag: -2}}}, StatusDone, "6-2i", "", false},
+		{"matrix", req.ExpressionRequest{Expression: "[[1, 2]] * 2"}, &pb.Result{Value: &pb.Result_MatrixResult{MatrixResult: &pb.Matrix{Rows: 1, Cols: 2, Values: []float64{2, 4}}}}, StatusDone, "[[2,4]]", "", false},
+		{"error", req.ExpressionRequest{Expression: "2 * 3"}, &pb.Result{Value: &pb.Result_Error{Error: "division by zero"}, ErrorCode: "division_by_zero"}, StatusError, "division by zero in 2 * 3", "division_by_zero", false},
+		// agents that predate error codes
+		{"error without code", req.ExpressionRequest{Expression: "2 * 3"}, &pb.Result{Value: &pb.Result_Error{Error: "boom"}}, StatusError, "boom in 2 * 3", codeAgentError, false},
+		{"invalid", req.ExpressionRequest{Expression: "2 * 3"}, &pb.Result{Value: &pb.Result_ExactResult{ExactResult: "six"}}, StatusError, "invalid result six in 2 * 3", codeInvalidResult, false},
+		{"unsupported", req.ExpressionRequest{Expression: "2 * 3"}, &pb.Result{}, StatusError, "unsupported result type in 2 * 3", codeInvalidResult, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := newTestCalcService()
+
+			tt.request.Optimize = distributed
+			id, err := cs.AddExpression(tt.request, 1)
+			if err != nil {
+				t.Fatalf("AddExpression() error = %v", err)
+			}
+
+			task, err := cs.GetTask(context.Background(), &emptypb.Empty{})
+			if err != nil {
+				t.Fatalf("GetTask() error = %v", err)
+			}
+			tt.result.Id, tt.result.UserId = task.Id, task.UserId
+			if _, err := cs.SendResult(context.Background(), tt.result); (status.Code(err) == codes.InvalidArgument) != tt.invalidArg {
+				t.Fatalf("SendResult() error = %v", err)
+			}
+
+			unit, _ := cs.FindById(id, 1)
+			if unit.Expr.Status != tt.status || unit.Expr.Result != tt.want {
+				t.Errorf("expression = %s %q, want %s %q", unit.Expr.Status, unit.Expr.Result, tt.status, tt.want)
+			}
+
+			failed := unit.Expr.FailedTask
+			switch {
+			case tt.code == "" && failed != nil:
+				t.Errorf("failed task = %+v, want none", failed)
+			case tt.code == "":
+			case failed == nil:
+				t.Errorf("no failed task, want code %s", tt.code)
+			case failed.ID != int(task.Id) || failed.Code != tt.code || failed.Operation != task.Operation || !slices.Equal(failed.Args, task.Args):
+				t.Errorf("failed task = %+v, want task %d %s %v with code %s", failed, task.Id, task.Operation, task.Args, tt.code)
+			}
+		})
+	}
+}
+
+// TestPutResultKinds covers the results HTTP agents send to /internal/task.
+func TestPutResultKinds(t *testing.T) {
+	tests := []struct {
+		name    string
+		request req.ExpressionRequest
+		result  req.Result
+		status  string
+		want    string
+		code    string
+		fails   bool
+	}{
+		{"float", req.ExpressionRequest{Expression: "2 * 3"}, req.Result{Value: 6.0}, StatusDone, "6", "", false},
+		{"fraction", req.ExpressionRequest{Expression: "6 / 4", NumberMode: calculation.NumberModeRational}, req.Result{Value: "3/2"}, StatusDone, "3/2", "", false},
+		{"matrix", req.ExpressionRequest{Expression: "[[1, 2]] * 2"}, req.Result{Value: "[[2,4]]"}, StatusDone, "[[2,4]]", "", false},
+		{"error", req.ExpressionRequest{Expression: "2 * 3"}, req.Result{Error: "division by zero", ErrorCode: "division_by_zero"}, StatusError, "division by zero in 2 * 3", "division_by_zero", false},
+		{"invalid", req.ExpressionRequest{Expression: "2 * 3"}, req.Result{Value: "six"}, StatusError, "invalid result six in 2 * 3", codeInvalidResult, true},
+		{"unsupported", req.ExpressionRequest{Expression: "2 * 3"}, req.Result{Value: true}, StatusError, "invalid result true in 2 * 3", codeInvalidResult, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := newTestCalcService()
+
+			tt.request.Optimize = distributed
+			id, err := cs.AddExpression(tt.request, 1)
+			if err != nil {
+				t.Fatalf("AddExpression() error = %v", err)
+			}
+
+			task := cs.GetTaskUser(1, "http-agent")
+			if task == nil {
+				t.Fatal("GetTaskUser() = nil, want a task")
+			}
+			if tt.result.Error != "" {
+				err = cs.FailTaskUser(task.ID, tt.result.ErrorCode, tt.result.Error, 1)
+			} else {
+				err = cs.PutResultUser(task.ID, tt.result.Value, 1)
+			}
+			if (err != nil) != tt.fails {
+				t.Fatalf("sending the result: error = %v, want failure %t", err, tt.fails)
+			}
+			if _, found := cs.timeoutsTable[task.ID]; found {
+				t.Error("the timeout of the task is still registered")
+			}
+
+			unit, _ := cs.FindById(id, 1)
+			if unit.Expr.Status != tt.status || unit.Expr.Result != tt.want {
+				t.Errorf("expression = %s %q, want %s %q", unit.Expr.Status, unit.Expr.Result, tt.status, tt.want)
+			}
+			if failed := unit.Expr.FailedTask; tt.code != "" && (failed == nil || failed.ID != task.ID || failed.Code != tt.code) {
+				t.Errorf("failed task = %+v, want task %d with code %s", failed, task.ID, tt.code)
+			}
+		})
+	}
+}
+
+func TestTaskFailureCancelsTasks(t *testing.T) {
+	cs := newTestCalcService()
+
+	id, err := cs.AddExpression(req.ExpressionRequest{Expression: "(1+2)*(3+4)+(5+6)", Optimize: distributed}, 1)
+	if err != nil {
+		t.Fatalf("AddExpression() error = %v", err)
+	}
+
+	// two of the three additions are running, the last one is queued
+	failing := cs.GetTaskUser(1, "agent-1")
+	running := cs.GetTaskUser(1, "agent-2")
+	if failing == nil || running == nil || len(cs.userTasks[1]) != 1 {
+		t.Fatalf("got tasks %v and %v with %d queued, want one queued", failing, running, len(cs.userTasks[1]))
+	}
+
+	_, err = cs.SendResult(context.Background(), &pb.Result{
+		Id:        int32(failing.ID),
+		UserId:    1,
+		Value:     &pb.Result_Error{Error: "division by zero"},
+		ErrorCode: "division_by_zero",
+	})
+	if err != nil {
+		t.Fatalf("SendResult() error = %v", err)
+	}
+
+	unit, _ := cs.FindById(id, 1)
+	if failed := unit.Expr.FailedTask; unit.Expr.Status != StatusError || failed == nil || failed.ID != failing.ID || failed.Agent != "agent-1" {
+		t.Errorf("expression = %s with failed task %+v, want task %d of agent-1", unit.Expr.Status, failed, failing.ID)
+	}
+	if len(cs.userTasks[1]) != 0 || len(cs.userTaskTable[1]) != 0 || len(cs.timeoutsTable) != 0 {
+		t.Errorf("%d queued, %d outstanding and %d timed tasks left, want none", len(cs.userTasks[1]), len(cs.userTaskTable[1]), len(cs.timeoutsTable))
+	}
+
+	statuses := make(map[string]int)
+	graph, _ := cs.FindGraph(id, 1)
+	for _, node := range graph.Graph.Nodes {
+		if node.Operation == "+" && node.ID != graph.Graph.Root {
+			statuses[node.Status]++
+		}
+	}
+	if statuses[NodeError] != 1 || statuses[NodeCancelled] != 2 {
+		t.Errorf("addition statuses = %v, want one error and two cancelled", statuses)
+	}
+
+	// a late result of a cancelled task is rejected
+	_, err = cs.SendResult(context.Background(), &pb.Result{
+		Id:     int32(running.ID),
+		UserId: 1,
+		Value:  &pb.Result_FloatResult{FloatResult: 7},
+	})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("late SendResult() error = %v, want NotFound", err)
+	}
+
+	// failures reported over HTTP
+	id, err = cs.AddExpression(req.ExpressionRequest{Expression: "2 * 3", Optimize: distributed}, 1)
+	if err != nil {
+		t.Fatalf("AddExpression() error = %v", err)
+	}
+	task := cs.GetTaskUser(1, "agent-1")
+	if err := cs.FailTaskUser(task.ID, "", "boom", 1); err != nil {
+		t.Fatalf("FailTaskUser() error = %v", err)
+	}
+	if unit, _ := cs.FindById(id, 1); unit.Expr.Status != StatusError || unit.Expr.FailedTask == nil || unit.Expr.FailedTask.Code != codeAgentError {
+		t.Errorf("expression = %s with failed task %+v, want code %s", unit.Expr.Status, unit.Expr.FailedTask, codeAgentError)
+	}
+}
+
+// BenchmarkRebalance runs the chain on four agents that take a millisecond
+// per addition: seven sequential additions against three rounds of
+// concurrent ones.
+func BenchmarkRebalance(b *testing.B) {
+	const agents = 4
+
+	for _, bb := range []struct {
+		name      string
+		rebalance *bool
+	}{
+		{"balanced", nil},
+		{"strict order", new(bool)},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				cs := newTestCalcService()
+
+				request := chain
+				request.Rebalance = bb.rebalance
+				id, err := cs.AddExpression(request, 1)
+				if err != nil {
+					b.Fatalf("AddExpression() error = %v", err)
+				}
+
+				done := make(chan struct{})
+				var wg sync.WaitGroup
+				for range agents {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						runAgent(cs, done)
+					}()
+				}
+
+				for {
+					if unit, _ := cs.FindById(id, 1); unit.Expr.Status != StatusWaiting {
+						break
+					}
+					time.Sleep(100 * time.Microsecond)
+				}
+				close(done)
+				wg.Wait()
+			}
+		})
+	}
+}
+
+// runAgent adds the operands of every task it takes after a millisecond.
+func runAgent(cs *CalcService, done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		task := cs.GetTaskUser(1, "test")
+		if task == nil {
+			time.Sleep(100 * time.Microsecond)
+			continue
+		}
+
+		a, _ := strconv.ParseFloat(task.Args[0], 64)
+		c, _ := strconv.ParseFloat(task.Args[1], 64)
+		time.Sleep(time.Millisecond)
+		_ = cs.PutResultUser(task.ID, a+c, 1)
+	}
+}
+
+func TestFindGraph(t *testing.T) {
+	cs := newTestCalcService()
+
+	id, err := cs.AddExpression(req.ExpressionRequest{
+		Expression: "if(x > 1, x * 2, x - 1)",
+		Variables:  map[string]float64{"x": 3},
+	}, 1)
+	if err != nil {
+		t.Fatalf("AddExpression() error = %v", err)
+	}
+
+	statuses := func() []string {
+		graph, err := cs.FindGraph(id, 1)
+		if err != nil {
+			t.Fatalf("FindGraph() error = %v", err)
+		}
+		result := make([]string, len(graph.Graph.Nodes))
+		for i, node := range graph.Graph.Nodes {
+			result[i] = node.Status
+		}
+		return result
+	}
+
+	// x, 1, >, 2, *, -, if: the literals are shared between the branches.
+	want := []string{NodeDone, NodeDone, NodeQueued, NodeDone, NodeIdle, NodeIdle, NodePending}
+	if got := statuses(); !slices.Equal(got, want) {
+		t.Errorf("statuses after start = %v, want %v", got, want)
+	}
+
+	task := cs.GetTaskUser(1, "agent-1")
+	graph, _ := cs.FindGraph(id, 1)
+	if node := graph.Graph.Nodes[2]; node.Status != NodeRunning || node.Agent != "agent-1" || node.StartedAt == nil {
+		t.Errorf("taken node = %s by %q started at %v", node.Status, node.Agent, node.StartedAt)
+	}
+	if err := cs.PutResultUser(task.ID, 1.0, 1); err != nil {
+		t.Fatalf("PutResultUser() error = %v", err)
+	}
+
+	want = []string{NodeDone, NodeDone, NodeDone, NodeDone, NodeQueued, NodeSkipped, NodePending}
+	if got := statuses(); !slices.Equal(got, want) {
+		t.Errorf("statuses after condition = %v, want %v", got, want)
+	}
+
+	completeTasks(t, cs, 1)
+
+	graph, _ = cs.FindGraph(id, 1)
+	if graph.Status != StatusDone || graph.Graph.Nodes[graph.Graph.Root].Result != "6" {
+		t.Errorf("graph = %s with root %+v, want Done 6", graph.Status, graph.Graph.Nodes[graph.Graph.Root])
+	}
+	if node := graph.Graph.Nodes[2]; node.Result != "true" || node.QueuedAt == nil || node.FinishedAt == nil {
+		t.Errorf("condition node = %+v, want result true with timings", node)
+	}
+}
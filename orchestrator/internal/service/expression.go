@@ -1,13 +1,14 @@
 package service
 
 import (
-	"container/list"
-	"fmt"
+	"errors"
+	"math/big"
 	"strconv"
-	"strings"
 
+	"github.com/DobryySoul/orchestrator/internal/controllers/http/models/req"
 	"github.com/DobryySoul/orchestrator/internal/controllers/http/models/resp"
 	"github.com/DobryySoul/orchestrator/pkg/calculation"
+	"github.com/DobryySoul/orchestrator/pkg/calculation/ast"
 )
 
 const (
@@ -16,95 +17,287 @@ const (
 	StatusWaiting = "Waiting"
 )
 
-const (
-	TokenTypeNumber = iota
-	TokenTypeOperation
-	TokenTypeTask
-)
+func isExact(expr *resp.Expression) bool {
+	return expr.Precision == calculation.PrecisionExact || isRational(expr)
+}
+
+func isRational(expr *resp.Expression) bool {
+	return expr.NumberMode == calculation.NumberModeRational
+}
+
+// newOperand converts a literal or an agent result to an operand. In exact
+// mode the operand also carries the value as a fraction.
+func newOperand(value any, exact bool) (resp.Operand, bool) {
+	var operand resp.Operand
+
+	switch v := value.(type) {
+	case float64:
+		operand.Value = v
+		if exact {
+			rat := new(big.Rat).SetFloat64(v)
+			if rat == nil {
+				return operand, false
+			}
+			operand.Exact = rat.RatString()
+		}
+	case int64:
+		return newOperand(float64(v), exact)
+	case complex128:
+		if imag(v) == 0 {
+			return newOperand(real(v), exact)
+		}
+		if exact {
+			return operand, false
+		}
+		operand.Value, operand.Imag = real(v), imag(v)
+	case *calculation.Matrix:
+		if exact || v == nil {
+			return operand, false
+		}
+		operand.Matrix = v
+	case string:
+		rat, ok := calculation.ParseExact(v)
+		if !ok {
+			if z, ok := calculation.ParseComplex(v); ok {
+				return newOperand(z, exact)
+			}
+			if m, ok := calculation.ParseMatrix(v); ok {
+				return newOperand(m, exact)
+			}
+			if q, ok := calculation.ParseQuantity(v); ok && !exact {
+				operand.Value, operand.Unit = q.SI(), q.Unit
+				return operand, true
+			}
+			return operand, false
+		}
+		operand.Value, _ = rat.Float64()
+		if exact {
+			operand.Exact = rat.RatString()
+		}
+	default:
+		return operand, false
+	}
 
-type Token interface {
-	Type() int
+	return operand, true
 }
 
-type (
-	NumToken struct {
-		Value float64
+func exactOf(operand resp.Operand) (*big.Rat, bool) {
+	if operand.Exact == "" {
+		return nil, false
 	}
-	OpToken struct {
-		Value string
+	return calculation.ParseExact(operand.Exact)
+}
+
+func isTrue(operand resp.Operand) bool {
+	if exact, ok := exactOf(operand); ok {
+		return exact.Sign() != 0
 	}
-	TaskToken struct {
-		ID int
+	return operand.Value != 0 || operand.Imag != 0
+}
+
+func formatOperand(operand resp.Operand) string {
+	if operand.Matrix != nil {
+		return operand.Matrix.String()
 	}
-)
+	if operand.Unit != "" {
+		return calculation.FormatQuantity(operand.Value, operand.Unit)
+	}
+	if operand.Exact != "" {
+		return operand.Exact
+	}
+	if operand.Imag != 0 {
+		return calculation.FormatComplex(complex(operand.Value, operand.Imag))
+	}
+	return strconv.FormatFloat(operand.Value, 'g', -1, 64)
+}
 
-func (num NumToken) Type() int {
-	return TokenTypeNumber
+// formatResult renders the value of a node as the result of expr in format.
+// Booleans, fractions and matrices are not affected by the format.
+func formatResult(expr *resp.Expression, node *resp.GraphNode, format calculation.Format) string {
+	if node.Boolean {
+		return strconv.FormatBool(isTrue(node.Value))
+	}
+	if exact, ok := exactOf(node.Value); ok {
+		if isRational(expr) {
+			return exact.RatString()
+		}
+		return format.Decimal(calculation.FormatExact(exact, expr.Digits))
+	}
+	switch {
+	case node.Value.Matrix != nil:
+		return formatOperand(node.Value)
+	case node.Value.Unit != "":
+		return format.Quantity(node.Value.Value, node.Value.Unit)
+	case node.Value.Imag != 0:
+		return format.Complex(complex(node.Value.Value, node.Value.Imag))
+	}
+	return format.Float(node.Value.Value)
 }
 
-func (num OpToken) Type() int {
-	return TokenTypeOperation
+// setResult completes expr with the value of its root node, formatted as
+// the request asked. Rational results also get a decimal approximation.
+// The unformatted result, the exact fraction for rational ones and 1 or 0
+// for booleans, is kept for references to expr.
+func setResult(expr *resp.Expression, root *resp.GraphNode) {
+	var format calculation.Format
+	if expr.Format != nil {
+		format = *expr.Format
+	}
+
+	expr.Status = StatusDone
+	expr.Result = formatResult(expr, root, format)
+	expr.Value = formatResult(expr, root, calculation.Format{})
+	if root.Boolean {
+		expr.Value = formatOperand(root.Value)
+	}
+
+	if exact, ok := exactOf(root.Value); ok && isRational(expr) && !root.Boolean {
+		expr.Decimal = format.Decimal(calculation.FormatExact(exact, expr.Digits))
+	}
 }
 
-func (num TaskToken) Type() int {
-	return TokenTypeTask
+// referenceValue is the result of expr as a number that other expressions
+// can refer to.
+func referenceValue(expr *resp.Expression) string {
+	if expr.Value != "" {
+		return expr.Value
+	}
+	if expr.Decimal != "" {
+		return expr.Decimal
+	}
+	return expr.Result
 }
 
+// ExprElement locates the graph node a task computes.
 type ExprElement struct {
 	ID     int
-	Ptr    *list.Element
+	Node   int
 	UserID uint64
+	Key    string // key of the task in the result cache
+
+	// A block of a split matrix product lands at Row, Col of the result.
+	Blocked  bool
+	Row, Col int
+
+	Unit string // unit of the result of a task on quantities
+}
+
+// ErrorDetails describes where err occurred in the expression, or returns
+// nil when err is not a calculation.ParseError.
+func ErrorDetails(err error) *resp.ErrorDetails {
+	var parseErr *calculation.ParseError
+	if !errors.As(err, &parseErr) {
+		return nil
+	}
+
+	return &resp.ErrorDetails{
+		Code:       parseErr.Code(),
+		Message:    parseErr.Error(),
+		Position:   parseErr.Position,
+		Token:      parseErr.Token,
+		Snippet:    parseErr.Expression,
+		Caret:      parseErr.Caret(),
+		Suggestion: parseErr.Suggestion,
+	}
 }
 
-func NewExpression(id int, expr string) (*resp.Expression, error) {
-	rpn, err := calculation.RPN(expr)
+func NewExpression(id int, request req.ExpressionRequest, resolve calculation.Resolver) (*resp.Expression, error) {
+	tree, err := calculation.ParseWithOptions(request.Expression, calculation.Options{
+		Variables:        request.Variables,
+		Resolve:          resolve,
+		Precision:        request.Precision,
+		Digits:           request.Digits,
+		NumberMode:       request.NumberMode,
+		DecimalSeparator: request.DecimalSeparator,
+		Locale:           request.Locale,
+		NonFinite:        request.NonFinite,
+	})
+	if err == nil && request.Format != nil {
+		err = request.Format.Validate()
+	}
 	if err != nil {
 		return &resp.Expression{
 			ID:         id,
 			Status:     StatusError,
 			Result:     err.Error(),
-			Expression: expr,
+			Error:      ErrorDetails(err),
+			Expression: request.Expression,
+			Variables:  request.Variables,
+			Precision:  request.Precision,
+			Digits:     request.Digits,
+			NumberMode: request.NumberMode,
+			Separator:  request.DecimalSeparator,
+			Locale:     request.Locale,
+			Format:     request.Format,
+			NonFinite:  request.NonFinite,
+			Optimize:   request.Optimize,
+			Rebalance:  request.Rebalance,
 		}, err
 	}
 
 	expression := &resp.Expression{
-		List:       list.New(),
 		ID:         id,
 		Status:     StatusError,
 		Result:     "",
-		Expression: expr,
+		Expression: request.Expression,
+		Variables:  request.Variables,
+		Precision:  request.Precision,
+		Digits:     request.Digits,
+		NumberMode: request.NumberMode,
+		Separator:  request.DecimalSeparator,
+		Locale:     request.Locale,
+		Format:     request.Format,
+		NonFinite:  request.NonFinite,
+		Optimize:   request.Optimize,
+		Rebalance:  request.Rebalance,
 	}
 
-	if rpn == nil {
+	if tree == nil {
 		return expression, nil
 	}
 
-	if len(rpn) == 1 {
-		expression.Status = StatusDone
-		expression.Result = rpn[0]
+	if hasReferences(tree) {
+		expression.Status = StatusWaiting
 		return expression, nil
 	}
 
-	expression.Status = StatusWaiting
-	for _, val := range rpn {
-		if val == "" {
-			continue
-		}
-		if strings.Contains("-+*/", val) {
-			expression.List.PushBack(OpToken{val})
-		} else {
-			num, err := strconv.ParseFloat(val, 64)
-			if err != nil {
-				return nil, fmt.Errorf("parse float error: %w", err)
-			}
-			expression.List.PushBack(NumToken{num})
-		}
+	boolean := (tree.Kind == ast.Unary || tree.Kind == ast.Binary) && calculation.IsBoolean(tree.Value)
+	if request.Optimize == nil || *request.Optimize {
+		tree, expression.Simplifications = calculation.Optimize(tree, calculation.Options{
+			Precision:  request.Precision,
+			Digits:     request.Digits,
+			NumberMode: request.NumberMode,
+		})
+	}
+	if request.Rebalance == nil || *request.Rebalance {
+		tree = calculation.Rebalance(tree)
 	}
 
-	if err == calculation.ErrDivisionByZero {
-		expression.Status = StatusError
-		expression.Result = calculation.ErrDivisionByZero.Error()
+	graph, err := newGraph(tree, isExact(expression))
+	if err != nil {
+		return nil, err
 	}
+	expression.Graph = graph
+	expression.SavedTasks = graph.Shared
+
+	if root := graph.Nodes[graph.Root]; root.Status == NodeDone {
+		root.Boolean = boolean
+		setResult(expression, root)
+		return expression, nil
+	}
+
+	expression.Status = StatusWaiting
 
 	return expression, nil
 }
+
+func hasReferences(tree *ast.Node) bool {
+	found := false
+	ast.Walk(tree, func(node *ast.Node) bool {
+		if node.Kind == ast.Variable && calculation.IsReference(node.Value) {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
@@ -1,8 +1,10 @@
 package service
 
 import (
+	"errors"
 	"testing"
 
+	"github.com/DobryySoul/orchestrator/internal/controllers/http/models/req"
 	"github.com/DobryySoul/orchestrator/internal/controllers/http/models/resp"
 	"github.com/DobryySoul/orchestrator/pkg/calculation"
 )
@@ -12,6 +14,8 @@ func TestNewExpression(t *testing.T) {
 		name       string
 		id         int
 		expr       string
+		vars       map[string]float64
+		precision  string
 		wantExpr   *resp.Expression
 		wantErr    bool
 		errMessage string
@@ -53,13 +57,13 @@ func TestNewExpression(t *testing.T) {
 			wantErr: true,
 		},
 		{
-			name: "invalid expression invalid character",
+			name: "invalid expression unbound variable",
 			id:   4,
 			expr: "a - 2",
 			wantExpr: &resp.Expression{
 				ID:         4,
 				Status:     StatusError,
-				Result:     calculation.ErrInvalidCharacter.Error(),
+				Result:     calculation.ErrUnboundVariable.Error(),
 				Expression: "a - 2",
 			},
 			wantErr: true,
@@ -136,11 +140,166 @@ func TestNewExpression(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "valid expression with unary minus",
+			id:   11,
+			expr: "-3 + 5",
+			wantExpr: &resp.Expression{
+				ID:         11,
+				Status:     StatusWaiting,
+				Result:     "",
+				Expression: "-3 + 5",
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid expression with negated parentheses",
+			id:   12,
+			expr: "2 * -(1 + 1)",
+			wantExpr: &resp.Expression{
+				ID:         12,
+				Status:     StatusWaiting,
+				Result:     "",
+				Expression: "2 * -(1 + 1)",
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid expression with power",
+			id:   13,
+			expr: "2 ** 3 ^ 2",
+			wantExpr: &resp.Expression{
+				ID:         13,
+				Status:     StatusWaiting,
+				Result:     "",
+				Expression: "2 ** 3 ^ 2",
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid expression with functions",
+			id:   14,
+			expr: "sqrt(16) + max(2, 7, 3)",
+			wantExpr: &resp.Expression{
+				ID:         14,
+				Status:     StatusWaiting,
+				Result:     "",
+				Expression: "sqrt(16) + max(2, 7, 3)",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid expression unknown function",
+			id:   15,
+			expr: "foo(1)",
+			wantExpr: &resp.Expression{
+				ID:         15,
+				Status:     StatusError,
+				Result:     calculation.ErrUnknownFunction.Error(),
+				Expression: "foo(1)",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid expression invalid character",
+			id:   16,
+			expr: "2 # 3",
+			wantExpr: &resp.Expression{
+				ID:         16,
+				Status:     StatusError,
+				Result:     calculation.ErrInvalidCharacter.Error(),
+				Expression: "2 # 3",
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid expression with variables",
+			id:   17,
+			expr: "a*x+b",
+			vars: map[string]float64{"a": 2, "x": 3, "b": 1},
+			wantExpr: &resp.Expression{
+				ID:         17,
+				Status:     StatusWaiting,
+				Result:     "",
+				Expression: "a*x+b",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid expression with partially bound variables",
+			id:   18,
+			expr: "a*x+b",
+			vars: map[string]float64{"a": 2, "x": 3},
+			wantExpr: &resp.Expression{
+				ID:         18,
+				Status:     StatusError,
+				Result:     calculation.ErrUnboundVariable.Error(),
+				Expression: "a*x+b",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid expression division by zero variable",
+			id:   19,
+			expr: "1 / y",
+			vars: map[string]float64{"y": 0},
+			wantExpr: &resp.Expression{
+				ID:         19,
+				Status:     StatusError,
+				Result:     calculation.ErrDivisionByZero.Error(),
+				Expression: "1 / y",
+			},
+			wantErr: true,
+		},
+		{
+			name:      "valid exact expression",
+			id:        20,
+			expr:      "0.1 + 0.2",
+			precision: calculation.PrecisionExact,
+			wantExpr: &resp.Expression{
+				ID:         20,
+				Status:     StatusWaiting,
+				Result:     "",
+				Expression: "0.1 + 0.2",
+			},
+			wantErr: false,
+		},
+		{
+			name:      "invalid exact expression with exact zero divisor",
+			id:        21,
+			expr:      "1 / (0.3 - 0.1 * 3)",
+			precision: calculation.PrecisionExact,
+			wantExpr: &resp.Expression{
+				ID:         21,
+				Status:     StatusError,
+				Result:     calculation.ErrDivisionByZero.Error(),
+				Expression: "1 / (0.3 - 0.1 * 3)",
+			},
+			wantErr: true,
+		},
+		{
+			name:      "invalid expression unknown precision",
+			id:        22,
+			expr:      "1 + 1",
+			precision: "quad",
+			wantExpr: &resp.Expression{
+				ID:         22,
+				Status:     StatusError,
+				Result:     calculation.ErrUnknownPrecision.Error(),
+				Expression: "1 + 1",
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotExpr, err := NewExpression(tt.id, tt.expr)
+			gotExpr, err := NewExpression(tt.id, req.ExpressionRequest{
+				Expression: tt.expr,
+				Variables:  tt.vars,
+				Precision:  tt.precision,
+				Optimize:   distributed,
+			}, nil)
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("NewExpression() error = %v, wantErr %v", err, tt.wantErr)
@@ -165,3 +324,123 @@ func TestNewExpression(t *testing.T) {
 		})
 	}
 }
+
+func TestNewExpressionRational(t *testing.T) {
+	tests := []struct {
+		expr        string
+		mode        string
+		wantResult  string
+		wantDecimal string
+		wantErr     error
+	}{
+		{expr: "1/3 + 1/6", mode: calculation.NumberModeRational, wantResult: "1/2", wantDecimal: "0.5"},
+		{expr: "2/3 * 3", mode: calculation.NumberModeRational, wantResult: "2", wantDecimal: "2"},
+		{expr: "1/3", mode: calculation.NumberModeRational, wantResult: "1/3", wantDecimal: "0.33333"},
+		{expr: "1/3 < 1/2", mode: calculation.NumberModeRational, wantResult: "true"},
+		{expr: "1/3 + 1/6", mode: calculation.NumberModeFloat, wantResult: "0.5"},
+		{expr: "1/3 + 1/6", mode: "decimal", wantErr: calculation.ErrUnknownNumberMode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode+" "+tt.expr, func(t *testing.T) {
+			expr, err := NewExpression(1, req.ExpressionRequest{
+				Expression: tt.expr,
+				NumberMode: tt.mode,
+				Digits:     5,
+			}, nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewExpression() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if expr.Status != StatusDone || expr.Result != tt.wantResult || expr.Decimal != tt.wantDecimal {
+				t.Errorf("NewExpression() = %s %q (%q), want Done %q (%q)",
+					expr.Status, expr.Result, expr.Decimal, tt.wantResult, tt.wantDecimal)
+			}
+		})
+	}
+}
+
+func TestNewExpressionDecimalSeparator(t *testing.T) {
+	tests := []struct {
+		expr       string
+		separator  string
+		locale     string
+		wantResult string
+		wantErr    error
+	}{
+		{expr: "2(1,5 + 1)", separator: calculation.DecimalComma, wantResult: "5"},
+		{expr: "max(0,5; 0,25) × 4", separator: calculation.DecimalComma, wantResult: "2"},
+		{expr: "2(1.5 + 1)", separator: calculation.DecimalPoint, wantResult: "5"},
+		{expr: "1,5", separator: "'", wantErr: calculation.ErrUnknownSeparator},
+		{expr: "2(1,5 + 1)", locale: "de", wantResult: "5"},
+		{expr: "2(1.5 + 1)", locale: "ch", wantResult: "5"},
+		{expr: "2(1.5 + 1)", separator: calculation.DecimalPoint, locale: "ru", wantResult: "5"},
+		{expr: "1,5", locale: "xx", wantErr: calculation.ErrUnknownLocale},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.separator+tt.locale+" "+tt.expr, func(t *testing.T) {
+			expr, err := NewExpression(1, req.ExpressionRequest{
+				Expression:       tt.expr,
+				DecimalSeparator: tt.separator,
+				Locale:           tt.locale,
+			}, nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewExpression() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if expr.Status != StatusDone || expr.Result != tt.wantResult || expr.Separator != tt.separator {
+				t.Errorf("NewExpression() = %s %q, want Done %q", expr.Status, expr.Result, tt.wantResult)
+			}
+		})
+	}
+}
+
+func TestNewExpressionFormat(t *testing.T) {
+	three := 3
+	tests := []struct {
+		request     req.ExpressionRequest
+		wantResult  string
+		wantDecimal string
+		wantErr     error
+	}{
+		{request: req.ExpressionRequest{Expression: "2 / 3"}, wantResult: "0.6666666666666666"},
+		{request: req.ExpressionRequest{Expression: "2 / 3", Format: &calculation.Format{Decimals: &three}}, wantResult: "0.667"},
+		{request: req.ExpressionRequest{Expression: "2 / 3", Format: &calculation.Format{Decimals: &three, Rounding: calculation.RoundDown}}, wantResult: "0.666"},
+		{request: req.ExpressionRequest{Expression: "2 ^ 80", Format: &calculation.Format{SignificantDigits: 4}}, wantResult: "1.209e+24"},
+		{request: req.ExpressionRequest{Expression: "3 + 4i", Format: &calculation.Format{Decimals: &three}}, wantResult: "3.000+4.000i"},
+		{request: req.ExpressionRequest{Expression: "1 > 0", Format: &calculation.Format{Decimals: &three}}, wantResult: "true"},
+		{
+			request:    req.ExpressionRequest{Expression: "2 / 3", Precision: calculation.PrecisionExact, Digits: 20, Format: &calculation.Format{SignificantDigits: 17}},
+			wantResult: "0.66666666666666667",
+		},
+		{
+			request:     req.ExpressionRequest{Expression: "1234 / 3", NumberMode: calculation.NumberModeRational, Digits: 20, Format: &calculation.Format{Locale: "en", Decimals: &three}},
+			wantResult:  "1234/3",
+			wantDecimal: "411.333",
+		},
+		{request: req.ExpressionRequest{Expression: "1", Format: &calculation.Format{Notation: "engineering"}}, wantErr: calculation.ErrInvalidFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.request.Expression, func(t *testing.T) {
+			expr, err := NewExpression(1, tt.request, nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewExpression() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if expr.Status != StatusDone || expr.Result != tt.wantResult || expr.Decimal != tt.wantDecimal {
+				t.Errorf("NewExpression() = %s %q (%q), want Done %q (%q)", expr.Status, expr.Result, expr.Decimal, tt.wantResult, tt.wantDecimal)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/DobryySoul/orchestrator/internal/controllers/http/models/resp"
+	"github.com/DobryySoul/orchestrator/pkg/calculation"
+	"github.com/DobryySoul/orchestrator/pkg/calculation/ast"
+)
+
+// Statuses of a graph node. Nodes start idle and become pending once their
+// result is needed, which for the branches of if() is only after the
+// condition has been computed. Queued and running nodes are cancelled when
+// another node of the expression fails.
+const (
+	NodeIdle      = "idle"
+	NodePending   = "pending"
+	NodeQueued    = "queued"
+	NodeRunning   = "running"
+	NodeDone      = "done"
+	NodeSkipped   = "skipped"
+	NodeError     = "error"
+	NodeCancelled = "cancelled"
+)
+
+// newGraph turns the tree into a task graph with one node per literal and
+// operation. Literals are done from the start, everything else is idle.
+// Structurally identical subtrees share a single node, so a repeated
+// subexpression is computed once and feeds every operation that uses it.
+func newGraph(tree *ast.Node, exact bool) (*resp.Graph, error) {
+	builder := &graphBuilder{graph: &resp.Graph{}, exact: exact, seen: make(map[string]int)}
+
+	root, err := builder.add(tree)
+	if err != nil {
+		return nil, err
+	}
+	builder.graph.Root = root
+
+	return builder.graph, nil
+}
+
+type graphBuilder struct {
+	graph *resp.Graph
+	exact bool
+	seen  map[string]int // node key -> node ID
+}
+
+func (b *graphBuilder) add(node *ast.Node) (int, error) {
+	inputs := make([]int, len(node.Args))
+	for i, arg := range node.Args {
+		id, err := b.add(arg)
+		if err != nil {
+			return 0, err
+		}
+		inputs[i] = id
+	}
+
+	// Inputs are already shared, so comparing them by ID is enough to
+	// compare whole subtrees.
+	key := fmt.Sprint(node.Kind, node.Value, inputs)
+	if id, ok := b.seen[key]; ok {
+		if node.Kind != ast.Literal {
+			b.graph.Shared++
+		}
+		return id, nil
+	}
+
+	graphNode := &resp.GraphNode{ID: len(b.graph.Nodes), Inputs: inputs, Status: NodeIdle}
+	switch node.Kind {
+	case ast.Literal:
+		value, ok := newOperand(node.Value, b.exact)
+		if !ok {
+			return 0, fmt.Errorf("invalid number %q", node.Value)
+		}
+		graphNode.Status = NodeDone
+		graphNode.Value = value
+		graphNode.Result = formatOperand(value)
+	case ast.Unary, ast.Binary, ast.Call:
+		graphNode.Operation = node.Value
+		graphNode.Source = node.String()
+		graphNode.Boolean = calculation.IsBoolean(node.Value)
+	default:
+		return 0, fmt.Errorf("unexpected %s node %q", node.Kind, node.Value)
+	}
+
+	b.graph.Nodes = append(b.graph.Nodes, graphNode)
+	b.seen[key] = graphNode.ID
+
+	for i, input := range inputs {
+		if !slices.Contains(inputs[:i], input) {
+			b.graph.Nodes[input].Dependents = append(b.graph.Nodes[input].Dependents, graphNode.ID)
+		}
+	}
+
+	return graphNode.ID, nil
+}
+
+// copyGraph returns a snapshot of graph that can be read without the lock.
+func copyGraph(graph *resp.Graph) *resp.Graph {
+	if graph == nil {
+		return nil
+	}
+
+	snapshot := &resp.Graph{Nodes: make([]*resp.GraphNode, len(graph.Nodes)), Root: graph.Root, Shared: graph.Shared}
+	for i, node := range graph.Nodes {
+		copied := *node
+		snapshot.Nodes[i] = &copied
+	}
+	return snapshot
+}
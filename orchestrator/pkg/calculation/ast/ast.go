@@ -0,0 +1,141 @@
+// Package ast describes the syntax tree of a calculator expression.
+package ast
+
+import "strings"
+
+type Kind int
+
+const (
+	Literal Kind = iota
+	Unary
+	Binary
+	Call
+	Variable
+)
+
+var kindNames = [...]string{
+	Literal:  "literal",
+	Unary:    "unary",
+	Binary:   "binary",
+	Call:     "call",
+	Variable: "variable",
+}
+
+func (k Kind) String() string {
+	if int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return "unknown"
+}
+
+func (k Kind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// Node is an expression. Value holds the number of a literal, the operator
+// of a unary or binary node, the function name of a call or the name of a
+// variable; Args holds the operands in source order. A literal bound from a
+// variable, constant or reference keeps that name in Name.
+//
+// Start and End are rune offsets of the node in the source expression, End
+// being exclusive. Parentheses around a node are not part of its span.
+type Node struct {
+	Kind  Kind    `json:"kind"`
+	Value string  `json:"value"`
+	Name  string  `json:"name,omitempty"`
+	Args  []*Node `json:"args,omitempty"`
+	Start int     `json:"start"`
+	End   int     `json:"end"`
+}
+
+// Walk calls visit for node and, while visit returns true, for each of its
+// arguments in turn.
+func Walk(node *Node, visit func(*Node) bool) {
+	if node == nil || !visit(node) {
+		return
+	}
+	for _, arg := range node.Args {
+		Walk(arg, visit)
+	}
+}
+
+// String prints the node as an expression, with only the parentheses that
+// are needed to keep its structure. Bound literals are printed by name.
+func (n *Node) String() string {
+	var b strings.Builder
+	n.print(&b)
+	return b.String()
+}
+
+func (n *Node) print(b *strings.Builder) {
+	switch n.Kind {
+	case Unary:
+		b.WriteString(Spelling(n.Value))
+		n.Args[0].printOperand(b, Precedence(n.Value), false)
+	case Binary:
+		right := IsRightAssociative(n.Value)
+		n.Args[0].printOperand(b, Precedence(n.Value), right)
+		b.WriteString(" " + n.Value + " ")
+		n.Args[1].printOperand(b, Precedence(n.Value), !right)
+	case Call:
+		b.WriteString(n.Value + "(")
+		for i, arg := range n.Args {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			arg.print(b)
+		}
+		b.WriteString(")")
+	default:
+		if n.Name != "" {
+			b.WriteString(n.Name)
+			return
+		}
+		b.WriteString(n.Value)
+	}
+}
+
+// printOperand parenthesizes n if it binds looser than its parent operator,
+// or as tight on the side the operator does not associate to.
+func (n *Node) printOperand(b *strings.Builder, parent int, strict bool) {
+	own := n.precedence()
+	if own < parent || strict && own == parent {
+		b.WriteString("(")
+		n.print(b)
+		b.WriteString(")")
+		return
+	}
+	n.print(b)
+}
+
+// isComplexSum reports whether a literal such as 11-2i has both a real and
+// an imaginary part.
+func isComplexSum(value string) bool {
+	if !strings.HasSuffix(value, "i") {
+		return false
+	}
+	for i := 1; i < len(value); i++ {
+		if (value[i] == '+' || value[i] == '-') && value[i-1] != 'e' && value[i-1] != 'E' {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *Node) precedence() int {
+	switch {
+	case n.Kind == Unary || n.Kind == Binary:
+		return Precedence(n.Value)
+	case n.Kind != Literal || n.Name != "":
+		return maxPrecedence
+	case strings.Contains(n.Value, "/"):
+		// a fraction reads as a division, a complex value as a sum and a
+		// negative value as a negation
+		return Precedence("/")
+	case isComplexSum(n.Value):
+		return Precedence("+")
+	case strings.HasPrefix(n.Value, "-"):
+		return Precedence(Neg)
+	}
+	return maxPrecedence
+}
@@ -0,0 +1,73 @@
+package ast
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func num(value string) *Node {
+	return &Node{Kind: Literal, Value: value}
+}
+
+func binary(op string, left, right *Node) *Node {
+	return &Node{Kind: Binary, Value: op, Args: []*Node{left, right}}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		name     string
+		node     *Node
+		expected string
+	}{
+		{"literal", num("1.5"), "1.5"},
+		{"no parentheses needed", binary("+", num("1"), binary("*", num("2"), num("3"))), "1 + 2 * 3"},
+		{"lower precedence operand", binary("*", binary("+", num("1"), num("2")), num("3")), "(1 + 2) * 3"},
+		{"left associative", binary("-", num("1"), binary("-", num("2"), num("3"))), "1 - (2 - 3)"},
+		{"right associative", binary("^", binary("^", num("2"), num("3")), num("2")), "(2 ^ 3) ^ 2"},
+		{"negated power", &Node{Kind: Unary, Value: Neg, Args: []*Node{binary("^", num("2"), num("2"))}}, "-2 ^ 2"},
+		{"power of negation", binary("^", &Node{Kind: Unary, Value: Neg, Args: []*Node{num("2")}}, num("2")), "(-2) ^ 2"},
+		{"negative literal", binary("^", num("-2"), num("2")), "(-2) ^ 2"},
+		{"fraction literal", binary("^", num("2"), num("1/3")), "2 ^ (1/3)"},
+		{"complex literal", binary("*", num("2"), num("11-2e-3i")), "2 * (11-2e-3i)"},
+		{"imaginary literal", binary("*", num("-2e-3i"), num("2")), "-2e-3i * 2"},
+		{"not", &Node{Kind: Unary, Value: Not, Args: []*Node{binary("<", num("1"), num("2"))}}, "!(1 < 2)"},
+		{"call", &Node{Kind: Call, Value: "max", Args: []*Node{num("1"), binary("+", num("2"), &Node{Kind: Variable, Value: "x"})}}, "max(1, 2 + x)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.node.String(); got != tt.expected {
+				t.Errorf("String() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWalk(t *testing.T) {
+	call := &Node{Kind: Call, Value: "min", Args: []*Node{num("3"), num("4")}}
+	tree := binary("+", binary("*", num("1"), num("2")), call)
+
+	var visited []string
+	Walk(tree, func(node *Node) bool {
+		visited = append(visited, node.Value)
+		return node != call
+	})
+
+	expected := []string{"+", "*", "1", "2", "min"}
+	if !slices.Equal(visited, expected) {
+		t.Errorf("Walk() visited %v, expected %v", visited, expected)
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	data, err := json.Marshal(binary("+", num("1"), num("2")))
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	expected := `{"kind":"binary","value":"+","args":[{"kind":"literal","value":"1","start":0,"end":0},{"kind":"literal","value":"2","start":0,"end":0}],"start":0,"end":0}`
+	if string(data) != expected {
+		t.Errorf("Marshal() = %s, expected %s", data, expected)
+	}
+}
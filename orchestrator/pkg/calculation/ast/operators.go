@@ -0,0 +1,50 @@
+package ast
+
+// Unary operators are named apart from their binary spelling.
+const (
+	Neg = "neg"
+	Not = "not"
+)
+
+// ConvertTo converts a quantity to the unit on its right, as in 3 ft to m.
+const ConvertTo = "to"
+
+// precedence of the operators; higher binds tighter.
+var precedence = map[string]int{
+	ConvertTo: 1,
+	"||":      2,
+	"&&":      3,
+	"==":      4, "!=": 4, "<": 4, "<=": 4, ">": 4, ">=": 4,
+	"|":   5,
+	"xor": 6,
+	"&":   7,
+	"<<":  8, ">>": 8,
+	"+": 9, "-": 9,
+	"*": 10, "/": 10, "//": 10, "%": 10,
+	Neg: 11, Not: 11,
+	"^": 12,
+}
+
+// maxPrecedence is how tightly literals, variables and calls bind.
+const maxPrecedence = 13
+
+// Precedence returns how tightly operator binds, or 0 if it is not an
+// operator.
+func Precedence(operator string) int {
+	return precedence[operator]
+}
+
+func IsRightAssociative(operator string) bool {
+	return operator == "^"
+}
+
+// Spelling returns how an operator is written in an expression.
+func Spelling(operator string) string {
+	switch operator {
+	case Neg:
+		return "-"
+	case Not:
+		return "!"
+	}
+	return operator
+}
@@ -1,107 +1,430 @@
 package calculation
 
 import (
+	"cmp"
 	"fmt"
 	"math"
-	"strconv"
 	"strings"
 	"unicode"
+
+	"github.com/DobryySoul/orchestrator/pkg/calculation/ast"
+)
+
+const (
+	UnaryMinus = ast.Neg
+	UnaryNot   = ast.Not
 )
 
+// unaryPlus marks a unary plus while tokenizing. It changes nothing and is
+// dropped from the RPN.
+const unaryPlus = "pos"
+
+// compoundOperators are spelled with two adjacent operator characters.
+var compoundOperators = map[string]string{
+	"**": "^",
+	"//": "//",
+	"<<": "<<",
+	">>": ">>",
+	"<=": "<=",
+	">=": ">=",
+	"==": "==",
+	"!=": "!=",
+	"&&": "&&",
+	"||": "||",
+}
+
+type Options struct {
+	Variables        map[string]float64
+	Resolve          Resolver
+	Precision        string
+	Digits           int
+	NumberMode       string
+	DecimalSeparator string
+	Locale           string // picks the decimal separator when DecimalSeparator is empty
+	NonFinite        string // policy for infinite and NaN results
+}
+
+// separator returns the decimal separator the expression is written with.
+func (opts Options) separator() string {
+	if opts.DecimalSeparator == "" && opts.Locale != "" {
+		return locales[opts.Locale].decimal
+	}
+	return opts.DecimalSeparator
+}
+
+// Exact reports whether opts ask for exact rather than float arithmetic.
+func (opts Options) Exact() bool {
+	return opts.Precision == PrecisionExact || opts.NumberMode == NumberModeRational
+}
+
 func RPN(expression string) ([]string, error) {
+	return RPNWithOptions(expression, Options{})
+}
+
+func RPNWithVariables(expression string, variables map[string]float64) ([]string, error) {
+	return RPNWithOptions(expression, Options{Variables: variables})
+}
+
+func RPNWithOptions(expression string, opts Options) ([]string, error) {
+	output, err := compile(expression, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return values(output), nil
+}
+
+// compile converts expression to RPN with names bound and checks that it
+// can be evaluated.
+func compile(expression string, opts Options) ([]token, error) {
 	if len(expression) == 0 {
 		return nil, ErrEmptyExpression
 	}
 
-	tokens, err := createToken(expression)
+	if opts.Precision != "" && opts.Precision != PrecisionFloat && opts.Precision != PrecisionExact {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownPrecision, opts.Precision)
+	}
+
+	if opts.NumberMode != "" && opts.NumberMode != NumberModeFloat && opts.NumberMode != NumberModeRational {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownNumberMode, opts.NumberMode)
+	}
+
+	if opts.DecimalSeparator != "" && opts.DecimalSeparator != DecimalPoint && opts.DecimalSeparator != DecimalComma {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSeparator, opts.DecimalSeparator)
+	}
+
+	if _, ok := locales[opts.Locale]; opts.Locale != "" && !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownLocale, opts.Locale)
+	}
+
+	if !ValidNonFinite(opts.NonFinite) {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownPolicy, opts.NonFinite)
+	}
+
+	if err := validateVariables(opts.Variables); err != nil {
+		return nil, fmt.Errorf("error while binding variables: %w", err)
+	}
+
+	tokens, err := createToken(normalize(expression, opts.separator()), opts.Variables)
 	if err != nil {
-		return nil, fmt.Errorf("error while creating tokens: %w", err)
+		return nil, fmt.Errorf("error while creating tokens: %w", withSource(err, expression))
 	}
 
 	output, err := convertingAnExpression(tokens)
 	if err != nil {
-		return nil, fmt.Errorf("error while converting expression: %w", err)
+		return nil, fmt.Errorf("error while converting expression: %w", withSource(err, expression))
+	}
+
+	output, err = bindVariables(output, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error while binding variables: %w", withSource(err, expression))
 	}
 
-	_, err = evaluateRPN(output)
+	if tok, ok := imaginaryLiteral(output); ok && opts.Exact() {
+		err = errorAt(ErrComplexOperand, tok, "complex numbers are computed with float precision")
+	} else if tok, ok := matrixToken(output); ok && opts.Exact() {
+		err = errorAt(ErrMatrixOperand, tok, "matrices are computed with float precision")
+	} else if tok, ok := unitToken(output); ok && opts.Exact() {
+		err = errorAt(ErrQuantityOperand, tok, "units are computed with float precision")
+	} else if tok, ok := irrationalToken(output); ok && opts.NumberMode == NumberModeRational {
+		err = errorAt(ErrIrrationalOperation, tok, `use "precision": "exact" for irrational functions`)
+	} else if hasPendingReferences(output) {
+		err = checkStructure(output)
+	} else if opts.Exact() {
+		_, err = evaluateRPNExact(output, opts.Digits, opts.NumberMode == NumberModeRational)
+	} else {
+		_, err = evaluateRPN(output, opts.NonFinite)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("error while evaluating RPN: %w", err)
+		return nil, fmt.Errorf("error while evaluating RPN: %w", withSource(err, expression))
 	}
 
 	return output, nil
 }
 
-func createToken(expression string) ([]string, error) {
-	var tokens []string
-	var number strings.Builder
+// token is a lexeme together with its rune offset in the source expression,
+// so that later stages can report where a problem is.
+type token struct {
+	value string
+	pos   int
+}
 
-	for _, ch := range expression {
-		if unicode.IsDigit(ch) || ch == '.' {
-			number.WriteRune(ch)
-		} else {
-			if number.Len() > 0 {
-				if err := validateNumber(number.String()); err != nil {
-					return nil, err
-				}
-				tokens = append(tokens, number.String())
-				number.Reset()
+func values(tokens []token) []string {
+	result := make([]string, len(tokens))
+	for i, tok := range tokens {
+		result[i] = tok.value
+	}
+	return result
+}
+
+// createToken splits expression into tokens. A name written after a number
+// is a unit unless it is one of variables, so binding m keeps 2 m a product.
+func createToken(expression string, variables map[string]float64) ([]token, error) {
+	var tokens []token
+	var ident strings.Builder
+	var start int
+
+	// multiply inserts the * left out between two operands
+	multiply := func(pos int, afterNumber bool) {
+		if len(tokens) == 0 {
+			return
+		}
+		last := tokens[len(tokens)-1]
+		if last.value == ")" || afterNumber && endsOperand(last) {
+			tokens = append(tokens, token{"*", pos})
+		}
+	}
+
+	flush := func() error {
+		if ident.Len() > 0 {
+			tok := token{ident.String(), start}
+			if tok.value == convertToIn {
+				tok.value = ConvertTo
 			}
-			if !unicode.IsSpace(ch) {
-				if !isValidOperator(ch) && ch != '(' && ch != ')' {
-					return nil, ErrInvalidCharacter
-				}
-				tokens = append(tokens, string(ch))
+			if IsUnaryOperator(tok.value) {
+				return errorAt(ErrReservedName, tok, "choose another name")
 			}
+			if IsBinaryOperator(tok.value) && expectsOperand(tokens) {
+				return errorAt(ErrNotEnoughOperands, tok, fmt.Sprintf("add an operand before %q", tok.value))
+			}
+			if !IsBinaryOperator(tok.value) {
+				multiply(tok.pos, true)
+			}
+			tokens = append(tokens, tok)
+			ident.Reset()
 		}
+		return nil
 	}
 
-	if number.Len() > 0 {
-		if err := validateNumber(number.String()); err != nil {
+	runes := []rune(expression)
+	for pos := 0; pos < len(runes); pos++ {
+		ch := runes[pos]
+
+		switch {
+		case ident.Len() > 0 && (unicode.IsLetter(ch) || unicode.IsDigit(ch) || ch == '_'):
+			ident.WriteRune(ch)
+			continue
+		case isDigit(ch) || ch == '.':
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			end := scanNumber(runes, pos)
+			value, err := parseNumber(token{string(runes[pos:end]), pos})
+			if err != nil {
+				return nil, err
+			}
+			if isImaginary(runes, end) {
+				value += ImaginaryUnit
+				end++
+			} else if unitEnd, unit, ok := unitSuffix(runes, end, variables); ok {
+				value += " " + unit
+				end = unitEnd
+			}
+			multiply(pos, false)
+			tokens = append(tokens, token{value, pos})
+			pos = end - 1
+			continue
+		case ch == '[':
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			matrix, end, err := scanMatrix(runes, pos)
+			if err != nil {
+				return nil, err
+			}
+			multiply(pos, true)
+			tokens = append(tokens, token{matrix.String(), pos})
+			pos = end - 1
+			continue
+		case unicode.IsLetter(ch) || ch == '_':
+			start = pos
+			ident.WriteRune(ch)
+			continue
+		case ch == '$':
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			end := pos + 1
+			for end < len(runes) && isDigit(runes[end]) {
+				end++
+			}
+			tok := token{string(runes[pos:end]), pos}
+			if end == pos+1 {
+				return nil, errorAt(ErrUnknownReference, tok, "use $N to refer to expression N")
+			}
+			multiply(pos, true)
+			tokens = append(tokens, tok)
+			pos = end - 1
+			continue
+		}
+
+		if err := flush(); err != nil {
 			return nil, err
 		}
-		tokens = append(tokens, number.String())
+		if len(tokens) > 0 && tokens[len(tokens)-1].value == ConvertTo {
+			end, target, err := scanTarget(runes, pos, tokens[len(tokens)-1])
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, target)
+			pos = end - 1
+			continue
+		}
+
+		if unicode.IsSpace(ch) {
+			continue
+		}
+		tok := token{string(ch), pos}
+		if !isValidOperator(ch) && ch != '(' && ch != ')' && ch != ',' {
+			return nil, errorAt(ErrInvalidCharacter, tok, "remove it")
+		}
+		if (ch == '-' || ch == '+' || ch == '!') && expectsOperand(tokens) {
+			switch ch {
+			case '-':
+				tok.value = UnaryMinus
+			case '+':
+				tok.value = unaryPlus
+			case '!':
+				tok.value = UnaryNot
+			}
+			tokens = append(tokens, tok)
+			continue
+		}
+		if pos > 0 && len(tokens) > 0 && tokens[len(tokens)-1].value == string(runes[pos-1]) {
+			if compound, ok := compoundOperators[string(runes[pos-1:pos+1])]; ok {
+				tokens[len(tokens)-1].value = compound
+				continue
+			}
+		}
+		if isValidOperator(ch) && expectsOperand(tokens) {
+			return nil, errorAt(ErrNotEnoughOperands, tok, fmt.Sprintf("add an operand before %q", tok.value))
+		}
+		if ch == '(' {
+			multiply(pos, true)
+		}
+		if ch == ')' || ch == ',' {
+			if op, ok := danglingOperator(tokens); ok {
+				return nil, errorAt(ErrNotEnoughOperands, op, fmt.Sprintf("add an operand after %q", sourceText(op.value)))
+			}
+		}
+		tokens = append(tokens, tok)
+	}
+
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	if op, ok := danglingOperator(tokens); ok && op.value == ConvertTo {
+		return nil, missingTarget(runes, op)
+	} else if ok {
+		return nil, errorAt(ErrNotEnoughOperands, op, fmt.Sprintf("add an operand after %q", sourceText(op.value)))
 	}
 
 	return tokens, nil
 }
 
-func convertingAnExpression(tokens []string) ([]string, error) {
-	var output []string
-	var operators []string
-	priority := map[string]int{
-		"+": 1, "-": 1,
-		"*": 2, "/": 2,
+func expectsOperand(tokens []token) bool {
+	if len(tokens) == 0 {
+		return true
 	}
 
-	for _, token := range tokens {
-		if _, err := strconv.ParseFloat(token, 64); err == nil {
-			output = append(output, token)
-		} else if token == "(" {
-			operators = append(operators, token)
-		} else if token == ")" {
-			for len(operators) > 0 && operators[len(operators)-1] != "(" {
+	last := tokens[len(tokens)-1].value
+
+	return last == "(" || last == "," || IsUnaryOperator(last) || IsBinaryOperator(last)
+}
+
+// danglingOperator reports an operator that is still waiting for its right
+// operand at the end of the tokens read so far.
+func danglingOperator(tokens []token) (token, bool) {
+	if len(tokens) == 0 {
+		return token{}, false
+	}
+
+	last := tokens[len(tokens)-1]
+	if IsUnaryOperator(last.value) || IsBinaryOperator(last.value) {
+		return last, true
+	}
+
+	return token{}, false
+}
+
+func convertingAnExpression(tokens []token) ([]token, error) {
+	var output []token
+	var operators []token
+	var argCounts []int
+
+	for i, tok := range tokens {
+		if isLiteral(tok.value) {
+			output = append(output, tok)
+		} else if tok.value == unaryPlus {
+			continue
+		} else if IsFunction(tok.value) {
+			if i+1 >= len(tokens) || tokens[i+1].value != "(" {
+				return nil, errorAt(ErrInvalidExpression, tok, fmt.Sprintf("call it as %s(...)", tok.value))
+			}
+			operators = append(operators, tok)
+		} else if tok.value == "(" {
+			if len(operators) > 0 && IsFunction(operators[len(operators)-1].value) {
+				argc := 1
+				if i+1 < len(tokens) && tokens[i+1].value == ")" {
+					argc = 0
+				}
+				argCounts = append(argCounts, argc)
+			}
+			operators = append(operators, tok)
+		} else if tok.value == UnaryMinus || tok.value == UnaryNot {
+			operators = append(operators, tok)
+		} else if (isIdentifier(tok.value) && !IsBinaryOperator(tok.value)) || IsReference(tok.value) {
+			if i+1 < len(tokens) && tokens[i+1].value == "(" {
+				return nil, errorAt(ErrUnknownFunction, tok, "use one of: "+functionNames())
+			}
+			output = append(output, tok)
+		} else if tok.value == "," {
+			for len(operators) > 0 && operators[len(operators)-1].value != "(" {
+				output = append(output, operators[len(operators)-1])
+				operators = operators[:len(operators)-1]
+			}
+			if len(operators) < 2 || !IsFunction(operators[len(operators)-2].value) {
+				return nil, errorAt(ErrInvalidExpression, tok, "commas may only separate function arguments")
+			}
+			argCounts[len(argCounts)-1]++
+		} else if tok.value == ")" {
+			for len(operators) > 0 && operators[len(operators)-1].value != "(" {
 				output = append(output, operators[len(operators)-1])
 				operators = operators[:len(operators)-1]
 			}
 			if len(operators) == 0 {
-				return nil, ErrMismatchedParentheses
+				return nil, errorAt(ErrMismatchedParentheses, tok, `remove the unmatched ")"`)
 			}
 			operators = operators[:len(operators)-1]
+
+			if len(operators) > 0 && IsFunction(operators[len(operators)-1].value) {
+				call := operators[len(operators)-1]
+				operators = operators[:len(operators)-1]
+
+				argc := argCounts[len(argCounts)-1]
+				argCounts = argCounts[:len(argCounts)-1]
+
+				if err := checkArgumentCount(call.value, argc); err != nil {
+					return nil, errorAt(err, call, arityHint(call.value))
+				}
+				output = append(output, token{FormatCall(call.value, argc), call.pos})
+			}
 		} else {
-			if _, ok := priority[token]; !ok {
-				return nil, ErrUnknownOperator
+			if ast.Precedence(tok.value) == 0 {
+				return nil, errorAt(ErrUnknownOperator, tok, operatorHint(tok.value))
 			}
-			for len(operators) > 0 && priority[operators[len(operators)-1]] >= priority[token] {
+			for len(operators) > 0 && (ast.Precedence(operators[len(operators)-1].value) > ast.Precedence(tok.value) ||
+				ast.Precedence(operators[len(operators)-1].value) == ast.Precedence(tok.value) && !ast.IsRightAssociative(tok.value)) {
 				output = append(output, operators[len(operators)-1])
 				operators = operators[:len(operators)-1]
 			}
-			operators = append(operators, token)
+			operators = append(operators, tok)
 		}
 	}
 
 	for len(operators) > 0 {
-		if operators[len(operators)-1] == "(" {
-			return nil, ErrMismatchedParentheses
+		if operators[len(operators)-1].value == "(" {
+			return nil, errorAt(ErrMismatchedParentheses, operators[len(operators)-1], `add a closing ")"`)
 		}
 		output = append(output, operators[len(operators)-1])
 		operators = operators[:len(operators)-1]
@@ -110,63 +433,203 @@ func convertingAnExpression(tokens []string) ([]string, error) {
 	return output, nil
 }
 
-func validateNumber(number string) error {
-	dotCount := 0
-	for _, ch := range number {
-		if ch == '.' {
-			dotCount++
-			if dotCount > 1 {
-				return ErrInvalidNumber
-			}
-		} else if !unicode.IsDigit(ch) {
-			return ErrInvalidNumber
+func isValidOperator(ch rune) bool {
+	operators := "+-*/^%&|<>=!"
+	return strings.ContainsRune(operators, ch)
+}
+
+func operatorHint(operator string) string {
+	switch operator {
+	case "=":
+		return `use "==" to compare`
+	case "!":
+		return `use "!" before an operand or "!=" to compare`
+	}
+	return ""
+}
+
+func isIdentifier(token string) bool {
+	for i, ch := range token {
+		if !unicode.IsLetter(ch) && ch != '_' && (i == 0 || !unicode.IsDigit(ch)) {
+			return false
 		}
 	}
-	return nil
+	return token != ""
 }
 
-func isValidOperator(ch rune) bool {
-	operators := "+-*/"
-	return strings.ContainsRune(operators, ch)
+func IsUnaryOperator(token string) bool {
+	return token == UnaryMinus || token == unaryPlus || token == UnaryNot
 }
 
-func evaluateRPN(tokens []string) ([]string, error) {
-	var stack []float64
+func IsBinaryOperator(token string) bool {
+	return ast.Precedence(token) > 0 && !IsUnaryOperator(token)
+}
 
-	for _, token := range tokens {
-		if num, err := strconv.ParseFloat(token, 64); err == nil {
-			stack = append(stack, num)
-		} else {
-			if len(stack) < 2 {
-				return nil, ErrNotEnoughOperands
-			}
-			b := stack[len(stack)-1]
-			a := stack[len(stack)-2]
-			stack = stack[:len(stack)-2]
-
-			switch token {
-			case "+":
-				stack = append(stack, a+b)
-			case "-":
-				stack = append(stack, a-b)
-			case "*":
-				stack = append(stack, a*b)
-			case "/":
-				if b == 0 {
-					return nil, ErrDivisionByZero
-				}
-				stack = append(stack, a/b)
-			case "^":
-				stack = append(stack, math.Pow(a, b))
-			default:
-				return nil, ErrUnknownOperator
+// evaluateRPN computes the result to validate the expression. Errors are
+// attached to the value they produce and only reported if that value is
+// used, so that the branch not taken by if() cannot fail the expression.
+func evaluateRPN(tokens []token, nonFinite string) ([]string, error) {
+	var stack []value
+	var deferred []error
+	var starts []token
+
+	for _, tok := range tokens {
+		if literal, ok := parseValue(tok.value); ok {
+			stack = append(stack, literal)
+			deferred = append(deferred, nil)
+			starts = append(starts, tok)
+			continue
+		}
+
+		argc := 2
+		if _, n, ok := ParseCall(tok.value); ok {
+			argc = n
+		} else if IsUnaryOperator(tok.value) {
+			argc = 1
+		}
+		if len(stack) < argc {
+			return nil, errorAt(ErrNotEnoughOperands, tok, "")
+		}
+		args := make([]value, argc)
+		copy(args, stack[len(stack)-argc:])
+		errs := make([]error, argc)
+		copy(errs, deferred[len(deferred)-argc:])
+		stack = stack[:len(stack)-argc]
+		deferred = deferred[:len(deferred)-argc]
+		starts = reduceStarts(starts, tok, argc)
+
+		if name, _, _ := ParseCall(tok.value); name == If {
+			if args[0].matrix != nil {
+				errs[0] = firstError(errs[0], errorAt(ErrMatrixOperand, tok, "use a number as the condition"))
 			}
+			branch := chooseBranch(args[0].number != 0)
+			stack = append(stack, args[branch])
+			deferred = append(deferred, firstError(errs[0], errs[branch]))
+			continue
+		}
+
+		if err := firstError(errs...); err != nil {
+			stack = append(stack, value{})
+			deferred = append(deferred, err)
+			continue
+		}
+
+		result, err := applyValue(tok, args)
+		if err == nil {
+			result, err = applyNonFinite(tok, result, nonFinite)
 		}
+		stack = append(stack, result)
+		deferred = append(deferred, err)
+	}
+
+	if err := checkSingleResult(starts); err != nil {
+		return nil, err
+	}
+	if deferred[0] != nil {
+		return nil, deferred[0]
+	}
+
+	return []string{stack[0].String()}, nil
+}
+
+func applyFloat(tok token, args []float64) (float64, error) {
+	if name, _, ok := ParseCall(tok.value); ok {
+		return functions[name].apply(args), nil
 	}
 
-	if len(stack) != 1 {
-		return nil, ErrInvalidExpression
+	switch tok.value {
+	case UnaryMinus:
+		return -args[0], nil
+	case UnaryNot:
+		return boolean(args[0] == 0), nil
+	case "+":
+		return args[0] + args[1], nil
+	case "-":
+		return args[0] - args[1], nil
+	case "*":
+		return args[0] * args[1], nil
+	case "/":
+		if args[1] == 0 {
+			return 0, errorAt(ErrDivisionByZero, tok, "change the divisor")
+		}
+		return args[0] / args[1], nil
+	case "^":
+		return math.Pow(args[0], args[1]), nil
+	case "%", "//":
+		if args[1] == 0 {
+			return 0, errorAt(ErrDivisionByZero, tok, "change the divisor")
+		}
+		if tok.value == "%" {
+			return floorModulo(args[0], args[1]), nil
+		}
+		return math.Floor(args[0] / args[1]), nil
+	case "&", "|", "xor", "<<", ">>":
+		result, err := bitwise(tok.value, args[0], args[1])
+		if err != nil {
+			return 0, errorAt(err, tok, integerHint(err))
+		}
+		return result, nil
+	case "==", "!=", "<", "<=", ">", ">=":
+		return boolean(compare(tok.value, cmp.Compare(args[0], args[1]))), nil
+	case "&&":
+		return boolean(args[0] != 0 && args[1] != 0), nil
+	case "||":
+		return boolean(args[0] != 0 || args[1] != 0), nil
 	}
 
-	return []string{strconv.FormatFloat(stack[0], 'f', -1, 64)}, nil
+	return 0, errorAt(ErrUnknownOperator, tok, "")
+}
+
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reduceStarts replaces the first tokens of the argc operands consumed by op
+// with the first token of the resulting subexpression.
+func reduceStarts(starts []token, op token, argc int) []token {
+	first := op
+	if argc > 0 && starts[len(starts)-argc].pos < op.pos {
+		first = starts[len(starts)-argc]
+	}
+	return append(starts[:len(starts)-argc], first)
+}
+
+// checkStructure verifies operand counts without evaluating, for RPN whose
+// references are not resolved yet.
+func checkStructure(tokens []token) error {
+	var starts []token
+
+	for _, tok := range tokens {
+		argc := 2
+		if _, n, ok := ParseCall(tok.value); ok {
+			argc = n
+		} else if IsUnaryOperator(tok.value) {
+			argc = 1
+		} else if !IsBinaryOperator(tok.value) {
+			starts = append(starts, tok)
+			continue
+		}
+
+		if len(starts) < argc {
+			return errorAt(ErrNotEnoughOperands, tok, "")
+		}
+		starts = reduceStarts(starts, tok, argc)
+	}
+
+	return checkSingleResult(starts)
+}
+
+func checkSingleResult(starts []token) error {
+	switch {
+	case len(starts) == 0:
+		return errorAt(ErrInvalidExpression, token{}, "add an operand")
+	case len(starts) > 1:
+		return errorAt(ErrInvalidExpression, starts[1], fmt.Sprintf("add an operator before %q", sourceText(starts[1].value)))
+	}
+	return nil
 }
@@ -1,6 +1,7 @@
 package calculation
 
 import (
+	"errors"
 	"testing"
 )
 
@@ -53,6 +54,138 @@ func TestRPN(t *testing.T) {
 			expected:    []string{"3", "4", "+", "2", "1", "-", "*"},
 			expectError: false,
 		},
+		{
+			name:        "leading unary minus",
+			expression:  "-3 + 5",
+			expected:    []string{"3", "neg", "5", "+"},
+			expectError: false,
+		},
+		{
+			name:        "unary minus after operator",
+			expression:  "2 * (-4)",
+			expected:    []string{"2", "4", "neg", "*"},
+			expectError: false,
+		},
+		{
+			name:        "unary minus in parentheses",
+			expression:  "(-1)",
+			expected:    []string{"1", "neg"},
+			expectError: false,
+		},
+		{
+			name:        "unary minus binds tighter than multiplication",
+			expression:  "-2 * 3",
+			expected:    []string{"2", "neg", "3", "*"},
+			expectError: false,
+		},
+		{
+			name:        "double negation",
+			expression:  "5 - -2",
+			expected:    []string{"5", "2", "neg", "-"},
+			expectError: false,
+		},
+		{
+			name:        "unary plus is dropped",
+			expression:  "+3 * +2",
+			expected:    []string{"3", "2", "*"},
+			expectError: false,
+		},
+		{
+			name:        "negated parentheses",
+			expression:  "-(2 + 3)",
+			expected:    []string{"2", "3", "+", "neg"},
+			expectError: false,
+		},
+		{
+			name:        "power has higher precedence than multiplication",
+			expression:  "2 * 3 ^ 2",
+			expected:    []string{"2", "3", "2", "^", "*"},
+			expectError: false,
+		},
+		{
+			name:        "power is right associative",
+			expression:  "2 ^ 3 ^ 2",
+			expected:    []string{"2", "3", "2", "^", "^"},
+			expectError: false,
+		},
+		{
+			name:        "double asterisk is power",
+			expression:  "2 ** 3",
+			expected:    []string{"2", "3", "^"},
+			expectError: false,
+		},
+		{
+			name:        "separated asterisks are not power",
+			expression:  "2 * * 3",
+			expected:    nil,
+			expectError: true,
+		},
+		{
+			name:        "power binds tighter than unary minus",
+			expression:  "-2 ^ 2",
+			expected:    []string{"2", "2", "^", "neg"},
+			expectError: false,
+		},
+		{
+			name:        "negative exponent",
+			expression:  "2 ^ -1",
+			expected:    []string{"2", "1", "neg", "^"},
+			expectError: false,
+		},
+		{
+			name:        "function call",
+			expression:  "sqrt(16) + 1",
+			expected:    []string{"16", "sqrt:1", "1", "+"},
+			expectError: false,
+		},
+		{
+			name:        "variadic function call",
+			expression:  "max(2, 7, 3)",
+			expected:    []string{"2", "7", "3", "max:3"},
+			expectError: false,
+		},
+		{
+			name:        "nested function calls with expressions",
+			expression:  "min(abs(-2), 1 + 2) * 2",
+			expected:    []string{"2", "neg", "abs:1", "1", "2", "+", "min:2", "2", "*"},
+			expectError: false,
+		},
+		{
+			name:        "unknown function",
+			expression:  "foo(1)",
+			expected:    nil,
+			expectError: true,
+		},
+		{
+			name:        "wrong number of arguments",
+			expression:  "sqrt(1, 2)",
+			expected:    nil,
+			expectError: true,
+		},
+		{
+			name:        "empty argument list",
+			expression:  "max()",
+			expected:    nil,
+			expectError: true,
+		},
+		{
+			name:        "comma outside of function call",
+			expression:  "(1, 2)",
+			expected:    nil,
+			expectError: true,
+		},
+		{
+			name:        "function without parentheses",
+			expression:  "sqrt 4",
+			expected:    nil,
+			expectError: true,
+		},
+		{
+			name:        "lone unary minus",
+			expression:  "-",
+			expected:    nil,
+			expectError: true,
+		},
 		{
 			name:        "mismatched parentheses 1",
 			expression:  "(3 + 4) * (2 - 1",
@@ -71,6 +204,78 @@ func TestRPN(t *testing.T) {
 			expected:    nil,
 			expectError: true,
 		},
+		{
+			name:        "scientific notation",
+			expression:  "6.02e23 * 1E-9",
+			expected:    []string{"6.02e23", "1E-9", "*"},
+			expectError: false,
+		},
+		{
+			name:        "radix literals",
+			expression:  "0xFF + 0b1010 - 0o17",
+			expected:    []string{"255", "10", "+", "15", "-"},
+			expectError: false,
+		},
+		{
+			name:        "digit separators",
+			expression:  "1_000_000 + 0xFF_FF + 1_0.5e1_0",
+			expected:    []string{"1000000", "65535", "+", "10.5e10", "+"},
+			expectError: false,
+		},
+		{
+			name:        "exponent followed by operator",
+			expression:  "2e3-1",
+			expected:    []string{"2e3", "1", "-"},
+			expectError: false,
+		},
+		{
+			name:        "modulo and floor division share multiplicative precedence",
+			expression:  "1 + 7 // 2 % 3",
+			expected:    []string{"1", "7", "2", "//", "3", "%", "+"},
+			expectError: false,
+		},
+		{
+			name:        "bitwise precedence",
+			expression:  "1 | 2 xor 3 & 4 << 1 + 1",
+			expected:    []string{"1", "2", "3", "4", "1", "1", "+", "<<", "&", "xor", "|"},
+			expectError: false,
+		},
+		{
+			name:        "xor needs a left operand",
+			expression:  "xor 1",
+			expected:    nil,
+			expectError: true,
+		},
+		{
+			name:        "comparison and logic precedence",
+			expression:  "1 + 1 >= 2 && 3 != 0 || !0",
+			expected:    []string{"1", "1", "+", "2", ">=", "3", "0", "!=", "&&", "0", "not", "||"},
+			expectError: false,
+		},
+		{
+			name:        "conditional",
+			expression:  "if(5 > 10, 5 * 0.9, 5)",
+			expected:    []string{"5", "10", ">", "5", "0.9", "*", "5", "if:3"},
+			expectError: false,
+		},
+		{
+			name:        "conditional needs three arguments",
+			expression:  "if(1, 2)",
+			expected:    nil,
+			expectError: true,
+		},
+		{
+			name:        "single equals sign",
+			expression:  "1 = 2",
+			expected:    nil,
+			expectError: true,
+		},
+		{
+			name:        "malformed hex literal",
+			expression:  "0x1G",
+			expected:    nil,
+			expectError: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -92,6 +297,188 @@ func TestRPN(t *testing.T) {
 	}
 }
 
+func TestEvaluateRPN(t *testing.T) {
+	tests := []struct {
+		expression string
+		expected   string
+	}{
+		{"-3 + 5", "2"},
+		{"2 * (-4)", "-8"},
+		{"(-1)", "-1"},
+		{"-(2 + 3) * 2", "-10"},
+		{"- - 3", "3"},
+		{"+4", "4"},
+		{"2 ^ 3 ^ 2", "512"},
+		{"1.5e3 + 0x10", "1516"},
+		{"0b11 * 2.5E-1", "0.75"},
+		{"-7 % 3", "2"},
+		{"7 % -3", "-2"},
+		{"-7 // 2", "-4"},
+		{"6 & 3 | 8", "10"},
+		{"5 xor 1", "4"},
+		{"1 << 10", "1024"},
+		{"-9 >> 1", "-5"},
+		{"2 + 3 << 1", "10"},
+		{"1 < 2", "1"},
+		{"2 <= 1", "0"},
+		{"1 + 2 == 3", "1"},
+		{"!0 && !!2", "1"},
+		{"!(1 == 1) || 3 > 2", "1"},
+		{"0.1 + 0.2 == 0.3", "0"},
+		{"if(2 > 1, 10, 20)", "10"},
+		{"if(0, 1 / 0, 7)", "7"},
+		{"if(1, if(0, 1, 2), 3) * 2", "4"},
+		{"2 ** -1", "0.5"},
+		{"-2 ^ 2", "-4"},
+		{"(-2) ^ 2", "4"},
+		{"sqrt(16) + max(2, 7, 3)", "11"},
+		{"min(4, -1, 2)", "-1"},
+		{"abs(-2.5)", "2.5"},
+		{"cos(0) + sin(0) + log(1)", "1"},
+		{"(3+4i)*(1-2i)", "11-2i"},
+		{"sqrt(-4)", "2i"},
+		{"1i * 1i", "-1"},
+		{"abs(3 + 4i)", "5"},
+		{"(1+2i) / 2 - 0.5", "1i"},
+		{"2.5e3i == 2500i", "1"},
+		{"[1, 2, 3]", "[1,2,3]"},
+		{"[[1, 2], [3, 4]]", "[[1,2],[3,4]]"},
+		{"[1, -2.5, 0x10]", "[1,-2.5,16]"},
+		{"[1, 2] + [3, 4]", "[4,6]"},
+		{"[[1, 2], [3, 4]] - [[1, 1], [1, 1]]", "[[0,1],[2,3]]"},
+		{"2 * [1, 2] * 3", "[6,12]"},
+		{"-[[1, 2], [3, 4]]", "[[-1,-2],[-3,-4]]"},
+		{"dot([1, 2, 3], [4, 5, 6])", "32"},
+		{"[[1, 2], [3, 4]] * [[5, 6], [7, 8]]", "[[19,22],[43,50]]"},
+		{"[[1, 2, 3], [4, 5, 6]] * [1, 0, 1]", "[4,10]"},
+		{"[1, 1] * [[1, 2, 3], [4, 5, 6]]", "[5,7,9]"},
+		{"transpose([[1, 2, 3], [4, 5, 6]])", "[[1,4],[2,5],[3,6]]"},
+		{"det([[1, 2], [3, 4]])", "-2"},
+		{"det([[0, 2, 1], [1, 1, 1], [2, 0, 3]])", "-4"},
+		{"det([[1, 2], [2, 4]]) + 1", "1"},
+		{"if(1, [1], [2])", "[1]"},
+		{"5 km / 30 min to km/h", "10 km/h"},
+		{"3 ft to m", "0.9144 m"},
+		{"3 ft in m", "0.9144 m"},
+		{"2 km + 300 m", "2.3 km"},
+		{"9.81 m/s^2 * 2 s", "19.62 m/s"},
+		{"(1 h to min) * 2", "120 min"},
+		{"sqrt(16 m^2)", "4 m"},
+		{"1 km / 1 m", "1000"},
+		{"1 / 2 s", "0.5 s^-1"},
+		{"1 N*m to J", "1 J"},
+		{"5 km > 400 m", "1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			tokens, err := createToken(tt.expression, nil)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			rpn, err := convertingAnExpression(tokens)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			result, err := evaluateRPN(rpn, "")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result[0] != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result[0])
+			}
+		})
+	}
+}
+
+func TestRPNWithVariables(t *testing.T) {
+	tests := []struct {
+		name        string
+		expression  string
+		variables   map[string]float64
+		expected    []string
+		expectedErr error
+	}{
+		{
+			name:       "bound variables are substituted",
+			expression: "a*x+b",
+			variables:  map[string]float64{"a": 2, "x": 3, "b": 1},
+			expected:   []string{"2", "3", "*", "1", "+"},
+		},
+		{
+			name:       "negative and fractional values",
+			expression: "-rate * 2",
+			variables:  map[string]float64{"rate": -0.25},
+			expected:   []string{"-0.25", "neg", "2", "*"},
+		},
+		{
+			name:       "variable used as function argument",
+			expression: "max(x1, x_2)",
+			variables:  map[string]float64{"x1": 1, "x_2": 2},
+			expected:   []string{"1", "2", "max:2"},
+		},
+		{
+			name:        "unbound variable",
+			expression:  "a + b",
+			variables:   map[string]float64{"a": 1},
+			expectedErr: ErrUnboundVariable,
+		},
+		{
+			name:        "invalid variable name",
+			expression:  "1 + 1",
+			variables:   map[string]float64{"1x": 1},
+			expectedErr: ErrInvalidVariableName,
+		},
+		{
+			name:        "function name used as variable",
+			expression:  "sqrt + 1",
+			variables:   map[string]float64{"sqrt": 1},
+			expectedErr: ErrReservedName,
+		},
+		{
+			name:        "constant used as variable",
+			expression:  "pi + 1",
+			variables:   map[string]float64{"pi": 3},
+			expectedErr: ErrReservedName,
+		},
+		{
+			name:       "constants",
+			expression: "2 * pi + e",
+			expected:   []string{"2", constants["pi"], "*", constants["e"], "+"},
+		},
+		{
+			name:       "conditional on a variable",
+			expression: "if(x > 10, x*0.9, x)",
+			variables:  map[string]float64{"x": 20},
+			expected:   []string{"20", "10", ">", "20", "0.9", "*", "20", "if:3"},
+		},
+		{
+			name:        "variable called as function",
+			expression:  "f(2)",
+			variables:   map[string]float64{"f": 1},
+			expectedErr: ErrUnknownFunction,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := RPNWithVariables(tt.expression, tt.variables)
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Errorf("Expected error %v, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !compareSlices(result, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
 func compareSlices(a, b []string) bool {
 	if len(a) != len(b) {
 		return false
@@ -103,3 +490,144 @@ func compareSlices(a, b []string) bool {
 	}
 	return true
 }
+
+func TestParseError(t *testing.T) {
+	tests := []struct {
+		expression string
+		kind       error
+		code       string
+		position   int
+		token      string
+		caret      string
+	}{
+		{"2 + * 3", ErrNotEnoughOperands, "not_enough_operands", 4, "*", "    ^"},
+		{"2 # 3", ErrInvalidCharacter, "invalid_character", 2, "#", "  ^"},
+		{"(1 + 2", ErrMismatchedParentheses, "mismatched_parentheses", 0, "(", "^"},
+		{"1 + 2)", ErrMismatchedParentheses, "mismatched_parentheses", 5, ")", "     ^"},
+		{"1 + 1.2.3", ErrInvalidNumber, "invalid_number", 7, ".", "       ^"},
+		{"0b102", ErrInvalidNumber, "invalid_number", 4, "2", "    ^"},
+		{"0xFG", ErrInvalidNumber, "invalid_number", 3, "G", "   ^"},
+		{"2 * 0x", ErrInvalidNumber, "invalid_number", 4, "0x", "    ^^"},
+		{"1__000", ErrInvalidNumber, "invalid_number", 2, "_", "  ^"},
+		{"1_", ErrInvalidNumber, "invalid_number", 1, "_", " ^"},
+		{"1_.5", ErrInvalidNumber, "invalid_number", 1, "_", " ^"},
+		{"1e2.5", ErrInvalidNumber, "invalid_number", 3, ".", "   ^"},
+		{"1e400", ErrInvalidNumber, "invalid_number", 0, "1e400", "^^^^^"},
+		{"1e-99999", ErrInvalidNumber, "invalid_number", 3, "99999", "   ^^^^^"},
+		{"1e", ErrInvalidNumber, "invalid_number", 0, "1e", "^^"},
+		{"1e+", ErrInvalidNumber, "invalid_number", 0, "1e+", "^^^"},
+		{"2 * 1.5E", ErrInvalidNumber, "invalid_number", 4, "1.5E", "    ^^^^"},
+		{".", ErrInvalidNumber, "invalid_number", 0, ".", "^"},
+		{"1 + foo(2)", ErrUnknownFunction, "unknown_function", 4, "foo", "    ^^^"},
+		{"sqrt(1, 2)", ErrWrongArgumentCount, "wrong_argument_count", 0, "sqrt", "^^^^"},
+		{"4 / (2 - 2)", ErrDivisionByZero, "division_by_zero", 2, "/", "  ^"},
+		{"(2 -)", ErrNotEnoughOperands, "not_enough_operands", 3, "-", "   ^"},
+		{"2 3", ErrInvalidExpression, "invalid_expression", 2, "3", "  ^"},
+		{"√2 + x", ErrInvalidCharacter, "invalid_character", 0, "√", "^"},
+		{"1 + x", ErrUnboundVariable, "unbound_variable", 4, "x", "    ^"},
+		{"2 * $", ErrUnknownReference, "unknown_reference", 4, "$", "    ^"},
+		{"1.5 & 1", ErrNonIntegerOperand, "non_integer_operand", 4, "&", "    ^"},
+		{"1 << -1", ErrInvalidShift, "invalid_shift", 2, "<<", "  ^^"},
+		{"5 // 0", ErrDivisionByZero, "division_by_zero", 2, "//", "  ^^"},
+		{"if(1, 1 / 0, 2)", ErrDivisionByZero, "division_by_zero", 8, "/", "        ^"},
+		{"1 = 2", ErrUnknownOperator, "unknown_operator", 2, "=", "  ^"},
+		{"2 * xor", ErrNotEnoughOperands, "not_enough_operands", 4, "xor", "    ^^^"},
+		{"ans + 1", ErrUnknownReference, "unknown_reference", 0, "ans", "^^^"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			_, err := RPN(tt.expression)
+
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("Expected ParseError, got %v", err)
+			}
+			if !errors.Is(err, tt.kind) {
+				t.Errorf("Expected kind %v, got %v", tt.kind, parseErr.Kind)
+			}
+			if parseErr.Code() != tt.code {
+				t.Errorf("Expected code %q, got %q", tt.code, parseErr.Code())
+			}
+			if parseErr.Position != tt.position || parseErr.Token != tt.token {
+				t.Errorf("Expected %q at %d, got %q at %d", tt.token, tt.position, parseErr.Token, parseErr.Position)
+			}
+			if parseErr.Caret() != tt.caret {
+				t.Errorf("Expected caret %q, got %q", tt.caret, parseErr.Caret())
+			}
+			if parseErr.Expression != tt.expression {
+				t.Errorf("Expected snippet %q, got %q", tt.expression, parseErr.Expression)
+			}
+		})
+	}
+}
+
+func TestRPNWithReferences(t *testing.T) {
+	results := map[string]string{"ans": "4", "$1": "10", "$2": "", "$4": "1/3", "$5": "yes"}
+	resolve := func(reference string) (string, error) {
+		value, ok := results[reference]
+		switch {
+		case !ok:
+			return "", ErrUnknownReference
+		case value == "":
+			return "", ErrPendingReference
+		}
+		return value, nil
+	}
+
+	tests := []struct {
+		name        string
+		expression  string
+		expected    []string
+		expectedErr error
+	}{
+		{
+			name:       "resolved references",
+			expression: "ans * $1",
+			expected:   []string{"4", "10", "*"},
+		},
+		{
+			name:       "reference to a rational result",
+			expression: "$4 * 3",
+			expected:   []string{"1/3", "3", "*"},
+		},
+		{
+			name:       "pending reference is kept",
+			expression: "($2 + 1) / $1",
+			expected:   []string{"$2", "1", "+", "10", "/"},
+		},
+		{
+			name:        "pending reference still needs valid syntax",
+			expression:  "$2 $1",
+			expectedErr: ErrInvalidExpression,
+		},
+		{
+			name:        "reference to a result that is not a number",
+			expression:  "$5 + 1",
+			expectedErr: ErrReferenceValue,
+		},
+		{
+			name:        "unknown reference",
+			expression:  "$3 + 1",
+			expectedErr: ErrUnknownReference,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := RPNWithOptions(tt.expression, Options{Resolve: resolve})
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Errorf("Expected error %v, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !compareSlices(result, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
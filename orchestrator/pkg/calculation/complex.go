@@ -0,0 +1,145 @@
+package calculation
+
+import (
+	"math"
+	"math/cmplx"
+	"strconv"
+	"unicode"
+)
+
+// ImaginaryUnit is the suffix of imaginary literals such as 4i or 0.5i.
+const ImaginaryUnit = "i"
+
+// promotable operations move into the complex plane when their real result
+// is NaN, so that sqrt(-4) is 2i rather than NaN.
+var promotable = map[string]bool{"sqrt": true, "log": true, "^": true}
+
+var complexFunctions = map[string]func(z complex128) complex128{
+	"sqrt": cmplx.Sqrt,
+	"sin":  cmplx.Sin,
+	"cos":  cmplx.Cos,
+	"log":  cmplx.Log,
+	"abs":  func(z complex128) complex128 { return complex(cmplx.Abs(z), 0) },
+}
+
+// ParseComplex parses a real or complex number written like 3, 4i or 11-2i.
+func ParseComplex(value string) (complex128, bool) {
+	z, err := strconv.ParseComplex(value, 128)
+	return z, err == nil
+}
+
+// FormatComplex renders z like 11-2i, or as a plain real number when it has
+// no imaginary part.
+func FormatComplex(z complex128) string {
+	re := strconv.FormatFloat(real(z), 'g', -1, 64)
+	if imag(z) == 0 {
+		return re
+	}
+
+	im := strconv.FormatFloat(imag(z), 'g', -1, 64) + ImaginaryUnit
+	if real(z) == 0 {
+		return im
+	}
+	if imag(z) > 0 || math.IsNaN(imag(z)) {
+		im = "+" + im
+	}
+	return re + im
+}
+
+func isNumber(value string) bool {
+	_, ok := ParseComplex(value)
+	return ok
+}
+
+// isImaginary reports whether the number literal that ends at runes[end]
+// carries the imaginary unit.
+func isImaginary(runes []rune, end int) bool {
+	if end >= len(runes) || string(runes[end]) != ImaginaryUnit {
+		return false
+	}
+	next := end + 1
+	return next == len(runes) || !(unicode.IsLetter(runes[next]) || unicode.IsDigit(runes[next]) || runes[next] == '_')
+}
+
+// imaginaryLiteral returns the first literal of rpn with an imaginary part.
+func imaginaryLiteral(rpn []token) (token, bool) {
+	for _, tok := range rpn {
+		if z, ok := ParseComplex(tok.value); ok && imag(z) != 0 {
+			return tok, true
+		}
+	}
+	return token{}, false
+}
+
+// applyNumber computes operations on real operands like applyFloat and
+// switches to complex arithmetic once an operand or a result is complex.
+func applyNumber(tok token, args []complex128) (complex128, error) {
+	if isRealArgs(args) {
+		reals := make([]float64, len(args))
+		for i, arg := range args {
+			reals[i] = real(arg)
+		}
+
+		result, err := applyFloat(tok, reals)
+		if err != nil || !math.IsNaN(result) || !promotable[operationName(tok.value)] {
+			return complex(result, 0), err
+		}
+	}
+
+	return applyComplex(tok, args)
+}
+
+func applyComplex(tok token, args []complex128) (complex128, error) {
+	if name, _, ok := ParseCall(tok.value); ok {
+		if fn, ok := complexFunctions[name]; ok {
+			return fn(args[0]), nil
+		}
+		return 0, errorAt(ErrComplexOperand, tok, "use real arguments")
+	}
+
+	switch tok.value {
+	case UnaryMinus:
+		return -args[0], nil
+	case UnaryNot:
+		return complex(boolean(args[0] == 0), 0), nil
+	case "+":
+		return args[0] + args[1], nil
+	case "-":
+		return args[0] - args[1], nil
+	case "*":
+		return args[0] * args[1], nil
+	case "/":
+		if args[1] == 0 {
+			return 0, errorAt(ErrDivisionByZero, tok, "change the divisor")
+		}
+		return args[0] / args[1], nil
+	case "^":
+		return cmplx.Pow(args[0], args[1]), nil
+	case "==":
+		return complex(boolean(args[0] == args[1]), 0), nil
+	case "!=":
+		return complex(boolean(args[0] != args[1]), 0), nil
+	case "&&":
+		return complex(boolean(args[0] != 0 && args[1] != 0), 0), nil
+	case "||":
+		return complex(boolean(args[0] != 0 || args[1] != 0), 0), nil
+	}
+
+	return 0, errorAt(ErrComplexOperand, tok, "use real operands")
+}
+
+func isRealArgs(args []complex128) bool {
+	for _, arg := range args {
+		if imag(arg) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func operationName(value string) string {
+	if name, _, ok := ParseCall(value); ok {
+		return name
+	}
+	return value
+}
@@ -0,0 +1,60 @@
+package calculation
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestFormatComplex(t *testing.T) {
+	tests := []struct {
+		value    complex128
+		expected string
+	}{
+		{complex(11, -2), "11-2i"},
+		{complex(0, 2), "2i"},
+		{complex(-1.5, 0), "-1.5"},
+		{complex(1e21, 0.5), "1e+21+0.5i"},
+		{complex(math.Copysign(0, -1), -1), "-1i"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			if got := FormatComplex(tt.value); got != tt.expected {
+				t.Errorf("FormatComplex(%v) = %q, want %q", tt.value, got, tt.expected)
+			}
+			if parsed, ok := ParseComplex(tt.expected); !ok || parsed != tt.value {
+				t.Errorf("ParseComplex(%q) = %v, %t", tt.expected, parsed, ok)
+			}
+		})
+	}
+}
+
+func TestComplexErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		opts       Options
+		position   int
+	}{
+		{"ordering", "1 + 1i < 2", Options{}, 7},
+		{"bitwise", "2i & 1", Options{}, 3},
+		{"function", "max(1, 2i)", Options{}, 0},
+		{"exact precision", "1 + 2i", Options{Precision: PrecisionExact}, 4},
+		{"rational mode", "sqrt(2i)", Options{NumberMode: NumberModeRational}, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := RPNWithOptions(tt.expression, tt.opts)
+
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) || !errors.Is(err, ErrComplexOperand) {
+				t.Fatalf("RPNWithOptions(%q) error = %v, want %v", tt.expression, err, ErrComplexOperand)
+			}
+			if parseErr.Position != tt.position {
+				t.Errorf("error position = %d, want %d", parseErr.Position, tt.position)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package calculation
+
+import (
+	"strconv"
+	"strings"
+)
+
+// LastResult refers to the caller's most recent finished expression;
+// "$N" refers to the expression with ID N.
+const LastResult = "ans"
+
+// constants are given with more digits than float64 holds so that exact
+// mode keeps them to DefaultDigits places.
+var constants = map[string]string{
+	"pi":  "3.14159265358979323846264338327950288419716939937510582097494459",
+	"e":   "2.71828182845904523536028747135266249775724709369995957496696763",
+	"tau": "6.28318530717958647692528676655900576839433879875021164194988918",
+	"phi": "1.61803398874989484820458683436563811772030917980576286213544862",
+}
+
+// Resolver returns the result of a referenced expression. It returns
+// ErrPendingReference when that expression has not finished yet.
+type Resolver func(reference string) (string, error)
+
+func IsConstant(name string) bool {
+	_, ok := constants[name]
+	return ok
+}
+
+func IsReference(token string) bool {
+	_, ok := ReferenceID(token)
+	return ok || token == LastResult
+}
+
+func ReferenceID(token string) (int, bool) {
+	digits, found := strings.CutPrefix(token, "$")
+	if !found || digits == "" || strings.ContainsFunc(digits, func(ch rune) bool { return !isDigit(ch) }) {
+		return 0, false
+	}
+
+	id, err := strconv.Atoi(digits)
+	if err != nil {
+		return 0, false
+	}
+
+	return id, true
+}
@@ -11,4 +11,30 @@ var (
 	ErrEmptyExpression       = errors.New("expression is empty")
 	ErrInvalidCharacter      = errors.New("invalid character in expression")
 	ErrInvalidNumber         = errors.New("invalid number")
+	ErrUnknownFunction       = errors.New("unknown function")
+	ErrWrongArgumentCount    = errors.New("wrong number of function arguments")
+	ErrUnboundVariable       = errors.New("unbound variable")
+	ErrInvalidVariableName   = errors.New("invalid variable name")
+	ErrReservedName          = errors.New("reserved name")
+	ErrUnknownPrecision      = errors.New("unknown precision")
+	ErrUnknownNumberMode     = errors.New("unknown number mode")
+	ErrUnknownSeparator      = errors.New("unknown decimal separator")
+	ErrUnknownLocale         = errors.New("unknown locale")
+	ErrInvalidFormat         = errors.New("invalid number format")
+	ErrNonFiniteResult       = errors.New("result is not a finite number")
+	ErrUnknownPolicy         = errors.New("unknown non-finite policy")
+	ErrUnknownReference      = errors.New("unknown reference")
+	ErrPendingReference      = errors.New("referenced expression is not finished")
+	ErrFailedReference       = errors.New("referenced expression failed")
+	ErrReferenceValue        = errors.New("referenced result cannot be used in an expression")
+	ErrNonIntegerOperand     = errors.New("operand must be an integer")
+	ErrInvalidShift          = errors.New("shift count must be a non-negative integer")
+	ErrComplexOperand        = errors.New("operand must be a real number")
+	ErrInvalidMatrix         = errors.New("invalid matrix literal")
+	ErrShapeMismatch         = errors.New("matrix shapes do not match")
+	ErrMatrixOperand         = errors.New("operand must be a number")
+	ErrUnknownUnit           = errors.New("unknown unit")
+	ErrDimensionMismatch     = errors.New("incompatible units")
+	ErrQuantityOperand       = errors.New("operand must not carry a unit")
+	ErrIrrationalOperation   = errors.New("operation has no exact rational result")
 )
@@ -0,0 +1,266 @@
+package calculation
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+)
+
+const (
+	PrecisionFloat = "float"
+	PrecisionExact = "exact"
+)
+
+// Number modes. Rational mode computes exactly, like PrecisionExact, and
+// answers with a reduced fraction instead of a decimal.
+const (
+	NumberModeFloat    = "float"
+	NumberModeRational = "rational"
+)
+
+const (
+	DefaultDigits    = 50
+	maxExactExponent = 4096
+)
+
+type exactOperation func(args []*big.Rat, digits int) (*big.Rat, error)
+
+var exactOperations = map[string]exactOperation{
+	"+": func(args []*big.Rat, _ int) (*big.Rat, error) { return new(big.Rat).Add(args[0], args[1]), nil },
+	"-": func(args []*big.Rat, _ int) (*big.Rat, error) { return new(big.Rat).Sub(args[0], args[1]), nil },
+	"*": func(args []*big.Rat, _ int) (*big.Rat, error) { return new(big.Rat).Mul(args[0], args[1]), nil },
+	"/": func(args []*big.Rat, _ int) (*big.Rat, error) {
+		if args[1].Sign() == 0 {
+			return nil, ErrDivisionByZero
+		}
+		return new(big.Rat).Quo(args[0], args[1]), nil
+	},
+	"^":      powerExact,
+	"%":      moduloExact,
+	"//":     floorDivideExact,
+	"&":      bitwiseExact((*big.Int).And),
+	"|":      bitwiseExact((*big.Int).Or),
+	"xor":    bitwiseExact((*big.Int).Xor),
+	"<<":     shiftExact(true),
+	">>":     shiftExact(false),
+	UnaryNot: func(args []*big.Rat, _ int) (*big.Rat, error) { return booleanExact(args[0].Sign() == 0), nil },
+	"==":     compareExact("=="),
+	"!=":     compareExact("!="),
+	"<":      compareExact("<"),
+	"<=":     compareExact("<="),
+	">":      compareExact(">"),
+	">=":     compareExact(">="),
+	"&&": func(args []*big.Rat, _ int) (*big.Rat, error) {
+		return booleanExact(args[0].Sign() != 0 && args[1].Sign() != 0), nil
+	},
+	"||": func(args []*big.Rat, _ int) (*big.Rat, error) {
+		return booleanExact(args[0].Sign() != 0 || args[1].Sign() != 0), nil
+	},
+	If: func(args []*big.Rat, _ int) (*big.Rat, error) {
+		return new(big.Rat).Set(args[chooseBranch(args[0].Sign() != 0)]), nil
+	},
+	UnaryMinus: func(args []*big.Rat, _ int) (*big.Rat, error) { return new(big.Rat).Neg(args[0]), nil },
+	"abs":      func(args []*big.Rat, _ int) (*big.Rat, error) { return new(big.Rat).Abs(args[0]), nil },
+	"sqrt":     elementaryExact(ratSqrt),
+	"sin":      elementaryExact(ratSin),
+	"cos":      elementaryExact(ratCos),
+	"log":      elementaryExact(ratLog),
+	"min": func(args []*big.Rat, _ int) (*big.Rat, error) {
+		result := args[0]
+		for _, arg := range args[1:] {
+			if arg.Cmp(result) < 0 {
+				result = arg
+			}
+		}
+		return new(big.Rat).Set(result), nil
+	},
+	"max": func(args []*big.Rat, _ int) (*big.Rat, error) {
+		result := args[0]
+		for _, arg := range args[1:] {
+			if arg.Cmp(result) > 0 {
+				result = arg
+			}
+		}
+		return new(big.Rat).Set(result), nil
+	},
+}
+
+func ParseExact(token string) (*big.Rat, bool) {
+	return new(big.Rat).SetString(token)
+}
+
+// isFraction reports whether literal is a fraction like 1/3, the form a
+// reference to a rational result takes.
+func isFraction(literal string) bool {
+	_, ok := ParseExact(literal)
+	return ok && strings.Contains(literal, "/")
+}
+
+// FormatExact renders r as a plain decimal, exactly when the expansion
+// terminates within digits places and rounded to digits places otherwise.
+func FormatExact(r *big.Rat, digits int) string {
+	if digits <= 0 {
+		digits = DefaultDigits
+	}
+
+	if r.IsInt() {
+		return r.Num().String()
+	}
+
+	if n, exact := r.FloatPrec(); exact && n <= digits {
+		return r.FloatString(n)
+	}
+
+	result := strings.TrimRight(r.FloatString(digits), "0")
+	result = strings.TrimSuffix(result, ".")
+	if result == "-0" {
+		return "0"
+	}
+	return result
+}
+
+func ApplyExact(operation string, args []*big.Rat, digits int) (*big.Rat, error) {
+	op, ok := exactOperations[operation]
+	if !ok {
+		return nil, ErrUnknownOperator
+	}
+
+	if digits <= 0 {
+		digits = DefaultDigits
+	}
+
+	return op(args, digits)
+}
+
+// irrational reports whether operation may take rational args to an
+// irrational result, which rational mode cannot answer with a fraction:
+// the elementary functions and powers with a non-integer exponent or one
+// too large to compute exactly.
+func irrational(operation string, args []*big.Rat) bool {
+	switch operation {
+	case "sqrt", "sin", "cos", "log":
+		return true
+	case "^":
+		exponent := args[1]
+		return !exponent.IsInt() || exponent.Num().CmpAbs(big.NewInt(maxExactExponent)) > 0
+	}
+	return false
+}
+
+// irrationalToken returns the first call of an elementary function in rpn.
+func irrationalToken(rpn []token) (token, bool) {
+	for _, tok := range rpn {
+		if name, _, ok := ParseCall(tok.value); ok && irrational(name, nil) {
+			return tok, true
+		}
+	}
+	return token{}, false
+}
+
+// evaluateRPNExact checks that tokens evaluate exactly. In rational mode
+// operations without a rational result are errors.
+func evaluateRPNExact(tokens []token, digits int, rational bool) ([]string, error) {
+	var stack []*big.Rat
+	var deferred []error
+	var starts []token
+
+	for _, tok := range tokens {
+		if num, ok := ParseExact(tok.value); ok {
+			stack = append(stack, num)
+			deferred = append(deferred, nil)
+			starts = append(starts, tok)
+			continue
+		}
+
+		operation, argc := tok.value, 2
+		if name, n, ok := ParseCall(tok.value); ok {
+			operation, argc = name, n
+		} else if IsUnaryOperator(tok.value) {
+			argc = 1
+		}
+
+		if len(stack) < argc {
+			return nil, errorAt(ErrNotEnoughOperands, tok, "")
+		}
+		args := make([]*big.Rat, argc)
+		copy(args, stack[len(stack)-argc:])
+		errs := make([]error, argc)
+		copy(errs, deferred[len(deferred)-argc:])
+		stack = stack[:len(stack)-argc]
+		deferred = deferred[:len(deferred)-argc]
+		starts = reduceStarts(starts, tok, argc)
+
+		if operation == If {
+			branch := chooseBranch(args[0].Sign() != 0)
+			stack = append(stack, args[branch])
+			deferred = append(deferred, firstError(errs[0], errs[branch]))
+			continue
+		}
+
+		if err := firstError(errs...); err != nil {
+			stack = append(stack, new(big.Rat))
+			deferred = append(deferred, err)
+			continue
+		}
+
+		if rational && irrational(operation, args) {
+			stack = append(stack, new(big.Rat))
+			deferred = append(deferred, errorAt(ErrIrrationalOperation, tok, "use an integer exponent"))
+			continue
+		}
+
+		result, err := ApplyExact(operation, args, digits)
+		if errors.Is(err, ErrDivisionByZero) {
+			err = errorAt(err, tok, "change the divisor")
+		} else if err != nil {
+			err = errorAt(err, tok, integerHint(err))
+		}
+		stack = append(stack, result)
+		deferred = append(deferred, err)
+	}
+
+	if err := checkSingleResult(starts); err != nil {
+		return nil, err
+	}
+	if deferred[0] != nil {
+		return nil, deferred[0]
+	}
+
+	return []string{FormatExact(stack[0], digits)}, nil
+}
+
+func powerExact(args []*big.Rat, digits int) (*big.Rat, error) {
+	base, exponent := args[0], args[1]
+
+	if exponent.IsInt() && exponent.Num().IsInt64() {
+		e := exponent.Num().Int64()
+		if e >= -maxExactExponent && e <= maxExactExponent {
+			if e < 0 {
+				if base.Sign() == 0 {
+					return nil, ErrDivisionByZero
+				}
+				base, e = new(big.Rat).Inv(base), -e
+			}
+			num := new(big.Int).Exp(base.Num(), big.NewInt(e), nil)
+			den := new(big.Int).Exp(base.Denom(), big.NewInt(e), nil)
+			return new(big.Rat).SetFrac(num, den), nil
+		}
+	}
+
+	result, ok := ratPower(base, exponent, digits)
+	if !ok {
+		return nil, ErrNonFiniteResult
+	}
+	return result, nil
+}
+
+// elementaryExact lifts a function of bigmath.go into exact mode.
+func elementaryExact(fn func(x *big.Rat, digits int) (*big.Rat, bool)) exactOperation {
+	return func(args []*big.Rat, digits int) (*big.Rat, error) {
+		result, ok := fn(args[0], digits)
+		if !ok {
+			return nil, ErrNonFiniteResult
+		}
+		return result, nil
+	}
+}
@@ -0,0 +1,207 @@
+package calculation
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+func TestEvaluateRPNExact(t *testing.T) {
+	tests := []struct {
+		name        string
+		expression  string
+		digits      int
+		expected    string
+		expectedErr error
+	}{
+		{name: "decimal fractions", expression: "0.1 + 0.2", digits: 50, expected: "0.3"},
+		{name: "large integers", expression: "10000000000000000000001 - 1", digits: 50, expected: "10000000000000000000000"},
+		{name: "integer power", expression: "2 ^ 100", digits: 50, expected: "1267650600228229401496703205376"},
+		{name: "negative power", expression: "2 ^ -3", digits: 50, expected: "0.125"},
+		{name: "repeating decimal is rounded to digits", expression: "1 / 3", digits: 10, expected: "0.3333333333"},
+		{name: "exact cancellation", expression: "(1 / 3) * 3 - 1", digits: 50, expected: "0"},
+		{name: "square root", expression: "sqrt(2)", digits: 20, expected: "1.4142135623730950488"},
+		{name: "division by exact zero", expression: "1 / (0.3 - 0.1 * 3)", digits: 50, expectedErr: ErrDivisionByZero},
+		{name: "square root of negative", expression: "sqrt(-1)", digits: 50, expectedErr: ErrNonFiniteResult},
+		{name: "floored modulo", expression: "-7 % 3 + 7.5 % 2", digits: 50, expected: "3.5"},
+		{name: "floor division", expression: "-7 // 2", digits: 50, expected: "-4"},
+		{name: "bitwise on big integers", expression: "(1 << 100) | 1 xor 3 & 6", digits: 50, expected: "1267650600228229401496703205379"},
+		{name: "arithmetic right shift", expression: "-9 >> 1", digits: 50, expected: "-5"},
+		{name: "bitwise on fraction", expression: "0.5 & 1", digits: 50, expectedErr: ErrNonIntegerOperand},
+		{name: "exact comparison", expression: "0.1 + 0.2 == 0.3 && 1 / 3 < 0.34", digits: 50, expected: "1"},
+		{name: "lazy conditional", expression: "if(1 / 3 * 3 != 1, 1 / 0, 2)", digits: 50, expected: "2"},
+		{name: "modulo by zero", expression: "1 % (0.1 * 10 - 1)", digits: 50, expectedErr: ErrDivisionByZero},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := createToken(tt.expression, nil)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			rpn, err := convertingAnExpression(tokens)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			result, err := evaluateRPNExact(rpn, tt.digits, false)
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Errorf("Expected error %v, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result[0] != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result[0])
+			}
+		})
+	}
+}
+
+// TestExactElementaryFunctions checks 50 digits against values computed
+// independently; the agent tests use the same table.
+func TestExactElementaryFunctions(t *testing.T) {
+	tests := []struct {
+		operation string
+		args      []string
+		expected  string
+	}{
+		{"sqrt", []string{"2"}, "1.41421356237309504880168872420969807856967187537695"},
+		{"sqrt", []string{"1e60"}, "1000000000000000000000000000000"},
+		{"sqrt", []string{"123456789012345678901234567890"}, "351364182882014.42531112223816981261182604308051128447256256976262"},
+		{"log", []string{"2"}, "0.69314718055994530941723212145817656807550013436026"},
+		{"log", []string{"1"}, "0"},
+		{"log", []string{"0.001"}, "-6.90775527898213705205397436405309262280330446588632"},
+		{"log", []string{"1e100"}, "230.2585092994045684017991454684364207601101488628773"},
+		{"sin", []string{"1"}, "0.84147098480789650665250232163029899962256306079837"},
+		{"cos", []string{"1"}, "0.54030230586813971740093660744297660373231042061792"},
+		{"sin", []string{"0"}, "0"},
+		{"cos", []string{"0"}, "1"},
+		{"sin", []string{"100"}, "-0.50636564110975879365655761045978543206503272129066"},
+		{"cos", []string{"1e20"}, "0.763970404441728300400146802737881122834473441747"},
+		{"^", []string{"2", "0.5"}, "1.41421356237309504880168872420969807856967187537695"},
+		{"^", []string{"10", "2.5"}, "316.22776601683793319988935444327185337195551393252168"},
+		{"^", []string{"3", "50.5"}, "1243435789333745207971490.00407809988777135686720911848017909705693676041164"},
+		{"^", []string{"2", "1/3"}, "1.25992104989487316476721060727822835057025146470151"},
+		{"^", []string{"0.5", "-1.5"}, "2.8284271247461900976033774484193961571393437507539"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.operation+" "+tt.args[0], func(t *testing.T) {
+			args := make([]*big.Rat, len(tt.args))
+			for i, arg := range tt.args {
+				args[i], _ = ParseExact(arg)
+			}
+			result, err := ApplyExact(tt.operation, args, 50)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got := FormatExact(result, 50); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+// TestExactDigits checks that results are correct to the requested number
+// of digits, however many are asked for; the agent tests use the same table.
+func TestExactDigits(t *testing.T) {
+	tests := []struct {
+		operation string
+		args      []string
+		digits    int
+		expected  string
+	}{
+		{"sqrt", []string{"2"}, 10, "1.4142135624"},
+		{"sqrt", []string{"2"}, 30, "1.41421356237309504880168872421"},
+		{"sqrt", []string{"2"}, 100, "1.4142135623730950488016887242096980785696718753769480731766797379907324784621070388503875343276415727"},
+		{"/", []string{"1", "3"}, 1, "0.3"},
+		{"/", []string{"1", "3"}, 5, "0.33333"},
+		{"/", []string{"1", "3"}, 20, "0.33333333333333333333"},
+		{"log", []string{"2"}, 3, "0.693"},
+		{"log", []string{"2"}, 40, "0.6931471805599453094172321214581765680755"},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %d", tt.operation, tt.digits), func(t *testing.T) {
+			args := make([]*big.Rat, len(tt.args))
+			for i, arg := range tt.args {
+				args[i], _ = ParseExact(arg)
+			}
+			result, err := ApplyExact(tt.operation, args, tt.digits)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got := FormatExact(result, tt.digits); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestFormatExact(t *testing.T) {
+	tests := []struct {
+		value    *big.Rat
+		digits   int
+		expected string
+	}{
+		{big.NewRat(7, 1), 10, "7"},
+		{big.NewRat(-1, 8), 10, "-0.125"},
+		{big.NewRat(2, 3), 5, "0.66667"},
+		{big.NewRat(1, 1024), 5, "0.00098"},
+		{big.NewRat(1, 100000000), 5, "0"},
+		{big.NewRat(-1, 100000000), 5, "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			if got := FormatExact(tt.value, tt.digits); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRPNWithOptionsUnknownPrecision(t *testing.T) {
+	_, err := RPNWithOptions("1 + 1", Options{Precision: "double"})
+	if !errors.Is(err, ErrUnknownPrecision) {
+		t.Errorf("Expected error %v, got %v", ErrUnknownPrecision, err)
+	}
+}
+
+func TestRPNWithOptionsRationalMode(t *testing.T) {
+	pending := func(string) (string, error) { return "", ErrPendingReference }
+
+	tests := []struct {
+		name        string
+		expression  string
+		expectedErr error
+	}{
+		{name: "fractions", expression: "1 / 3 + 2 ^ -2"},
+		{name: "integer power", expression: "(2 / 3) ^ 100"},
+		{name: "square root", expression: "sqrt(4)", expectedErr: ErrIrrationalOperation},
+		{name: "sine", expression: "sin(1)", expectedErr: ErrIrrationalOperation},
+		{name: "logarithm", expression: "1 + log(2)", expectedErr: ErrIrrationalOperation},
+		{name: "function beside a pending reference", expression: "$1 + cos(0)", expectedErr: ErrIrrationalOperation},
+		{name: "fractional power", expression: "2 ^ 0.5", expectedErr: ErrIrrationalOperation},
+		{name: "computed fractional power", expression: "2 ^ (1 / 2)", expectedErr: ErrIrrationalOperation},
+		{name: "power too large to compute exactly", expression: "2 ^ 5000", expectedErr: ErrIrrationalOperation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := RPNWithOptions(tt.expression, Options{NumberMode: NumberModeRational, Resolve: pending})
+			if !errors.Is(err, tt.expectedErr) {
+				t.Errorf("Expected error %v, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+
+	if _, err := RPNWithOptions("2 ^ 0.5", Options{Precision: PrecisionExact}); err != nil {
+		t.Errorf("exact precision rejected an irrational power: %v", err)
+	}
+}
@@ -0,0 +1,345 @@
+package calculation
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Rounding modes of a Format.
+const (
+	RoundHalfEven = "half_even"
+	RoundHalfUp   = "half_up"
+	RoundHalfDown = "half_down"
+	RoundDown     = "down" // toward zero
+	RoundUp       = "up"   // away from zero
+	RoundFloor    = "floor"
+	RoundCeiling  = "ceiling"
+)
+
+// Notations of a Format. NotationAuto switches to scientific notation for
+// very large and very small numbers.
+const (
+	NotationAuto       = "auto"
+	NotationPlain      = "plain"
+	NotationScientific = "scientific"
+)
+
+const (
+	maxSignificantDigits = 17
+	maxDecimals          = 30
+)
+
+// locales give the separators used when a Format does not set them. Russian
+// and French group digits with no-break spaces.
+var locales = map[string]struct{ thousands, decimal string }{
+	"en": {",", "."},
+	"ru": {"\u00a0", ","},
+	"de": {".", ","},
+	"fr": {"\u202f", ","},
+	"ch": {"'", "."},
+}
+
+// Format controls how results are shown. The zero Format prints the
+// shortest representation that reads back as the same float64.
+type Format struct {
+	SignificantDigits  int    `json:"significant_digits,omitempty"`
+	Decimals           *int   `json:"decimals,omitempty"`
+	Rounding           string `json:"rounding,omitempty"`
+	Notation           string `json:"notation,omitempty"`
+	Locale             string `json:"locale,omitempty"`
+	ThousandsSeparator string `json:"thousands_separator,omitempty"`
+	DecimalSeparator   string `json:"decimal_separator,omitempty"`
+}
+
+func (f Format) IsZero() bool {
+	return f == Format{}
+}
+
+// Validate reports the first option of f that cannot be applied.
+func (f Format) Validate() error {
+	switch {
+	case f.SignificantDigits != 0 && f.Decimals != nil:
+		return fmt.Errorf("%w: set either significant_digits or decimals", ErrInvalidFormat)
+	case f.SignificantDigits < 0 || f.SignificantDigits > maxSignificantDigits:
+		return fmt.Errorf("%w: significant_digits must be between 1 and %d", ErrInvalidFormat, maxSignificantDigits)
+	case f.Decimals != nil && (*f.Decimals < 0 || *f.Decimals > maxDecimals):
+		return fmt.Errorf("%w: decimals must be between 0 and %d", ErrInvalidFormat, maxDecimals)
+	}
+
+	switch f.Rounding {
+	case "", RoundHalfEven, RoundHalfUp, RoundHalfDown, RoundDown, RoundUp, RoundFloor, RoundCeiling:
+	default:
+		return fmt.Errorf("%w: unknown rounding %q", ErrInvalidFormat, f.Rounding)
+	}
+	switch f.Notation {
+	case "", NotationAuto, NotationPlain, NotationScientific:
+	default:
+		return fmt.Errorf("%w: unknown notation %q", ErrInvalidFormat, f.Notation)
+	}
+	if _, ok := locales[f.Locale]; f.Locale != "" && !ok {
+		return fmt.Errorf("%w: unknown locale %q", ErrInvalidFormat, f.Locale)
+	}
+
+	if f.DecimalSeparator != "" && f.DecimalSeparator != DecimalPoint && f.DecimalSeparator != DecimalComma {
+		return fmt.Errorf("%w: decimal_separator must be %q or %q", ErrInvalidFormat, DecimalPoint, DecimalComma)
+	}
+	if utf8.RuneCountInString(f.ThousandsSeparator) > 1 || strings.ContainsAny(f.ThousandsSeparator, "0123456789+-eE") {
+		return fmt.Errorf("%w: thousands_separator must be a single non-digit character", ErrInvalidFormat)
+	}
+	if thousands, decimal := f.separators(); thousands != "" && thousands == decimal {
+		return fmt.Errorf("%w: thousands and decimal separators are the same", ErrInvalidFormat)
+	}
+	return nil
+}
+
+func (f Format) separators() (thousands, decimal string) {
+	locale := locales[f.Locale]
+	thousands, decimal = locale.thousands, locale.decimal
+	if f.ThousandsSeparator != "" {
+		thousands = f.ThousandsSeparator
+	}
+	if f.DecimalSeparator != "" {
+		decimal = f.DecimalSeparator
+	}
+	if decimal == "" {
+		decimal = DecimalPoint
+	}
+	return thousands, decimal
+}
+
+// Float formats x.
+func (f Format) Float(x float64) string {
+	if f.IsZero() || math.IsNaN(x) || math.IsInf(x, 0) {
+		return strconv.FormatFloat(x, 'g', -1, 64)
+	}
+	return f.Decimal(strconv.FormatFloat(x, 'e', -1, 64))
+}
+
+// Complex formats the parts of z like FormatComplex.
+func (f Format) Complex(z complex128) string {
+	if f.IsZero() {
+		return FormatComplex(z)
+	}
+
+	re := f.Float(real(z))
+	if imag(z) == 0 {
+		return re
+	}
+
+	im := f.Float(imag(z)) + ImaginaryUnit
+	if real(z) == 0 {
+		return im
+	}
+	if !strings.HasPrefix(im, "-") {
+		im = "+" + im
+	}
+	return re + im
+}
+
+// Quantity formats a value given in SI base units in unit, like
+// FormatQuantity.
+func (f Format) Quantity(si float64, unit string) string {
+	factor, _, ok := parseUnit(unit)
+	if !ok {
+		return f.Float(si) + " " + unit
+	}
+	value, _ := strconv.ParseFloat(strconv.FormatFloat(si/factor, 'g', 15, 64), 64)
+	return f.Float(value) + " " + unit
+}
+
+// Decimal formats a number written in decimal, such as 3.3333333333333335
+// or 1.5e-7. Numbers are rounded from their decimal digits, so 2.675
+// rounds half up to 2.68 although the nearest float64 is a little smaller.
+// Anything else is returned as it is.
+func (f Format) Decimal(literal string) string {
+	negative, digits, exp, ok := splitDecimal(literal)
+	if !ok || f.IsZero() {
+		return literal
+	}
+
+	scientific := f.Notation == NotationScientific ||
+		(f.Notation == "" || f.Notation == NotationAuto) && digits != "" && (exp < -4 || exp >= 21)
+
+	// keep is the number of significant digits left after rounding
+	keep := len(digits)
+	switch {
+	case f.SignificantDigits > 0:
+		keep = f.SignificantDigits
+	case f.Decimals != nil && scientific:
+		keep = *f.Decimals + 1
+	case f.Decimals != nil:
+		keep = exp + 1 + *f.Decimals
+	}
+	digits, exp = roundDigits(digits, exp, keep, f.Rounding, negative)
+
+	var text string
+	if scientific && digits != "" {
+		text = f.scientific(digits, exp)
+	} else {
+		text = f.plain(digits, exp)
+	}
+	if negative && strings.ContainsAny(text, "123456789") {
+		text = "-" + text
+	}
+	return text
+}
+
+func (f Format) scientific(digits string, exp int) string {
+	_, decimal := f.separators()
+
+	fraction := digits[1:]
+	if f.Decimals != nil {
+		fraction += strings.Repeat("0", max(0, *f.Decimals-len(fraction)))
+	}
+
+	mantissa := digits[:1]
+	if fraction != "" {
+		mantissa += decimal + fraction
+	}
+
+	sign := "+"
+	if exp < 0 {
+		sign, exp = "-", -exp
+	}
+	return fmt.Sprintf("%se%s%02d", mantissa, sign, exp)
+}
+
+func (f Format) plain(digits string, exp int) string {
+	thousands, decimal := f.separators()
+
+	var integer, fraction string
+	switch {
+	case digits == "":
+		integer = "0"
+	case exp < 0:
+		integer = "0"
+		fraction = strings.Repeat("0", -exp-1) + digits
+	case exp+1 >= len(digits):
+		integer = digits + strings.Repeat("0", exp+1-len(digits))
+	default:
+		integer, fraction = digits[:exp+1], digits[exp+1:]
+	}
+	if f.Decimals != nil {
+		fraction += strings.Repeat("0", max(0, *f.Decimals-len(fraction)))
+	}
+
+	if thousands != "" {
+		var grouped strings.Builder
+		for i, digit := range integer {
+			if i > 0 && (len(integer)-i)%3 == 0 {
+				grouped.WriteString(thousands)
+			}
+			grouped.WriteRune(digit)
+		}
+		integer = grouped.String()
+	}
+
+	if fraction == "" {
+		return integer
+	}
+	return integer + decimal + fraction
+}
+
+// splitDecimal splits a decimal literal into its sign, its significant
+// digits without leading or trailing zeros, and the power of ten of the
+// first digit. Zero has no digits.
+func splitDecimal(literal string) (negative bool, digits string, exp int, ok bool) {
+	text := literal
+	if strings.HasPrefix(text, "-") || strings.HasPrefix(text, "+") {
+		negative, text = text[0] == '-', text[1:]
+	}
+
+	mantissa, exponent, found := strings.Cut(strings.ToLower(text), "e")
+	if found {
+		n, err := strconv.Atoi(exponent)
+		if err != nil {
+			return false, "", 0, false
+		}
+		exp = n
+	}
+
+	integer, fraction, _ := strings.Cut(mantissa, ".")
+	if integer == "" && fraction == "" {
+		return false, "", 0, false
+	}
+	for _, ch := range integer + fraction {
+		if !isDigit(ch) {
+			return false, "", 0, false
+		}
+	}
+
+	digits = integer + fraction
+	exp += len(integer) - 1
+	trimmed := strings.TrimLeft(digits, "0")
+	exp -= len(digits) - len(trimmed)
+	digits = strings.TrimRight(trimmed, "0")
+	return negative, digits, exp, true
+}
+
+// roundDigits rounds digits, whose first digit stands for 10^exp, to keep
+// significant digits. It returns the digits without trailing zeros and the
+// power of ten of the first of them, which grows when 9.99 rounds to 10.
+func roundDigits(digits string, exp, keep int, mode string, negative bool) (string, int) {
+	if keep >= len(digits) {
+		return digits, exp
+	}
+	if keep < 0 {
+		// every digit is dropped; pad so that the first dropped digit is
+		// the one right after the rounding position
+		digits, exp, keep = strings.Repeat("0", -keep)+digits, exp-keep, 0
+	}
+
+	kept, dropped := digits[:keep], digits[keep:]
+	if roundsAway(kept, dropped, mode, negative) {
+		var carry bool
+		if kept, carry = incrementDigits(kept); carry {
+			return "1", exp + 1
+		}
+	}
+	return strings.TrimRight(kept, "0"), exp
+}
+
+// incrementDigits adds one to the last digit and reports whether the
+// digits, all nines, carried into a new leading digit.
+func incrementDigits(digits string) (string, bool) {
+	b := []byte(digits)
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] < '9' {
+			b[i]++
+			return string(b), false
+		}
+		b[i] = '0'
+	}
+	return "", true
+}
+
+func roundsAway(kept, dropped string, mode string, negative bool) bool {
+	if strings.Trim(dropped, "0") == "" {
+		return false
+	}
+
+	half := dropped[0] == '5' && strings.Trim(dropped[1:], "0") == ""
+	switch mode {
+	case RoundDown:
+		return false
+	case RoundUp:
+		return true
+	case RoundFloor:
+		return negative
+	case RoundCeiling:
+		return !negative
+	case RoundHalfUp:
+		return dropped[0] >= '5'
+	case RoundHalfDown:
+		return dropped[0] > '5' || dropped[0] == '5' && !half
+	}
+
+	// half to even
+	if half {
+		return kept != "" && (kept[len(kept)-1]-'0')%2 == 1
+	}
+	return dropped[0] >= '5'
+}
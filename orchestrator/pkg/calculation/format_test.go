@@ -0,0 +1,92 @@
+package calculation
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	decimals := func(n int) *int { return &n }
+
+	tests := []struct {
+		name     string
+		format   Format
+		value    float64
+		expected string
+	}{
+		{"zero format", Format{}, 1.0 / 3, "0.3333333333333333"},
+		{"significant digits", Format{SignificantDigits: 3}, 1.0 / 3, "0.333"},
+		{"trailing zeros trimmed", Format{SignificantDigits: 5}, 2.5, "2.5"},
+		{"fixed decimals", Format{Decimals: decimals(2)}, 2.5, "2.50"},
+		{"no decimals", Format{Decimals: decimals(0)}, 2.5, "2"},
+		{"half up", Format{Decimals: decimals(0), Rounding: RoundHalfUp}, 2.5, "3"},
+		{"half down", Format{Decimals: decimals(0), Rounding: RoundHalfDown}, 2.5, "2"},
+		{"half down above half", Format{Decimals: decimals(0), Rounding: RoundHalfDown}, 2.51, "3"},
+		{"decimal digits", Format{Decimals: decimals(2), Rounding: RoundHalfUp}, 2.675, "2.68"},
+		{"down", Format{SignificantDigits: 2}, -0.0199, "-0.02"},
+		{"toward zero", Format{SignificantDigits: 2, Rounding: RoundDown}, -0.0199, "-0.019"},
+		{"floor", Format{Decimals: decimals(1), Rounding: RoundFloor}, -1.21, "-1.3"},
+		{"ceiling", Format{Decimals: decimals(1), Rounding: RoundCeiling}, -1.29, "-1.2"},
+		{"away from zero", Format{Decimals: decimals(1), Rounding: RoundUp}, 1.21, "1.3"},
+		{"carry", Format{Decimals: decimals(2)}, 9.999, "10.00"},
+		{"rounded to zero", Format{Decimals: decimals(2)}, -0.001, "0.00"},
+		{"rounded up from below", Format{Decimals: decimals(2), Rounding: RoundUp}, 0.0001, "0.01"},
+		{"large plain", Format{SignificantDigits: 3}, 123456, "123000"},
+		{"auto scientific", Format{SignificantDigits: 3}, 1.2345e-7, "1.23e-07"},
+		{"scientific", Format{Notation: NotationScientific, Decimals: decimals(2)}, 12345, "1.23e+04"},
+		{"plain", Format{Notation: NotationPlain}, 1.5e-7, "0.00000015"},
+		{"thousands", Format{ThousandsSeparator: " "}, 1234567.5, "1 234 567.5"},
+		{"locale", Format{Locale: "de", Decimals: decimals(2)}, -1234567.891, "-1.234.567,89"},
+		{"locale override", Format{Locale: "ru", ThousandsSeparator: "_"}, 12345.5, "12_345,5"},
+		{"short integer", Format{Locale: "en"}, 999, "999"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.format.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if got := tt.format.Float(tt.value); got != tt.expected {
+				t.Errorf("Float(%v) = %q, want %q", tt.value, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFormatValues(t *testing.T) {
+	format := Format{SignificantDigits: 3, Locale: "ru"}
+
+	if got := format.Complex(complex(1.0/3, -2.0/3)); got != "0,333-0,667i" {
+		t.Errorf("Complex() = %q", got)
+	}
+	if got := format.Quantity(1000.0/3, "km"); got != "0,333 km" {
+		t.Errorf("Quantity() = %q", got)
+	}
+	if got := format.Decimal("1234.5678901234567890123"); got != "1\u00a0230" {
+		t.Errorf("Decimal() = %q", got)
+	}
+	if got := format.Decimal("1/3"); got != "1/3" {
+		t.Errorf("Decimal(fraction) = %q", got)
+	}
+}
+
+func TestFormatValidate(t *testing.T) {
+	two := 2
+	invalid := []Format{
+		{SignificantDigits: 3, Decimals: &two},
+		{SignificantDigits: 18},
+		{Rounding: "nearest"},
+		{Notation: "engineering"},
+		{Locale: "xx"},
+		{DecimalSeparator: "'"},
+		{ThousandsSeparator: "5"},
+		{ThousandsSeparator: ",", DecimalSeparator: ","},
+		{Locale: "de", DecimalSeparator: "."},
+	}
+
+	for _, format := range invalid {
+		if err := format.Validate(); !errors.Is(err, ErrInvalidFormat) {
+			t.Errorf("Validate(%+v) error = %v, want %v", format, err, ErrInvalidFormat)
+		}
+	}
+}
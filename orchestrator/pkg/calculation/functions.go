@@ -0,0 +1,91 @@
+package calculation
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type function struct {
+	minArgs int
+	maxArgs int
+	apply   func(args []float64) float64
+}
+
+const variadic = -1
+
+var functions = map[string]function{
+	"sqrt": {1, 1, func(args []float64) float64 { return math.Sqrt(args[0]) }},
+	"sin":  {1, 1, func(args []float64) float64 { return math.Sin(args[0]) }},
+	"cos":  {1, 1, func(args []float64) float64 { return math.Cos(args[0]) }},
+	"log":  {1, 1, func(args []float64) float64 { return math.Log(args[0]) }},
+	"abs":  {1, 1, func(args []float64) float64 { return math.Abs(args[0]) }},
+	"min":  {1, variadic, minimum},
+	"max":  {1, variadic, maximum},
+	If:     {3, 3, conditional},
+
+	// computed by applyMatrix
+	Dot:       {2, 2, nil},
+	Transpose: {1, 1, nil},
+	Det:       {1, 1, nil},
+}
+
+func IsFunction(name string) bool {
+	_, ok := functions[name]
+	return ok
+}
+
+// FormatCall encodes a function call as a single RPN token, e.g. "max:3".
+func FormatCall(name string, argc int) string {
+	return name + ":" + strconv.Itoa(argc)
+}
+
+func ParseCall(token string) (string, int, bool) {
+	name, count, found := strings.Cut(token, ":")
+	if !found || !IsFunction(name) {
+		return "", 0, false
+	}
+
+	argc, err := strconv.Atoi(count)
+	if err != nil {
+		return "", 0, false
+	}
+
+	return name, argc, true
+}
+
+func checkArgumentCount(name string, argc int) error {
+	fn := functions[name]
+	if argc < fn.minArgs || (fn.maxArgs != variadic && argc > fn.maxArgs) {
+		return ErrWrongArgumentCount
+	}
+	return nil
+}
+
+func arityHint(name string) string {
+	fn := functions[name]
+	switch {
+	case fn.maxArgs == variadic:
+		return fmt.Sprintf("%s expects at least %d argument(s)", name, fn.minArgs)
+	case fn.minArgs == fn.maxArgs:
+		return fmt.Sprintf("%s expects %d argument(s)", name, fn.minArgs)
+	}
+	return fmt.Sprintf("%s expects %d to %d arguments", name, fn.minArgs, fn.maxArgs)
+}
+
+func minimum(args []float64) float64 {
+	result := args[0]
+	for _, arg := range args[1:] {
+		result = math.Min(result, arg)
+	}
+	return result
+}
+
+func maximum(args []float64) float64 {
+	result := args[0]
+	for _, arg := range args[1:] {
+		result = math.Max(result, arg)
+	}
+	return result
+}
@@ -0,0 +1,120 @@
+package calculation
+
+import (
+	"math"
+	"math/big"
+)
+
+// maxExactShift bounds shift counts so that a shift cannot allocate an
+// arbitrarily large integer.
+const maxExactShift = 1 << 16
+
+// floorModulo returns the remainder of floored division, which takes the
+// sign of the divisor: -7 % 3 == 2, matching -7 // 3 == -3.
+func floorModulo(a, b float64) float64 {
+	result := math.Mod(a, b)
+	if result != 0 && (result < 0) != (b < 0) {
+		result += b
+	}
+	return result
+}
+
+func bitwise(operation string, a, b float64) (float64, error) {
+	if !isInteger(a) {
+		return 0, ErrNonIntegerOperand
+	}
+
+	switch operation {
+	case "<<", ">>":
+		if !isInteger(b) || b < 0 {
+			return 0, ErrInvalidShift
+		}
+		shift := int(math.Min(b, 2*maxExactShift))
+		if operation == "<<" {
+			return math.Ldexp(a, shift), nil
+		}
+		return math.Floor(math.Ldexp(a, -shift)), nil
+	}
+
+	if !isInteger(b) {
+		return 0, ErrNonIntegerOperand
+	}
+
+	x, y := int64(a), int64(b)
+	switch operation {
+	case "&":
+		return float64(x & y), nil
+	case "|":
+		return float64(x | y), nil
+	case "xor":
+		return float64(x ^ y), nil
+	}
+
+	return 0, ErrUnknownOperator
+}
+
+// isInteger reports whether x is an integer that fits into an int64.
+func isInteger(x float64) bool {
+	return x == math.Trunc(x) && math.Abs(x) < 1<<63
+}
+
+func integerHint(err error) string {
+	switch err {
+	case ErrNonIntegerOperand:
+		return "use integer operands"
+	case ErrInvalidShift:
+		return "shift by a non-negative integer"
+	}
+	return ""
+}
+
+func floorExact(r *big.Rat) *big.Int {
+	// big.Int.Div rounds towards negative infinity for positive divisors,
+	// and a Rat denominator is always positive.
+	return new(big.Int).Div(r.Num(), r.Denom())
+}
+
+func floorDivideExact(args []*big.Rat, _ int) (*big.Rat, error) {
+	if args[1].Sign() == 0 {
+		return nil, ErrDivisionByZero
+	}
+	quotient := new(big.Rat).Quo(args[0], args[1])
+	return new(big.Rat).SetInt(floorExact(quotient)), nil
+}
+
+func moduloExact(args []*big.Rat, _ int) (*big.Rat, error) {
+	if args[1].Sign() == 0 {
+		return nil, ErrDivisionByZero
+	}
+	quotient := new(big.Rat).SetInt(floorExact(new(big.Rat).Quo(args[0], args[1])))
+	return new(big.Rat).Sub(args[0], quotient.Mul(quotient, args[1])), nil
+}
+
+func bitwiseExact(operation func(z, x, y *big.Int) *big.Int) exactOperation {
+	return func(args []*big.Rat, _ int) (*big.Rat, error) {
+		if !args[0].IsInt() || !args[1].IsInt() {
+			return nil, ErrNonIntegerOperand
+		}
+		return new(big.Rat).SetInt(operation(new(big.Int), args[0].Num(), args[1].Num())), nil
+	}
+}
+
+func shiftExact(left bool) exactOperation {
+	return func(args []*big.Rat, _ int) (*big.Rat, error) {
+		if !args[0].IsInt() {
+			return nil, ErrNonIntegerOperand
+		}
+		count := args[1]
+		if !count.IsInt() || count.Sign() < 0 || count.Num().Cmp(big.NewInt(maxExactShift)) > 0 {
+			return nil, ErrInvalidShift
+		}
+
+		result := new(big.Int)
+		if left {
+			result.Lsh(args[0].Num(), uint(count.Num().Uint64()))
+		} else {
+			result.Rsh(args[0].Num(), uint(count.Num().Uint64()))
+		}
+		return new(big.Rat).SetInt(result), nil
+	}
+}
@@ -0,0 +1,197 @@
+package calculation
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const maxLiteralExponent = 4096
+
+var radixPrefixes = map[string]int{
+	"0x": 16, "0X": 16,
+	"0o": 8, "0O": 8,
+	"0b": 2, "0B": 2,
+}
+
+var baseNames = map[int]string{
+	2:  "binary",
+	8:  "octal",
+	10: "decimal",
+	16: "hexadecimal",
+}
+
+// scanNumber returns the end of the numeric literal starting at runes[i].
+// It deliberately consumes malformed tails such as a second decimal point
+// or a bad radix digit so that parseNumber can report them precisely.
+func scanNumber(runes []rune, i int) int {
+	if i+1 < len(runes) {
+		if _, ok := radixPrefixes[string(runes[i:i+2])]; ok {
+			i += 2
+			for i < len(runes) && (isDigit(runes[i]) || isASCIILetter(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			return i
+		}
+	}
+
+	for i < len(runes) && (isDigit(runes[i]) || runes[i] == '.' || runes[i] == '_') {
+		i++
+	}
+	if i < len(runes) && (runes[i] == 'e' || runes[i] == 'E') && startsExponent(runes[i+1:]) {
+		i++
+		if runes[i] == '+' || runes[i] == '-' {
+			i++
+		}
+		for i < len(runes) && (isDigit(runes[i]) || runes[i] == '.' || runes[i] == '_') {
+			i++
+		}
+	} else if i < len(runes) && (runes[i] == 'e' || runes[i] == 'E') && danglingExponent(runes[i+1:]) {
+		// 1e and 1e+ belong to the literal, which parseDecimal rejects
+		i++
+		if i < len(runes) && (runes[i] == '+' || runes[i] == '-') {
+			i++
+		}
+	}
+
+	return i
+}
+
+// danglingExponent reports whether the e that follows a number stands alone
+// rather than starting a longer name such as a variable.
+func danglingExponent(rest []rune) bool {
+	return len(rest) == 0 || !unicode.IsLetter(rest[0]) && !unicode.IsDigit(rest[0]) && rest[0] != '_'
+}
+
+func startsExponent(rest []rune) bool {
+	if len(rest) > 0 && isDigit(rest[0]) {
+		return true
+	}
+	return len(rest) > 1 && (rest[0] == '+' || rest[0] == '-') && isDigit(rest[1])
+}
+
+// parseNumber validates a literal found by scanNumber and returns it in a
+// form accepted by both strconv.ParseFloat and big.Rat.SetString.
+func parseNumber(literal token) (string, error) {
+	text := literal.value
+
+	if len(text) >= 2 {
+		if base, ok := radixPrefixes[text[:2]]; ok {
+			return parseInteger(literal, base)
+		}
+	}
+
+	return parseDecimal(literal)
+}
+
+func parseInteger(literal token, base int) (string, error) {
+	text := literal.value
+	digits := text[2:]
+	if digits == "" {
+		return "", literalError(literal, 0, len(text), fmt.Sprintf("add %s digits after %q", baseNames[base], text))
+	}
+	if err := checkDigits(literal, digits, 2, base); err != nil {
+		return "", err
+	}
+
+	value, _ := new(big.Int).SetString(strings.ReplaceAll(digits, "_", ""), base)
+
+	return checkRange(literal, value.String())
+}
+
+func parseDecimal(literal token) (string, error) {
+	text := literal.value
+
+	mantissa, exponent, hasExponent := strings.Cut(strings.ToLower(text), "e")
+
+	integer, fraction, _ := strings.Cut(mantissa, ".")
+	if dot := strings.IndexByte(fraction, '.'); dot >= 0 {
+		return "", literalError(literal, len(integer)+1+dot, 1, "use a single decimal point")
+	}
+	if integer == "" && fraction == "" {
+		return "", literalError(literal, 0, len(text), "add digits around the decimal point")
+	}
+	if err := checkDigits(literal, integer, 0, 10); err != nil {
+		return "", err
+	}
+	if err := checkDigits(literal, fraction, len(integer)+1, 10); err != nil {
+		return "", err
+	}
+
+	if hasExponent {
+		offset := len(mantissa) + 1
+		if strings.HasPrefix(exponent, "+") || strings.HasPrefix(exponent, "-") {
+			exponent, offset = exponent[1:], offset+1
+		}
+		if exponent == "" {
+			hint := "add the digits of the exponent"
+			if !strings.ContainsAny(text[len(mantissa):], "+-") {
+				hint += fmt.Sprintf(", or write %s*e to multiply by e", text[:len(mantissa)])
+			}
+			return "", literalError(literal, 0, len(text), hint)
+		}
+		if dot := strings.IndexByte(exponent, '.'); dot >= 0 {
+			return "", literalError(literal, offset+dot, 1, "the exponent must be an integer")
+		}
+		if err := checkDigits(literal, exponent, offset, 10); err != nil {
+			return "", err
+		}
+		if n, err := strconv.Atoi(strings.ReplaceAll(exponent, "_", "")); err != nil || n > maxLiteralExponent {
+			return "", literalError(literal, offset, len(exponent), fmt.Sprintf("use an exponent of at most %d", maxLiteralExponent))
+		}
+	}
+
+	return checkRange(literal, strings.ReplaceAll(text, "_", ""))
+}
+
+// checkDigits validates the digits of part, which starts at offset within
+// the literal; underscores are only allowed between two digits.
+func checkDigits(literal token, part string, offset, base int) error {
+	for i, ch := range part {
+		if ch == '_' {
+			if i == 0 || i == len(part)-1 || part[i-1] == '_' {
+				return literalError(literal, offset+i, 1, "underscores must separate digits")
+			}
+			continue
+		}
+		if digitValue(ch) >= base {
+			return literalError(literal, offset+i, 1, fmt.Sprintf("%q is not a %s digit", ch, baseNames[base]))
+		}
+	}
+	return nil
+}
+
+func checkRange(literal token, value string) (string, error) {
+	if _, err := strconv.ParseFloat(value, 64); err != nil {
+		return "", literalError(literal, 0, len(literal.value), "the number is out of range")
+	}
+	return value, nil
+}
+
+// literalError reports length bytes at offset within a literal; literals are
+// always ASCII, so byte and rune offsets coincide.
+func literalError(literal token, offset, length int, suggestion string) error {
+	return errorAt(ErrInvalidNumber, token{literal.value[offset : offset+length], literal.pos + offset}, suggestion)
+}
+
+func digitValue(ch rune) int {
+	switch {
+	case isDigit(ch):
+		return int(ch - '0')
+	case 'a' <= ch && ch <= 'z':
+		return int(ch-'a') + 10
+	case 'A' <= ch && ch <= 'Z':
+		return int(ch-'A') + 10
+	}
+	return 36
+}
+
+func isDigit(ch rune) bool {
+	return '0' <= ch && ch <= '9'
+}
+
+func isASCIILetter(ch rune) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z'
+}
@@ -0,0 +1,65 @@
+package calculation
+
+import "math/big"
+
+// If is the conditional function if(cond, then, else). Only the chosen
+// branch is evaluated, so the scheduler must not dispatch it eagerly.
+const If = "if"
+
+// IsBoolean reports whether an operation yields a truth value, which is
+// represented as 1 or 0 and rendered as true or false.
+func IsBoolean(operation string) bool {
+	switch operation {
+	case UnaryNot, "==", "!=", "<", "<=", ">", ">=", "&&", "||":
+		return true
+	}
+	return false
+}
+
+func boolean(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func booleanExact(b bool) *big.Rat {
+	return new(big.Rat).SetFloat64(boolean(b))
+}
+
+// compare applies a comparison operator to the result of cmp.Compare.
+func compare(operation string, c int) bool {
+	switch operation {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+func compareExact(operation string) exactOperation {
+	return func(args []*big.Rat, _ int) (*big.Rat, error) {
+		return booleanExact(compare(operation, args[0].Cmp(args[1]))), nil
+	}
+}
+
+// chooseBranch returns the index of the if() argument to evaluate.
+func chooseBranch(condition bool) int {
+	if condition {
+		return 1
+	}
+	return 2
+}
+
+func conditional(args []float64) float64 {
+	return args[chooseBranch(args[0] != 0)]
+}
@@ -0,0 +1,480 @@
+package calculation
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Functions on vectors and matrices.
+const (
+	Dot       = "dot"
+	Transpose = "transpose"
+	Det       = "det"
+)
+
+// Matrix is the value of an array literal. A vector such as [1,2,3] is a
+// single row with Vector set, [[1,2],[3,4]] is a 2x2 matrix.
+type Matrix struct {
+	Rows   int
+	Cols   int
+	Values []float64 // row by row
+	Vector bool
+}
+
+func NewMatrix(rows, cols int) *Matrix {
+	return &Matrix{Rows: rows, Cols: cols, Values: make([]float64, rows*cols)}
+}
+
+func (m *Matrix) At(i, j int) float64 {
+	return m.Values[i*m.Cols+j]
+}
+
+func (m *Matrix) Set(i, j int, value float64) {
+	m.Values[i*m.Cols+j] = value
+}
+
+// Shape is rendered like 2x3, or as the length of a vector.
+func (m *Matrix) Shape() string {
+	if m.Vector {
+		return fmt.Sprintf("vector of %d", m.Cols)
+	}
+	return fmt.Sprintf("%dx%d matrix", m.Rows, m.Cols)
+}
+
+// String renders m as an array literal that ParseMatrix accepts.
+func (m *Matrix) String() string {
+	var b strings.Builder
+	if !m.Vector {
+		b.WriteString("[")
+	}
+	for i := 0; i < m.Rows; i++ {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString("[")
+		for j := 0; j < m.Cols; j++ {
+			if j > 0 {
+				b.WriteString(",")
+			}
+			b.WriteString(strconv.FormatFloat(m.At(i, j), 'g', -1, 64))
+		}
+		b.WriteString("]")
+	}
+	if !m.Vector {
+		b.WriteString("]")
+	}
+	return b.String()
+}
+
+// Block copies rows [r0, r1) and columns [c0, c1) of m into a new matrix.
+func (m *Matrix) Block(r0, r1, c0, c1 int) *Matrix {
+	block := NewMatrix(r1-r0, c1-c0)
+	for i := r0; i < r1; i++ {
+		copy(block.Values[(i-r0)*block.Cols:(i-r0+1)*block.Cols], m.Values[i*m.Cols+c0:i*m.Cols+c1])
+	}
+	return block
+}
+
+// SetBlock copies block into m with its top left corner at row r0, column c0.
+func (m *Matrix) SetBlock(r0, c0 int, block *Matrix) {
+	for i := 0; i < block.Rows; i++ {
+		copy(m.Values[(r0+i)*m.Cols+c0:], block.Values[i*block.Cols:(i+1)*block.Cols])
+	}
+}
+
+// ParseMatrix parses an array literal as produced by Matrix.String.
+func ParseMatrix(value string) (*Matrix, bool) {
+	if !strings.HasPrefix(value, "[") {
+		return nil, false
+	}
+	m, _, err := scanMatrix([]rune(value), 0)
+	return m, err == nil
+}
+
+func isMatrix(value string) bool {
+	_, ok := ParseMatrix(value)
+	return ok
+}
+
+// scanMatrix reads the array literal that starts at runes[pos] and returns
+// it with the position after its closing bracket. Elements are numbers with
+// an optional sign; rows of a matrix must have the same length.
+func scanMatrix(runes []rune, pos int) (*Matrix, int, error) {
+	start := pos
+	fail := func(at int, suggestion string) error {
+		tok := token{"[", start}
+		if at < len(runes) {
+			tok = token{string(runes[at]), at}
+		}
+		return errorAt(ErrInvalidMatrix, tok, suggestion)
+	}
+
+	skipSpace := func() {
+		for pos < len(runes) && unicode.IsSpace(runes[pos]) {
+			pos++
+		}
+	}
+
+	// row reads "[a, b, ...]" starting at the opening bracket.
+	row := func() ([]float64, error) {
+		var values []float64
+		pos++
+		for {
+			skipSpace()
+			numberStart := pos
+			if pos < len(runes) && (runes[pos] == '-' || runes[pos] == '+') {
+				pos++
+			}
+			if pos >= len(runes) || !isDigit(runes[pos]) && runes[pos] != '.' {
+				return nil, fail(pos, "use numbers as elements")
+			}
+			end := scanNumber(runes, pos)
+			literal, err := parseNumber(token{string(runes[pos:end]), pos})
+			if err != nil {
+				return nil, err
+			}
+			value, _ := strconv.ParseFloat(literal, 64)
+			if runes[numberStart] == '-' {
+				value = -value
+			}
+			values = append(values, value)
+			pos = end
+
+			skipSpace()
+			switch {
+			case pos < len(runes) && runes[pos] == ',':
+				pos++
+			case pos < len(runes) && runes[pos] == ']':
+				pos++
+				return values, nil
+			default:
+				return nil, fail(pos, `separate elements with "," and close the row with "]"`)
+			}
+		}
+	}
+
+	pos++
+	skipSpace()
+	if pos < len(runes) && runes[pos] != '[' {
+		pos = start
+		values, err := row()
+		if err != nil {
+			return nil, 0, err
+		}
+		return &Matrix{Rows: 1, Cols: len(values), Values: values, Vector: true}, pos, nil
+	}
+
+	m := &Matrix{}
+	for {
+		skipSpace()
+		if pos >= len(runes) || runes[pos] != '[' {
+			return nil, 0, fail(pos, `start each row with "["`)
+		}
+		rowStart := pos
+		values, err := row()
+		if err != nil {
+			return nil, 0, err
+		}
+		if m.Rows > 0 && len(values) != m.Cols {
+			return nil, 0, fail(rowStart, fmt.Sprintf("give every row %d elements", m.Cols))
+		}
+		m.Rows, m.Cols = m.Rows+1, len(values)
+		m.Values = append(m.Values, values...)
+
+		skipSpace()
+		switch {
+		case pos < len(runes) && runes[pos] == ',':
+			pos++
+		case pos < len(runes) && runes[pos] == ']':
+			return m, pos + 1, nil
+		default:
+			return nil, 0, fail(pos, `separate rows with "," and close the matrix with "]"`)
+		}
+	}
+}
+
+// value is what the float evaluator computes: a number or a matrix.
+// Quantities keep number in SI base units together with their dimension
+// and the unit to display them in.
+type value struct {
+	number complex128
+	matrix *Matrix
+	dim    Dimension
+	unit   string
+}
+
+func (v value) String() string {
+	switch {
+	case v.matrix != nil:
+		return v.matrix.String()
+	case v.unit != "":
+		return FormatQuantity(real(v.number), v.unit)
+	case !v.dim.IsZero():
+		return FormatQuantity(real(v.number), v.dim.String())
+	}
+	return FormatComplex(v.number)
+}
+
+func parseValue(literal string) (value, bool) {
+	if m, ok := ParseMatrix(literal); ok {
+		return value{matrix: m}, true
+	}
+	if z, ok := ParseComplex(literal); ok {
+		return value{number: z}, true
+	}
+	if isFraction(literal) {
+		r, _ := ParseExact(literal)
+		f, _ := r.Float64()
+		return value{number: complex(f, 0)}, true
+	}
+	q, ok := ParseQuantity(literal)
+	if !ok {
+		return value{}, false
+	}
+	_, dim, _ := parseUnit(q.Unit)
+	return value{number: complex(q.SI(), 0), dim: dim, unit: q.Unit}, true
+}
+
+func isLiteral(literal string) bool {
+	_, ok := parseValue(literal)
+	return ok
+}
+
+// matrixToken returns the first array literal or matrix function of rpn.
+func matrixToken(rpn []token) (token, bool) {
+	for _, tok := range rpn {
+		if isMatrix(tok.value) || isMatrixFunction(operationName(tok.value)) {
+			return tok, true
+		}
+	}
+	return token{}, false
+}
+
+func isMatrixFunction(name string) bool {
+	return name == Dot || name == Transpose || name == Det
+}
+
+// applyValue computes an operation on numbers with applyNumber and hands
+// anything involving a quantity to applyQuantity and a matrix to
+// applyMatrix.
+func applyValue(tok token, args []value) (value, error) {
+	name := operationName(tok.value)
+	hasMatrix := isMatrixFunction(name)
+	hasUnit := name == ConvertTo
+	numbers := make([]complex128, len(args))
+	for i, arg := range args {
+		hasMatrix = hasMatrix || arg.matrix != nil
+		hasUnit = hasUnit || arg.unit != "" || !arg.dim.IsZero()
+		numbers[i] = arg.number
+	}
+
+	if hasUnit {
+		return applyQuantity(tok, args)
+	}
+	if hasMatrix {
+		return applyMatrix(tok, args)
+	}
+	result, err := applyNumber(tok, numbers)
+	return value{number: result}, err
+}
+
+func applyMatrix(tok token, args []value) (value, error) {
+	for _, arg := range args {
+		if arg.matrix == nil && imag(arg.number) != 0 {
+			return value{}, errorAt(ErrComplexOperand, tok, "matrices hold real numbers")
+		}
+	}
+
+	var result *Matrix
+	var err error
+	switch operationName(tok.value) {
+	case Dot:
+		return dot(tok, args[0].matrix, args[1].matrix)
+	case Det:
+		return determinant(tok, args[0].matrix)
+	case Transpose:
+		if args[0].matrix == nil {
+			return value{}, errorAt(ErrShapeMismatch, tok, "transpose a matrix")
+		}
+		result = transpose(args[0].matrix)
+	case UnaryMinus:
+		result = scale(args[0].matrix, -1)
+	case "+", "-":
+		result, err = elementwise(tok, args[0].matrix, args[1].matrix)
+	case "*":
+		switch {
+		case args[0].matrix == nil:
+			result = scale(args[1].matrix, real(args[0].number))
+		case args[1].matrix == nil:
+			result = scale(args[0].matrix, real(args[1].number))
+		default:
+			result, err = multiply(tok, args[0].matrix, args[1].matrix)
+		}
+	default:
+		return value{}, errorAt(ErrMatrixOperand, tok, "use +, -, *, dot, transpose or det with matrices")
+	}
+
+	if err != nil {
+		return value{}, err
+	}
+	return value{matrix: result}, nil
+}
+
+func elementwise(tok token, a, b *Matrix) (*Matrix, error) {
+	if a == nil || b == nil {
+		return nil, errorAt(ErrMatrixOperand, tok, fmt.Sprintf("%s matrices of the same shape", verb(tok.value)))
+	}
+	if a.Rows != b.Rows || a.Cols != b.Cols || a.Vector != b.Vector {
+		return nil, errorAt(ErrShapeMismatch, tok, fmt.Sprintf("%s a %s and a %s", verb(tok.value), a.Shape(), b.Shape()))
+	}
+
+	result := &Matrix{Rows: a.Rows, Cols: a.Cols, Values: make([]float64, len(a.Values)), Vector: a.Vector}
+	for i := range a.Values {
+		if tok.value == "+" {
+			result.Values[i] = a.Values[i] + b.Values[i]
+		} else {
+			result.Values[i] = a.Values[i] - b.Values[i]
+		}
+	}
+	return result, nil
+}
+
+func verb(operation string) string {
+	if operation == "+" {
+		return "add"
+	}
+	return "subtract"
+}
+
+func scale(m *Matrix, factor float64) *Matrix {
+	result := &Matrix{Rows: m.Rows, Cols: m.Cols, Values: make([]float64, len(m.Values)), Vector: m.Vector}
+	for i, v := range m.Values {
+		result.Values[i] = v * factor
+	}
+	return result
+}
+
+// multiplyShape returns the shape of a*b, or a hint when the shapes do not
+// agree. A vector is a column on the right of a matrix and a row on its
+// left; the product is then a vector as well.
+func multiplyShape(a, b *Matrix) (rows, cols int, vector bool, hint string) {
+	switch {
+	case a.Vector && b.Vector:
+		return 0, 0, false, "use dot(u, v) to multiply two vectors"
+	case b.Vector:
+		if a.Cols != b.Cols {
+			return 0, 0, false, fmt.Sprintf("multiply a %s by a vector of %d", a.Shape(), a.Cols)
+		}
+		return 1, a.Rows, true, ""
+	case a.Vector:
+		if a.Cols != b.Rows {
+			return 0, 0, false, fmt.Sprintf("multiply a vector of %d by a %s", b.Rows, b.Shape())
+		}
+		return 1, b.Cols, true, ""
+	case a.Cols != b.Rows:
+		return 0, 0, false, fmt.Sprintf("multiply a %s by a matrix with %d rows", a.Shape(), a.Cols)
+	}
+	return a.Rows, b.Cols, false, ""
+}
+
+// Multiply computes the product of a and b, whose shapes must agree.
+func Multiply(a, b *Matrix) *Matrix {
+	rows, cols, vector, _ := multiplyShape(a, b)
+	result := &Matrix{Rows: rows, Cols: cols, Values: make([]float64, rows*cols), Vector: vector}
+
+	left, right := a, b
+	if b.Vector {
+		right = &Matrix{Rows: b.Cols, Cols: 1, Values: b.Values}
+	}
+	for i := 0; i < left.Rows; i++ {
+		for j := 0; j < right.Cols; j++ {
+			var sum float64
+			for k := 0; k < left.Cols; k++ {
+				sum += left.At(i, k) * right.At(k, j)
+			}
+			result.Values[i*right.Cols+j] = sum
+		}
+	}
+	return result
+}
+
+func multiply(tok token, a, b *Matrix) (*Matrix, error) {
+	if _, _, _, hint := multiplyShape(a, b); hint != "" {
+		return nil, errorAt(ErrShapeMismatch, tok, hint)
+	}
+	return Multiply(a, b), nil
+}
+
+func transpose(m *Matrix) *Matrix {
+	if m.Vector {
+		return m
+	}
+	result := NewMatrix(m.Cols, m.Rows)
+	for i := 0; i < m.Rows; i++ {
+		for j := 0; j < m.Cols; j++ {
+			result.Set(j, i, m.At(i, j))
+		}
+	}
+	return result
+}
+
+func dot(tok token, a, b *Matrix) (value, error) {
+	if a == nil || b == nil || !a.Vector || !b.Vector {
+		return value{}, errorAt(ErrShapeMismatch, tok, "dot expects two vectors")
+	}
+	if a.Cols != b.Cols {
+		return value{}, errorAt(ErrShapeMismatch, tok, fmt.Sprintf("dot a vector of %d with a vector of %d", a.Cols, b.Cols))
+	}
+
+	var sum float64
+	for i := range a.Values {
+		sum += a.Values[i] * b.Values[i]
+	}
+	return value{number: complex(sum, 0)}, nil
+}
+
+func determinant(tok token, m *Matrix) (value, error) {
+	if m == nil || m.Vector || m.Rows != m.Cols {
+		return value{}, errorAt(ErrShapeMismatch, tok, "det expects a square matrix")
+	}
+	return value{number: complex(Determinant(m), 0)}, nil
+}
+
+// Determinant computes the determinant of a square matrix by Gaussian
+// elimination with partial pivoting.
+func Determinant(m *Matrix) float64 {
+	n := m.Rows
+	a := make([]float64, len(m.Values))
+	copy(a, m.Values)
+
+	det := 1.0
+	for col := 0; col < n; col++ {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(a[row*n+col]) > math.Abs(a[pivot*n+col]) {
+				pivot = row
+			}
+		}
+		if a[pivot*n+col] == 0 {
+			return 0
+		}
+		if pivot != col {
+			for k := 0; k < n; k++ {
+				a[col*n+k], a[pivot*n+k] = a[pivot*n+k], a[col*n+k]
+			}
+			det = -det
+		}
+
+		det *= a[col*n+col]
+		for row := col + 1; row < n; row++ {
+			factor := a[row*n+col] / a[col*n+col]
+			for k := col; k < n; k++ {
+				a[row*n+k] -= factor * a[col*n+k]
+			}
+		}
+	}
+	return det
+}
@@ -0,0 +1,64 @@
+package calculation
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMatrixErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		opts       Options
+		kind       error
+		position   int
+	}{
+		{"ragged rows", "[[1, 2], [3]]", Options{}, ErrInvalidMatrix, 9},
+		{"empty", "[]", Options{}, ErrInvalidMatrix, 1},
+		{"unclosed", "[1, 2", Options{}, ErrInvalidMatrix, 0},
+		{"expression element", "[1 + 2]", Options{}, ErrInvalidMatrix, 3},
+		{"added shapes", "[1, 2] + [1, 2, 3]", Options{}, ErrShapeMismatch, 7},
+		{"two vectors", "[1, 2] * [3, 4]", Options{}, ErrShapeMismatch, 7},
+		{"product shapes", "[[1, 2]] * [[1, 2]]", Options{}, ErrShapeMismatch, 9},
+		{"not square", "det([[1, 2, 3], [4, 5, 6]])", Options{}, ErrShapeMismatch, 0},
+		{"dot of numbers", "dot(1, 2)", Options{}, ErrShapeMismatch, 0},
+		{"scalar sum", "[1, 2] + 1", Options{}, ErrMatrixOperand, 7},
+		{"division", "[1, 2] / 2", Options{}, ErrMatrixOperand, 7},
+		{"condition", "if([1], 2, 3)", Options{}, ErrMatrixOperand, 0},
+		{"exact precision", "2 * [1, 2]", Options{Precision: PrecisionExact}, ErrMatrixOperand, 4},
+		{"rational mode", "det(2)", Options{NumberMode: NumberModeRational}, ErrMatrixOperand, 0},
+		{"complex scalar", "2i * [1, 2]", Options{}, ErrComplexOperand, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := RPNWithOptions(tt.expression, tt.opts)
+
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) || !errors.Is(err, tt.kind) {
+				t.Fatalf("RPNWithOptions(%q) error = %v, want %v", tt.expression, err, tt.kind)
+			}
+			if parseErr.Position != tt.position {
+				t.Errorf("error position = %d, want %d", parseErr.Position, tt.position)
+			}
+		})
+	}
+}
+
+func TestMatrixBlocks(t *testing.T) {
+	a, _ := ParseMatrix("[[1,2,3],[4,5,6],[7,8,9]]")
+	b, _ := ParseMatrix("[[1,0,2],[0,1,0],[3,0,1]]")
+
+	product := NewMatrix(a.Rows, b.Cols)
+	for r := 0; r < a.Rows; r += 2 {
+		for c := 0; c < b.Cols; c += 2 {
+			rows := a.Block(r, min(r+2, a.Rows), 0, a.Cols)
+			cols := b.Block(0, b.Rows, c, min(c+2, b.Cols))
+			product.SetBlock(r, c, Multiply(rows, cols))
+		}
+	}
+
+	if got, want := product.String(), Multiply(a, b).String(); got != want {
+		t.Errorf("blocked product = %s, want %s", got, want)
+	}
+}
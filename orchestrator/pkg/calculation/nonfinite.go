@@ -0,0 +1,66 @@
+package calculation
+
+import (
+	"fmt"
+	"math"
+)
+
+// Policies for results that are not finite numbers, such as the +Inf of
+// 10^400 or the NaN of 0*log(0). NonFiniteError is the default.
+const (
+	NonFiniteError    = "error"
+	NonFiniteSaturate = "saturate" // ±Inf becomes ±math.MaxFloat64; NaN is still an error
+	NonFiniteAllow    = "allow"
+)
+
+// ValidNonFinite reports whether policy is one of the NonFinite policies or
+// empty.
+func ValidNonFinite(policy string) bool {
+	return policy == "" || policy == NonFiniteError || policy == NonFiniteSaturate || policy == NonFiniteAllow
+}
+
+// ApplyNonFinite returns x as policy lets it be stored, or ErrNonFiniteResult.
+func ApplyNonFinite(policy string, x float64) (float64, error) {
+	switch {
+	case !math.IsInf(x, 0) && !math.IsNaN(x), policy == NonFiniteAllow:
+		return x, nil
+	case policy == NonFiniteSaturate && math.IsInf(x, 1):
+		return math.MaxFloat64, nil
+	case policy == NonFiniteSaturate && math.IsInf(x, -1):
+		return -math.MaxFloat64, nil
+	}
+	return x, fmt.Errorf("%w: %v", ErrNonFiniteResult, x)
+}
+
+// applyNonFinite applies policy to every number of v.
+func applyNonFinite(tok token, v value, policy string) (value, error) {
+	var err error
+	check := func(x float64) float64 {
+		x, xErr := ApplyNonFinite(policy, x)
+		err = firstError(err, xErr)
+		return x
+	}
+
+	if v.matrix != nil {
+		matrix := *v.matrix
+		matrix.Values = make([]float64, len(v.matrix.Values))
+		for i, x := range v.matrix.Values {
+			matrix.Values[i] = check(x)
+		}
+		v.matrix = &matrix
+	} else {
+		v.number = complex(check(real(v.number)), check(imag(v.number)))
+	}
+
+	if err != nil {
+		return v, errorAt(ErrNonFiniteResult, tok, nonFiniteHint(policy))
+	}
+	return v, nil
+}
+
+func nonFiniteHint(policy string) string {
+	if policy == NonFiniteSaturate {
+		return "the result is undefined"
+	}
+	return "the result overflows or is undefined"
+}
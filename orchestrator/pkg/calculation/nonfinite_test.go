@@ -0,0 +1,64 @@
+package calculation
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestNonFinitePolicy(t *testing.T) {
+	tests := []struct {
+		expression string
+		policy     string
+		position   int
+		err        error
+	}{
+		{"1 + 10^400", "", 6, ErrNonFiniteResult},
+		{"1 + 10^400", NonFiniteError, 6, ErrNonFiniteResult},
+		{"1e308 * 10", "", 6, ErrNonFiniteResult},
+		{"log(0)", "", 0, ErrNonFiniteResult},
+		{"10^400 - 10^400", NonFiniteSaturate, 0, nil},
+		{"0 * log(0)", NonFiniteError, 4, ErrNonFiniteResult},
+		{"0 * log(0)", NonFiniteSaturate, 0, nil},
+		{"0 * log(0)", NonFiniteAllow, 0, nil},
+		{"if(1, 2, log(0))", "", 0, nil},
+		{"1", "ignore", 0, ErrUnknownPolicy},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy+" "+tt.expression, func(t *testing.T) {
+			_, err := RPNWithOptions(tt.expression, Options{NonFinite: tt.policy})
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("RPNWithOptions(%q) error = %v, want %v", tt.expression, err, tt.err)
+			}
+
+			var parseErr *ParseError
+			if errors.As(err, &parseErr) && parseErr.Position != tt.position {
+				t.Errorf("position = %d, want %d", parseErr.Position, tt.position)
+			}
+		})
+	}
+}
+
+func TestApplyNonFinite(t *testing.T) {
+	tests := []struct {
+		policy   string
+		x        float64
+		expected float64
+		err      bool
+	}{
+		{"", 1.5, 1.5, false},
+		{"", math.Inf(1), math.Inf(1), true},
+		{NonFiniteSaturate, math.Inf(1), math.MaxFloat64, false},
+		{NonFiniteSaturate, math.Inf(-1), -math.MaxFloat64, false},
+		{NonFiniteSaturate, math.NaN(), math.NaN(), true},
+		{NonFiniteAllow, math.Inf(-1), math.Inf(-1), false},
+	}
+
+	for _, tt := range tests {
+		got, err := ApplyNonFinite(tt.policy, tt.x)
+		if (err != nil) != tt.err || got != tt.expected && !math.IsNaN(tt.expected) {
+			t.Errorf("ApplyNonFinite(%q, %v) = %v, %v", tt.policy, tt.x, got, err)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package calculation
+
+import "strings"
+
+// Decimal separators. With DecimalComma, 1,5 is one and a half and function
+// arguments are separated by semicolons: max(1,5; 2).
+const (
+	DecimalPoint = "."
+	DecimalComma = ","
+)
+
+// operatorAliases are operator characters copied from documents.
+var operatorAliases = map[rune]rune{
+	'×': '*',
+	'·': '*',
+	'⋅': '*',
+	'÷': '/',
+	'∕': '/',
+	'−': '-',
+}
+
+// normalize rewrites expression into the syntax createToken reads. Every
+// rune is replaced by exactly one rune, so positions in the result are
+// positions in expression.
+func normalize(expression string, separator string) string {
+	return strings.Map(func(ch rune) rune {
+		if alias, ok := operatorAliases[ch]; ok {
+			return alias
+		}
+		if separator == DecimalComma {
+			switch ch {
+			case ',':
+				return '.'
+			case ';':
+				return ','
+			}
+		}
+		return ch
+	}, expression)
+}
+
+// endsOperand reports whether an operand written right after tok is
+// multiplied by it, as in 2(3+4), (1+2)(3+4) or 3pi.
+func endsOperand(tok token) bool {
+	return tok.value == ")" || isLiteral(tok.value)
+}
@@ -0,0 +1,63 @@
+package calculation
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestInputSyntax(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		separator  string
+		expected   []string
+	}{
+		{"number before parenthesis", "2(3+4)", "", []string{"2", "3", "4", "+", "*"}},
+		{"parentheses", "(1+2)(3+4)", "", []string{"1", "2", "+", "3", "4", "+", "*"}},
+		{"number after parenthesis", "(1+2)3", "", []string{"1", "2", "+", "3", "*"}},
+		{"constant", "3pi", "", []string{"3", constants["pi"], "*"}},
+		{"function", "2sqrt(4)", "", []string{"2", "4", "sqrt:1", "*"}},
+		{"binds like *", "1/2(3)", "", []string{"1", "2", "/", "3", "*"}},
+		{"power binds tighter", "2(3)^2", "", []string{"2", "3", "2", "^", "*"}},
+		{"unicode operators", "6 × 7 ÷ 2 − 1", "", []string{"6", "7", "*", "2", "/", "1", "-"}},
+		{"decimal comma", "1,5 + 2", DecimalComma, []string{"1.5", "2", "+"}},
+		{"semicolon arguments", "max(1,5; 2)", DecimalComma, []string{"1.5", "2", "max:2"}},
+		{"decimal point", "max(1,5)", DecimalPoint, []string{"1", "5", "max:2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rpn, err := RPNWithOptions(tt.expression, Options{DecimalSeparator: tt.separator})
+			if err != nil {
+				t.Fatalf("RPNWithOptions(%q) error = %v", tt.expression, err)
+			}
+			if !slices.Equal(rpn, tt.expected) {
+				t.Errorf("RPNWithOptions(%q) = %v, want %v", tt.expression, rpn, tt.expected)
+			}
+		})
+	}
+
+	if _, err := RPNWithOptions("1", Options{DecimalSeparator: "'"}); !errors.Is(err, ErrUnknownSeparator) {
+		t.Errorf("unknown separator: error = %v, want %v", err, ErrUnknownSeparator)
+	}
+	if _, err := RPN("1 000"); err == nil {
+		t.Error("RPN(\"1 000\") error = nil, want digits groups to stay invalid")
+	}
+	if _, err := RPN("3e"); !errors.Is(err, ErrInvalidNumber) {
+		t.Errorf("RPN(\"3e\") error = %v, want %v instead of 3 * e", err, ErrInvalidNumber)
+	}
+}
+
+func TestInputSyntaxSpans(t *testing.T) {
+	tree, err := ParseWithOptions("2(3+4) × 1,5", Options{DecimalSeparator: DecimalComma})
+	if err != nil {
+		t.Fatalf("ParseWithOptions() error = %v", err)
+	}
+	if got := tree.String(); got != "2 * (3 + 4) * 1.5" {
+		t.Errorf("tree = %q, want 2 * (3 + 4) * 1.5", got)
+	}
+	if literal := tree.Args[1]; literal.Start != 9 || literal.End != 12 {
+		t.Errorf("1,5 spans %d..%d, want 9..12", literal.Start, literal.End)
+	}
+}
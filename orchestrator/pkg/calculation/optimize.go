@@ -0,0 +1,243 @@
+package calculation
+
+import (
+	"math/big"
+	"math/cmplx"
+
+	"github.com/DobryySoul/orchestrator/pkg/calculation/ast"
+)
+
+// Simplification is a rewrite made by Optimize, printed as expressions.
+type Simplification struct {
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// Optimize computes the parts of a bound tree that do not depend on
+// variables or references, drops the branch of if() that a constant
+// condition rules out and applies the identities x*1, x/1, x+0, x-0 and,
+// for a finite number x, x*0.
+// The tree is not modified; the rewrites are returned innermost first.
+func Optimize(tree *ast.Node, opts Options) (*ast.Node, []Simplification) {
+	o := optimizer{opts: opts}
+	return o.optimize(tree), o.simplifications
+}
+
+type optimizer struct {
+	opts            Options
+	simplifications []Simplification
+}
+
+func (o *optimizer) optimize(node *ast.Node) *ast.Node {
+	if node.Kind != ast.Literal && isConstantTree(node) {
+		if folded, ok := o.fold(node); ok {
+			return o.rewrite(node, folded)
+		}
+	}
+
+	optimized := *node
+	optimized.Args = make([]*ast.Node, len(node.Args))
+	for i, arg := range node.Args {
+		optimized.Args[i] = o.optimize(arg)
+	}
+
+	if result := o.simplify(&optimized); result != &optimized {
+		return o.rewrite(&optimized, result)
+	}
+	return &optimized
+}
+
+func (o *optimizer) rewrite(before, after *ast.Node) *ast.Node {
+	o.simplifications = append(o.simplifications, Simplification{
+		Before: before.String(),
+		After:  after.String(),
+	})
+	return after
+}
+
+// fold evaluates a constant subtree into a literal with the same span.
+func (o *optimizer) fold(node *ast.Node) (*ast.Node, bool) {
+	literal := &ast.Node{Kind: ast.Literal, Start: node.Start, End: node.End}
+
+	if o.opts.Exact() {
+		digits := o.opts.Digits
+		if digits <= 0 {
+			digits = DefaultDigits
+		}
+		value, err := foldExact(node, digits, o.opts.NumberMode == NumberModeRational)
+		if err != nil {
+			return nil, false
+		}
+		literal.Value = value.RatString()
+		return literal, true
+	}
+
+	value, err := foldFloat(node)
+	if err != nil || cmplx.IsInf(value.number) || cmplx.IsNaN(value.number) {
+		return nil, false
+	}
+	literal.Value = value.String()
+	return literal, true
+}
+
+// simplify returns the node that replaces node, or node itself.
+func (o *optimizer) simplify(node *ast.Node) *ast.Node {
+	if node.Kind == ast.Call && node.Value == If && isConstantTree(node.Args[0]) {
+		if condition, ok := exactValue(node.Args[0]); ok {
+			return node.Args[chooseBranch(condition.Sign() != 0)]
+		}
+	}
+	if node.Kind != ast.Binary {
+		return node
+	}
+
+	left, right := node.Args[0], node.Args[1]
+	switch node.Value {
+	case "*":
+		// an x that is computed may fail or not be finite, and a matrix or
+		// quantity x keeps its shape or unit
+		if isConstantValue(left, 0) && isFiniteScalar(right) || isConstantValue(right, 0) && isFiniteScalar(left) {
+			return &ast.Node{Kind: ast.Literal, Value: "0", Start: node.Start, End: node.End}
+		}
+		if isConstantValue(left, 1) {
+			return right
+		}
+		if isConstantValue(right, 1) {
+			return left
+		}
+	case "/":
+		if isConstantValue(right, 1) {
+			return left
+		}
+	case "+":
+		if isConstantValue(left, 0) {
+			return right
+		}
+		if isConstantValue(right, 0) {
+			return left
+		}
+	case "-":
+		if isConstantValue(right, 0) {
+			return left
+		}
+	}
+
+	return node
+}
+
+// isConstantTree reports whether node depends on nothing but literals and
+// constants such as pi.
+func isConstantTree(node *ast.Node) bool {
+	constant := true
+	ast.Walk(node, func(n *ast.Node) bool {
+		if n.Kind == ast.Variable || n.Kind == ast.Literal && n.Name != "" && !IsConstant(n.Name) {
+			constant = false
+		}
+		return constant
+	})
+	return constant
+}
+
+func isConstantValue(node *ast.Node, value int64) bool {
+	if node.Kind != ast.Literal || !isConstantTree(node) {
+		return false
+	}
+	rat, ok := exactValue(node)
+	return ok && rat.Cmp(big.NewRat(value, 1)) == 0
+}
+
+func exactValue(node *ast.Node) (*big.Rat, bool) {
+	if node.Kind != ast.Literal {
+		return nil, false
+	}
+	return ParseExact(node.Value)
+}
+
+// isFiniteScalar reports whether node is a literal holding a finite number
+// without a unit, such as a bound variable.
+func isFiniteScalar(node *ast.Node) bool {
+	if node.Kind != ast.Literal {
+		return false
+	}
+	v, ok := parseValue(node.Value)
+	return ok && v.matrix == nil && v.unit == "" && !cmplx.IsInf(v.number) && !cmplx.IsNaN(v.number)
+}
+
+// hasMatrix reports whether node contains an array literal or a matrix
+// function, which may make its value a matrix.
+func hasMatrix(node *ast.Node) bool {
+	found := false
+	ast.Walk(node, func(n *ast.Node) bool {
+		found = found || n.Kind == ast.Literal && isMatrix(n.Value) || n.Kind == ast.Call && isMatrixFunction(n.Value)
+		return !found
+	})
+	return found
+}
+
+func foldFloat(node *ast.Node) (value, error) {
+	if node.Kind == ast.Literal {
+		literal, ok := parseValue(node.Value)
+		if !ok {
+			return value{}, ErrInvalidNumber
+		}
+		return literal, nil
+	}
+
+	if node.Kind == ast.Call && node.Value == If {
+		condition, err := foldFloat(node.Args[0])
+		if err != nil {
+			return value{}, err
+		}
+		if condition.matrix != nil {
+			return value{}, ErrMatrixOperand
+		}
+		return foldFloat(node.Args[chooseBranch(condition.number != 0)])
+	}
+
+	args := make([]value, len(node.Args))
+	for i, arg := range node.Args {
+		folded, err := foldFloat(arg)
+		if err != nil {
+			return value{}, err
+		}
+		args[i] = folded
+	}
+
+	operation := node.Value
+	if node.Kind == ast.Call {
+		operation = FormatCall(node.Value, len(node.Args))
+	}
+	return applyValue(token{operation, node.Start}, args)
+}
+
+func foldExact(node *ast.Node, digits int, rational bool) (*big.Rat, error) {
+	if node.Kind == ast.Literal {
+		value, ok := ParseExact(node.Value)
+		if !ok {
+			return nil, ErrInvalidNumber
+		}
+		return value, nil
+	}
+
+	if node.Kind == ast.Call && node.Value == If {
+		condition, err := foldExact(node.Args[0], digits, rational)
+		if err != nil {
+			return nil, err
+		}
+		return foldExact(node.Args[chooseBranch(condition.Sign() != 0)], digits, rational)
+	}
+
+	args := make([]*big.Rat, len(node.Args))
+	for i, arg := range node.Args {
+		value, err := foldExact(arg, digits, rational)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = value
+	}
+
+	if rational && irrational(node.Value, args) {
+		return nil, ErrIrrationalOperation
+	}
+	return ApplyExact(node.Value, args, digits)
+}
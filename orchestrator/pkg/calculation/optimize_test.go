@@ -0,0 +1,116 @@
+package calculation
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		name            string
+		expression      string
+		variables       map[string]float64
+		precision       string
+		expected        string
+		simplifications []Simplification
+	}{
+		{
+			name:       "folds constants and identities",
+			expression: "2*3 + x*1 + 0",
+			variables:  map[string]float64{"x": 4},
+			expected:   "6 + x",
+			simplifications: []Simplification{
+				{"2 * 3", "6"},
+				{"x * 1", "x"},
+				{"6 + x + 0", "6 + x"},
+			},
+		},
+		{
+			name:            "whole expression",
+			expression:      "(1 + 2) * 4 - 2 ^ 3",
+			expected:        "4",
+			simplifications: []Simplification{{"(1 + 2) * 4 - 2 ^ 3", "4"}},
+		},
+		{
+			name:            "constants",
+			expression:      "r * (2 * pi)",
+			variables:       map[string]float64{"r": 1},
+			expected:        "r * 6.283185307179586",
+			simplifications: []Simplification{{"2 * pi", "6.283185307179586"}},
+		},
+		{
+			name:            "multiplication by zero",
+			expression:      "x * 0",
+			variables:       map[string]float64{"x": 1},
+			expected:        "0",
+			simplifications: []Simplification{{"x * 0", "0"}},
+		},
+		{
+			name:            "computed operand times zero is kept",
+			expression:      "0 * (x + y)",
+			variables:       map[string]float64{"x": 1, "y": 2},
+			expected:        "0 * (x + y)",
+			simplifications: nil,
+		},
+		{
+			name:            "matrix times zero stays a matrix",
+			expression:      "[[1, 2], [3, 4]] * 0",
+			expected:        "[[0,0],[0,0]]",
+			simplifications: []Simplification{{"[[1,2],[3,4]] * 0", "[[0,0],[0,0]]"}},
+		},
+		{
+			name:            "division and subtraction",
+			expression:      "(x - 0) / 1",
+			variables:       map[string]float64{"x": 1},
+			expected:        "x",
+			simplifications: []Simplification{{"x - 0", "x"}, {"x / 1", "x"}},
+		},
+		{
+			name:            "variable equal to one is kept",
+			expression:      "x * y",
+			variables:       map[string]float64{"x": 1, "y": 2},
+			expected:        "x * y",
+			simplifications: nil,
+		},
+		{
+			name:            "matrices",
+			expression:      "x * [1, 2] * 0 + [1, 2] * 2",
+			variables:       map[string]float64{"x": 1},
+			expected:        "x * [1,2] * 0 + [2,4]",
+			simplifications: []Simplification{{"[1,2] * 2", "[2,4]"}},
+		},
+		{
+			name:            "constant condition",
+			expression:      "if(2 > 1, x + 1, x / 0)",
+			variables:       map[string]float64{"x": 3},
+			expected:        "x + 1",
+			simplifications: []Simplification{{"2 > 1", "1"}, {"if(1, x + 1, x / 0)", "x + 1"}},
+		},
+		{
+			name:            "exact",
+			expression:      "x + 1 / 3",
+			variables:       map[string]float64{"x": 1},
+			precision:       PrecisionExact,
+			expected:        "x + 1/3",
+			simplifications: []Simplification{{"1 / 3", "1/3"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{Variables: tt.variables, Precision: tt.precision}
+			tree, err := ParseWithOptions(tt.expression, opts)
+			if err != nil {
+				t.Fatalf("ParseWithOptions() error = %v", err)
+			}
+
+			optimized, simplifications := Optimize(tree, opts)
+			if got := optimized.String(); got != tt.expected {
+				t.Errorf("Optimize() = %q, expected %q", got, tt.expected)
+			}
+			if !slices.Equal(simplifications, tt.simplifications) {
+				t.Errorf("Optimize() simplifications = %v, expected %v", simplifications, tt.simplifications)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package calculation
+
+import (
+	"fmt"
+	"unicode"
+
+	"github.com/DobryySoul/orchestrator/pkg/calculation/ast"
+)
+
+// Parse returns the syntax tree of expression. Nothing is bound or
+// evaluated: variables, constants and references stay Variable nodes.
+func Parse(expression string) (*ast.Node, error) {
+	if len(expression) == 0 {
+		return nil, ErrEmptyExpression
+	}
+
+	tokens, err := createToken(normalize(expression, DecimalPoint), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error while creating tokens: %w", withSource(err, expression))
+	}
+
+	output, err := convertingAnExpression(tokens)
+	if err != nil {
+		return nil, fmt.Errorf("error while converting expression: %w", withSource(err, expression))
+	}
+
+	if err := checkStructure(output); err != nil {
+		return nil, fmt.Errorf("error while checking expression: %w", withSource(err, expression))
+	}
+
+	return buildTree(normalize(expression, DecimalPoint), output, nil), nil
+}
+
+// ParseWithOptions validates expression like RPNWithOptions and returns its
+// tree with constants, variables and resolved references bound to literals.
+// Pending references stay Variable nodes.
+func ParseWithOptions(expression string, opts Options) (*ast.Node, error) {
+	output, err := compile(expression, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return buildTree(normalize(expression, opts.separator()), output, opts.Variables), nil
+}
+
+// buildTree assembles well-formed RPN into a tree, taking the spans of the
+// nodes from the source expression tokenized with variables.
+func buildTree(expression string, rpn []token, variables map[string]float64) *ast.Node {
+	runes := []rune(expression)
+
+	var stack []*ast.Node
+	for _, tok := range rpn {
+		node := &ast.Node{Value: tok.value, Start: tok.pos}
+
+		argc := 0
+		if name, n, ok := ParseCall(tok.value); ok {
+			node.Kind, node.Value, argc = ast.Call, name, n
+			node.End = closingParen(runes, tok.pos) + 1
+		} else if IsUnaryOperator(tok.value) {
+			node.Kind, argc = ast.Unary, 1
+		} else if IsBinaryOperator(tok.value) {
+			node.Kind, argc = ast.Binary, 2
+		} else if isLiteral(tok.value) {
+			node.Kind, node.End = ast.Literal, operandEnd(runes, tok.pos, variables)
+			if end, ok := targetEnd(runes, tok); ok {
+				node.End = end
+			}
+			if !isDigit(runes[tok.pos]) && runes[tok.pos] != '.' && runes[tok.pos] != '[' {
+				node.Name = string(runes[tok.pos:node.End])
+			}
+		} else {
+			node.Kind, node.End = ast.Variable, operandEnd(runes, tok.pos, variables)
+		}
+
+		if argc > 0 {
+			node.Args = append([]*ast.Node(nil), stack[len(stack)-argc:]...)
+			stack = stack[:len(stack)-argc]
+		}
+		switch node.Kind {
+		case ast.Unary:
+			node.End = node.Args[0].End
+		case ast.Binary:
+			node.Start, node.End = node.Args[0].Start, node.Args[1].End
+		}
+
+		stack = append(stack, node)
+	}
+
+	if len(stack) == 0 {
+		return nil
+	}
+	return stack[0]
+}
+
+// operandEnd returns the end of the number, array or name starting at
+// runes[pos].
+func operandEnd(runes []rune, pos int, variables map[string]float64) int {
+	if runes[pos] == '[' {
+		_, end, _ := scanMatrix(runes, pos)
+		return end
+	}
+	if isDigit(runes[pos]) || runes[pos] == '.' {
+		end := scanNumber(runes, pos)
+		if isImaginary(runes, end) {
+			end++
+		} else if unitEnd, _, ok := unitSuffix(runes, end, variables); ok {
+			end = unitEnd
+		}
+		return end
+	}
+
+	end := pos + 1
+	for end < len(runes) && (unicode.IsLetter(runes[end]) || unicode.IsDigit(runes[end]) || runes[end] == '_') {
+		end++
+	}
+	return end
+}
+
+// targetEnd returns the end of the unit a quantity is converted to, which
+// stands for the literal 1 unit.
+func targetEnd(runes []rune, tok token) (int, bool) {
+	target, ok := ParseQuantity(tok.value)
+	if !ok || !unicode.IsLetter(runes[tok.pos]) {
+		return 0, false
+	}
+	end, unit, ok := scanUnit(runes, tok.pos, true)
+	return end, ok && unit == target.Unit
+}
+
+// closingParen returns the position of the parenthesis that closes the
+// argument list of the call whose name starts at runes[pos].
+func closingParen(runes []rune, pos int) int {
+	depth := 0
+	for i := pos; i < len(runes); i++ {
+		switch runes[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(runes) - 1
+}
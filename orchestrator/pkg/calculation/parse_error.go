@@ -0,0 +1,121 @@
+package calculation
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/DobryySoul/orchestrator/pkg/calculation/ast"
+)
+
+var errorCodes = map[error]string{
+	ErrInvalidExpression:     "invalid_expression",
+	ErrDivisionByZero:        "division_by_zero",
+	ErrNotEnoughOperands:     "not_enough_operands",
+	ErrMismatchedParentheses: "mismatched_parentheses",
+	ErrUnknownOperator:       "unknown_operator",
+	ErrEmptyExpression:       "empty_expression",
+	ErrInvalidCharacter:      "invalid_character",
+	ErrInvalidNumber:         "invalid_number",
+	ErrUnknownFunction:       "unknown_function",
+	ErrWrongArgumentCount:    "wrong_argument_count",
+	ErrUnboundVariable:       "unbound_variable",
+	ErrInvalidVariableName:   "invalid_variable_name",
+	ErrReservedName:          "reserved_name",
+	ErrUnknownPrecision:      "unknown_precision",
+	ErrUnknownNumberMode:     "unknown_number_mode",
+	ErrUnknownSeparator:      "unknown_decimal_separator",
+	ErrUnknownLocale:         "unknown_locale",
+	ErrInvalidFormat:         "invalid_format",
+	ErrNonFiniteResult:       "non_finite_result",
+	ErrUnknownPolicy:         "unknown_non_finite_policy",
+	ErrUnknownReference:      "unknown_reference",
+	ErrPendingReference:      "pending_reference",
+	ErrFailedReference:       "failed_reference",
+	ErrReferenceValue:        "unusable_reference",
+	ErrNonIntegerOperand:     "non_integer_operand",
+	ErrInvalidShift:          "invalid_shift",
+	ErrComplexOperand:        "complex_operand",
+	ErrInvalidMatrix:         "invalid_matrix",
+	ErrShapeMismatch:         "shape_mismatch",
+	ErrMatrixOperand:         "matrix_operand",
+	ErrUnknownUnit:           "unknown_unit",
+	ErrDimensionMismatch:     "dimension_mismatch",
+	ErrQuantityOperand:       "quantity_operand",
+	ErrIrrationalOperation:   "irrational_operation",
+}
+
+// ParseError points at the token of the source expression that made it
+// invalid. Kind is one of the package sentinels, so errors.Is keeps working.
+type ParseError struct {
+	Kind       error
+	Expression string
+	Position   int // rune offset of Token in Expression
+	Token      string
+	Suggestion string
+}
+
+func (e *ParseError) Error() string {
+	if e.Token == "" {
+		return fmt.Sprintf("%v at position %d", e.Kind, e.Position)
+	}
+	return fmt.Sprintf("%v %q at position %d", e.Kind, e.Token, e.Position)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Kind
+}
+
+// Code is a stable machine-readable name of the error kind.
+func (e *ParseError) Code() string {
+	if code, ok := errorCodes[e.Kind]; ok {
+		return code
+	}
+	return errorCodes[ErrInvalidExpression]
+}
+
+// Caret returns a line that underlines Token when printed below Expression.
+func (e *ParseError) Caret() string {
+	width := len([]rune(e.Token))
+	if width == 0 {
+		width = 1
+	}
+	return strings.Repeat(" ", e.Position) + strings.Repeat("^", width)
+}
+
+func errorAt(kind error, tok token, suggestion string) *ParseError {
+	return &ParseError{
+		Kind:       kind,
+		Position:   tok.pos,
+		Token:      sourceText(tok.value),
+		Suggestion: suggestion,
+	}
+}
+
+// withSource attaches the original expression to a ParseError found in err.
+func withSource(err error, expression string) error {
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		parseErr.Expression = expression
+	}
+	return err
+}
+
+// sourceText maps an internal token back to how it is spelled in the input.
+func sourceText(value string) string {
+	if name, _, ok := ParseCall(value); ok {
+		return name
+	}
+	return ast.Spelling(value)
+}
+
+func functionNames() string {
+	names := make([]string, 0, len(functions))
+	for name := range functions {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return strings.Join(names, ", ")
+}
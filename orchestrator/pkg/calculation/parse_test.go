@@ -0,0 +1,115 @@
+package calculation
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DobryySoul/orchestrator/pkg/calculation/ast"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		expected   string
+	}{
+		{"precedence", "1 + 2 * 3", "1 + 2 * 3"},
+		{"redundant parentheses", "((1 + 2)) * (3)", "(1 + 2) * 3"},
+		{"power alias", "2 ** 3 ** 2", "2 ^ 3 ^ 2"},
+		{"unary", "-(1 + 2) + +3", "-(1 + 2) + 3"},
+		{"literal forms", "0xFF + 1_000 + 1e3", "255 + 1000 + 1e3"},
+		{"names stay unbound", "pi * r ^ 2 + ans", "pi * r ^ 2 + ans"},
+		{"calls", "max(1, min(2, 3), if(x > 0, x, -x))", "max(1, min(2, 3), if(x > 0, x, -x))"},
+		{"logic", "!a && b || c == 1", "!a && b || c == 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := Parse(tt.expression)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if got := tree.String(); got != tt.expected {
+				t.Errorf("Parse().String() = %q, expected %q", got, tt.expected)
+			}
+
+			reparsed, err := Parse(tree.String())
+			if err != nil || reparsed.String() != tree.String() {
+				t.Errorf("String() does not round-trip: %q, error = %v", reparsed, err)
+			}
+		})
+	}
+}
+
+func TestParseSpans(t *testing.T) {
+	expression := "max(x, (10)) * -$1 + 2.5i"
+	tree, err := Parse(expression)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	runes := []rune(expression)
+	expected := map[string]string{
+		"+":    "max(x, (10)) * -$1 + 2.5i",
+		"*":    "max(x, (10)) * -$1",
+		"max":  "max(x, (10))",
+		"x":    "x",
+		"10":   "10",
+		"neg":  "-$1",
+		"$1":   "$1",
+		"2.5i": "2.5i",
+	}
+
+	ast.Walk(tree, func(node *ast.Node) bool {
+		if got := string(runes[node.Start:node.End]); got != expected[node.Value] {
+			t.Errorf("span of %s %q = %q, expected %q", node.Kind, node.Value, got, expected[node.Value])
+		}
+		return true
+	})
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		expression string
+		err        error
+	}{
+		{"", ErrEmptyExpression},
+		{"1 +", ErrNotEnoughOperands},
+		{"(1 + 2", ErrMismatchedParentheses},
+		{"foo(1)", ErrUnknownFunction},
+		{"1 2", ErrInvalidExpression},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			if _, err := Parse(tt.expression); !errors.Is(err, tt.err) {
+				t.Errorf("Parse() error = %v, expected %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestParseWithOptions(t *testing.T) {
+	tree, err := ParseWithOptions("2 * pi * r", Options{Variables: map[string]float64{"r": 0.5}})
+	if err != nil {
+		t.Fatalf("ParseWithOptions() error = %v", err)
+	}
+
+	var kinds []ast.Kind
+	ast.Walk(tree, func(node *ast.Node) bool {
+		kinds = append(kinds, node.Kind)
+		return true
+	})
+	for _, kind := range kinds {
+		if kind == ast.Variable {
+			t.Errorf("ParseWithOptions() left a variable in %q", tree)
+		}
+	}
+	if got := tree.Args[1]; got.Value != "0.5" || got.Start != 9 || got.End != 10 {
+		t.Errorf("bound variable = %q at %d:%d, expected 0.5 at 9:10", got.Value, got.Start, got.End)
+	}
+
+	if _, err := ParseWithOptions("1 / 0", Options{}); !errors.Is(err, ErrDivisionByZero) {
+		t.Errorf("ParseWithOptions() error = %v, expected %v", err, ErrDivisionByZero)
+	}
+}
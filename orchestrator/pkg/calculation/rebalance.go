@@ -0,0 +1,60 @@
+package calculation
+
+import "github.com/DobryySoul/orchestrator/pkg/calculation/ast"
+
+// Rebalance regroups chains of + and * into balanced trees, so that
+// 1+2+3+4 is computed as (1+2)+(3+4) and both additions can run at once.
+// Operands keep their order, but floating-point results may differ in the
+// last digits from strict left-to-right evaluation. Products involving
+// matrices are left alone: a vector is a row or a column depending on the
+// side it is multiplied from, so regrouping can change the result.
+func Rebalance(tree *ast.Node) *ast.Node {
+	if tree.Kind == ast.Binary && isAssociative(tree.Value) && !(tree.Value == "*" && hasMatrix(tree)) {
+		operands := chainOperands(tree, tree.Value, nil)
+		for i, operand := range operands {
+			operands[i] = Rebalance(operand)
+		}
+		return balancedChain(tree.Value, operands)
+	}
+
+	rebalanced := *tree
+	rebalanced.Args = make([]*ast.Node, len(tree.Args))
+	for i, arg := range tree.Args {
+		rebalanced.Args[i] = Rebalance(arg)
+	}
+	return &rebalanced
+}
+
+func isAssociative(operator string) bool {
+	return operator == "+" || operator == "*"
+}
+
+// chainOperands appends the operands of the chain of operator rooted at
+// node in source order.
+func chainOperands(node *ast.Node, operator string, operands []*ast.Node) []*ast.Node {
+	if node.Kind != ast.Binary || node.Value != operator {
+		return append(operands, node)
+	}
+	for _, arg := range node.Args {
+		operands = chainOperands(arg, operator, operands)
+	}
+	return operands
+}
+
+func balancedChain(operator string, operands []*ast.Node) *ast.Node {
+	if len(operands) == 1 {
+		return operands[0]
+	}
+
+	middle := len(operands) / 2
+	left := balancedChain(operator, operands[:middle])
+	right := balancedChain(operator, operands[middle:])
+
+	return &ast.Node{
+		Kind:  ast.Binary,
+		Value: operator,
+		Args:  []*ast.Node{left, right},
+		Start: left.Start,
+		End:   right.End,
+	}
+}
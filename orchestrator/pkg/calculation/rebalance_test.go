@@ -0,0 +1,57 @@
+package calculation
+
+import (
+	"testing"
+
+	"github.com/DobryySoul/orchestrator/pkg/calculation/ast"
+)
+
+func TestRebalance(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		expected   string
+		depth      int
+	}{
+		{"left-deep sum", "1+2+3+4+5+6+7+8", "1 + 2 + (3 + 4) + (5 + 6 + (7 + 8))", 3},
+		{"odd length", "a*b*c*d*e", "a * b * (c * (d * e))", 3},
+		{"mixed operators", "a + b*c*d*e + f", "a + (b * c * (d * e) + f)", 4},
+		{"subtraction is kept", "a - b - c - d", "a - b - c - d", 3},
+		{"nested chains", "max(a+b+c+d, 1) + e", "max(a + b + (c + d), 1) + e", 4},
+		{"power is kept", "2 ^ 3 ^ 2", "2 ^ 3 ^ 2", 2},
+		{"matrix products are kept", "[[1]] * [1] * [[1]] * a", "[[1]] * [1] * [[1]] * a", 3},
+		{"matrix sums", "[1] + [2] + [3] + [4]", "[1] + [2] + ([3] + [4])", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := Parse(tt.expression)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			rebalanced := Rebalance(tree)
+			if got := rebalanced.String(); got != tt.expected {
+				t.Errorf("Rebalance() = %q, expected %q", got, tt.expected)
+			}
+			if got := depth(rebalanced); got != tt.depth {
+				t.Errorf("Rebalance() depth = %d, expected %d", got, tt.depth)
+			}
+			if rebalanced.Start != tree.Start || rebalanced.End != tree.End {
+				t.Errorf("Rebalance() span = %d:%d, expected %d:%d", rebalanced.Start, rebalanced.End, tree.Start, tree.End)
+			}
+		})
+	}
+}
+
+// depth counts the operations on the longest path from the root to a leaf.
+func depth(node *ast.Node) int {
+	deepest := 0
+	for _, arg := range node.Args {
+		deepest = max(deepest, depth(arg))
+	}
+	if len(node.Args) > 0 {
+		deepest++
+	}
+	return deepest
+}
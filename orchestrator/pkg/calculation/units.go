@@ -0,0 +1,545 @@
+package calculation
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/DobryySoul/orchestrator/pkg/calculation/ast"
+)
+
+const (
+	ConvertTo   = ast.ConvertTo
+	convertToIn = "in" // another spelling of ConvertTo
+)
+
+// baseUnits are the SI base units in the order they are printed.
+var baseUnits = [...]string{"m", "kg", "s", "A", "K", "mol", "cd"}
+
+// Dimension holds the exponents of the SI base units of a quantity.
+type Dimension [len(baseUnits)]int
+
+func (d Dimension) IsZero() bool {
+	return d == Dimension{}
+}
+
+func (d Dimension) times(other Dimension, sign int) Dimension {
+	for i := range d {
+		d[i] += sign * other[i]
+	}
+	return d
+}
+
+func (d Dimension) power(n int) Dimension {
+	for i := range d {
+		d[i] *= n
+	}
+	return d
+}
+
+// String renders d in SI base units such as m^2*kg/s^2, or s^-1 when it has
+// no positive exponents.
+func (d Dimension) String() string {
+	var numerator, denominator []string
+	for i, exponent := range d {
+		switch {
+		case exponent > 0:
+			numerator = append(numerator, unitPower(baseUnits[i], exponent))
+		case exponent < 0:
+			denominator = append(denominator, unitPower(baseUnits[i], -exponent))
+		}
+	}
+
+	if len(numerator) == 0 {
+		for i := range denominator {
+			name, exponent, _ := strings.Cut(denominator[i], "^")
+			if exponent == "" {
+				exponent = "1"
+			}
+			denominator[i] = name + "^-" + exponent
+		}
+		return strings.Join(denominator, "*")
+	}
+
+	result := strings.Join(numerator, "*")
+	for _, unit := range denominator {
+		result += "/" + unit
+	}
+	return result
+}
+
+func unitPower(name string, exponent int) string {
+	if exponent == 1 {
+		return name
+	}
+	return name + "^" + strconv.Itoa(exponent)
+}
+
+type unitDefinition struct {
+	factor float64 // size of the unit in SI base units
+	dim    Dimension
+}
+
+func dimension(exponents ...int) Dimension {
+	var d Dimension
+	copy(d[:], exponents)
+	return d
+}
+
+var (
+	length      = dimension(1)
+	mass        = dimension(0, 1)
+	duration    = dimension(0, 0, 1)
+	force       = dimension(1, 1, -2)
+	energy      = dimension(2, 1, -2)
+	power       = dimension(2, 1, -3)
+	pressure    = dimension(-1, 1, -2)
+	volume      = dimension(3)
+	frequency   = dimension(0, 0, -1)
+	current     = dimension(0, 0, 0, 1)
+	temperature = dimension(0, 0, 0, 0, 1)
+	amount      = dimension(0, 0, 0, 0, 0, 1)
+	luminosity  = dimension(0, 0, 0, 0, 0, 0, 1)
+)
+
+// units are the unit names known to the parser. Inches are spelled "inch",
+// since "in" converts.
+var units = map[string]unitDefinition{
+	"m": {1, length}, "km": {1e3, length}, "cm": {1e-2, length}, "mm": {1e-3, length},
+	"um": {1e-6, length}, "nm": {1e-9, length},
+	"inch": {0.0254, length}, "ft": {0.3048, length}, "yd": {0.9144, length},
+	"mi": {1609.344, length}, "nmi": {1852, length},
+
+	"kg": {1, mass}, "g": {1e-3, mass}, "mg": {1e-6, mass}, "t": {1e3, mass},
+	"lb": {0.45359237, mass}, "oz": {0.028349523125, mass},
+
+	"s": {1, duration}, "ms": {1e-3, duration}, "us": {1e-6, duration}, "ns": {1e-9, duration},
+	"min": {60, duration}, "h": {3600, duration}, "d": {86400, duration},
+
+	"N": {1, force}, "kN": {1e3, force},
+	"J": {1, energy}, "kJ": {1e3, energy}, "Wh": {3600, energy}, "kWh": {3.6e6, energy},
+	"W": {1, power}, "kW": {1e3, power},
+	"Pa": {1, pressure}, "kPa": {1e3, pressure}, "bar": {1e5, pressure},
+	"L": {1e-3, volume}, "mL": {1e-6, volume},
+	"Hz": {1, frequency}, "kHz": {1e3, frequency},
+	"A": {1, current}, "mA": {1e-3, current},
+	"K":   {1, temperature},
+	"mol": {1, amount},
+	"cd":  {1, luminosity},
+}
+
+func isUnit(name string) bool {
+	_, ok := units[name]
+	return ok
+}
+
+// DefineUnits adds units given as "name=definition" pairs separated by
+// semicolons, for example "furlong=201.168 m; knot=1852 m/h". It is meant to
+// be called once at startup, before expressions are parsed.
+func DefineUnits(definitions string) error {
+	for _, definition := range strings.Split(definitions, ";") {
+		if strings.TrimSpace(definition) == "" {
+			continue
+		}
+
+		name, value, found := strings.Cut(definition, "=")
+		name = strings.TrimSpace(name)
+		if !found || !isIdentifier(name) {
+			return fmt.Errorf("invalid unit definition %q: use name=value unit", definition)
+		}
+		if isUnit(name) || IsFunction(name) || IsConstant(name) || ast.Precedence(name) > 0 || name == convertToIn || name == ImaginaryUnit {
+			return fmt.Errorf("%w: unit %q", ErrReservedName, name)
+		}
+
+		quantity, ok := ParseQuantity(strings.TrimSpace(value))
+		if !ok {
+			return fmt.Errorf("%w in definition of %q: %q", ErrUnknownUnit, name, value)
+		}
+		factor, dim, _ := parseUnit(quantity.Unit)
+		units[name] = unitDefinition{quantity.Value * factor, dim}
+	}
+	return nil
+}
+
+// parseUnit resolves a unit expression such as km/h or kg*m^2/s^2.
+func parseUnit(expression string) (float64, Dimension, bool) {
+	factor, dim := 1.0, Dimension{}
+	if strings.TrimSpace(expression) == "" {
+		return 0, dim, false
+	}
+
+	sign := 1
+	rest := expression
+	for {
+		rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+		end := strings.IndexFunc(rest, func(r rune) bool { return !unicode.IsLetter(r) && r != '_' })
+		if end < 0 {
+			end = len(rest)
+		}
+		unit, ok := units[rest[:end]]
+		if !ok {
+			return 0, dim, false
+		}
+		rest = strings.TrimLeftFunc(rest[end:], unicode.IsSpace)
+
+		exponent := 1
+		if strings.HasPrefix(rest, "^") {
+			digits := strings.TrimLeftFunc(rest[1:], unicode.IsSpace)
+			n := 0
+			if strings.HasPrefix(digits, "-") {
+				n = 1
+			}
+			for n < len(digits) && isDigit(rune(digits[n])) {
+				n++
+			}
+			var err error
+			if exponent, err = strconv.Atoi(digits[:n]); err != nil {
+				return 0, dim, false
+			}
+			rest = strings.TrimLeftFunc(digits[n:], unicode.IsSpace)
+		}
+
+		factor *= math.Pow(unit.factor, float64(sign*exponent))
+		dim = dim.times(unit.dim.power(exponent), sign)
+
+		switch {
+		case rest == "":
+			return factor, dim, true
+		case rest[0] == '*':
+			sign = 1
+		case rest[0] == '/':
+			sign = -1
+		default:
+			return 0, dim, false
+		}
+		rest = rest[1:]
+	}
+}
+
+// SIUnit returns the SI base units of a unit expression, or "" for a
+// dimensionless one.
+func SIUnit(unit string) string {
+	_, dim, _ := parseUnit(unit)
+	return dim.String()
+}
+
+// Quantity is a number with a unit, written like 5 km or 9.81 m/s^2.
+type Quantity struct {
+	Value float64 // in Unit
+	Unit  string
+}
+
+// ParseQuantity parses a number followed by a unit expression.
+func ParseQuantity(literal string) (Quantity, bool) {
+	number, unit, found := strings.Cut(literal, " ")
+	if !found {
+		return Quantity{}, false
+	}
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return Quantity{}, false
+	}
+	unit = strings.TrimSpace(unit)
+	if _, _, ok := parseUnit(unit); !ok {
+		return Quantity{}, false
+	}
+	return Quantity{Value: value, Unit: unit}, true
+}
+
+// SI returns the value of q in SI base units.
+func (q Quantity) SI() float64 {
+	factor, _, _ := parseUnit(q.Unit)
+	return q.Value * factor
+}
+
+// FormatQuantity renders a value given in SI base units in unit. Values are
+// rounded to 15 significant digits, so that conversions such as 10 km/h do
+// not show the error of the intermediate SI value.
+func FormatQuantity(si float64, unit string) string {
+	return Format{}.Quantity(si, unit)
+}
+
+// scanUnit returns the end of the unit expression that starts at runes[pos]
+// and its text without spaces. Spaces around "*", "/" and "^" are only
+// allowed when spaced is set; the suffix of a literal must be written
+// without them, so that 5 km / 30 min divides two quantities.
+func scanUnit(runes []rune, pos int, spaced bool) (int, string, bool) {
+	skipSpace := func(i int) int {
+		for spaced && i < len(runes) && unicode.IsSpace(runes[i]) {
+			i++
+		}
+		return i
+	}
+	name := func(i int) int {
+		for i < len(runes) && (unicode.IsLetter(runes[i]) || runes[i] == '_') {
+			i++
+		}
+		return i
+	}
+
+	var text strings.Builder
+	end := pos
+	for {
+		nameEnd := name(end)
+		if !isUnit(string(runes[end:nameEnd])) {
+			return end, text.String(), text.Len() > 0
+		}
+		text.WriteString(string(runes[end:nameEnd]))
+		end = nameEnd
+
+		if i := skipSpace(end); i < len(runes) && runes[i] == '^' {
+			j := skipSpace(i + 1)
+			digits := j
+			if digits < len(runes) && runes[digits] == '-' {
+				digits++
+			}
+			k := digits
+			for k < len(runes) && isDigit(runes[k]) {
+				k++
+			}
+			if k > digits {
+				text.WriteString("^" + string(runes[j:k]))
+				end = k
+			}
+		}
+
+		i := skipSpace(end)
+		if i >= len(runes) || (runes[i] != '*' && runes[i] != '/') {
+			return end, text.String(), true
+		}
+		next := skipSpace(i + 1)
+		if !isUnit(string(runes[next:name(next)])) {
+			return end, text.String(), true
+		}
+		text.WriteRune(runes[i])
+		end = next
+	}
+}
+
+// unitSuffix returns the end and the text of the unit written after the
+// number literal ending at runes[end], if there is one. A name bound in
+// variables is not a unit there.
+func unitSuffix(runes []rune, end int, variables map[string]float64) (int, string, bool) {
+	start := end
+	for start < len(runes) && unicode.IsSpace(runes[start]) {
+		start++
+	}
+
+	nameEnd := start
+	for nameEnd < len(runes) && (unicode.IsLetter(runes[nameEnd]) || unicode.IsDigit(runes[nameEnd]) || runes[nameEnd] == '_') {
+		nameEnd++
+	}
+	next := nameEnd
+	for next < len(runes) && unicode.IsSpace(runes[next]) {
+		next++
+	}
+	name := string(runes[start:nameEnd])
+	if _, bound := variables[name]; bound || !isUnit(name) || next < len(runes) && runes[next] == '(' {
+		return end, "", false
+	}
+
+	return scanUnit(runes, start, false)
+}
+
+// scanTarget reads the unit a quantity is converted to, starting at
+// runes[pos] right after the keyword "to" or "in", as the literal 1 unit.
+// The target ends the expression or a parenthesised part of it.
+func scanTarget(runes []rune, pos int, keyword token) (int, token, error) {
+	for pos < len(runes) && unicode.IsSpace(runes[pos]) {
+		pos++
+	}
+	end, unit, ok := scanUnit(runes, pos, true)
+	if !ok {
+		nameEnd := pos
+		for nameEnd < len(runes) && (unicode.IsLetter(runes[nameEnd]) || unicode.IsDigit(runes[nameEnd]) || runes[nameEnd] == '_') {
+			nameEnd++
+		}
+		if nameEnd == pos {
+			return 0, token{}, missingTarget(runes, keyword)
+		}
+		name := string(runes[pos:nameEnd])
+		if keywordAt(runes, keyword) == convertToIn && (name == ConvertTo || name == convertToIn) {
+			return 0, token{}, errorAt(ErrUnknownUnit, token{convertToIn, keyword.pos}, fmt.Sprintf(`write inches as "inch" before %q`, name))
+		}
+		return 0, token{}, errorAt(ErrUnknownUnit, token{name, pos}, "use one of: "+unitNames())
+	}
+
+	next := end
+	for next < len(runes) && unicode.IsSpace(runes[next]) {
+		next++
+	}
+	if next < len(runes) && runes[next] != ')' && runes[next] != ',' {
+		return 0, token{}, errorAt(ErrInvalidExpression, token{string(runes[next]), next}, "put the conversion in parentheses")
+	}
+	return end, token{"1 " + unit, pos}, nil
+}
+
+// missingTarget reports a conversion keyword with no unit after it. The
+// keyword "in" right after a number is most likely meant as inches.
+func missingTarget(runes []rune, keyword token) error {
+	word := keywordAt(runes, keyword)
+	hint := fmt.Sprintf("name a unit after %q", word)
+	if word == convertToIn {
+		hint += `, or write inches as "inch"`
+	}
+	return errorAt(ErrUnknownUnit, token{word, keyword.pos}, hint)
+}
+
+// keywordAt returns the conversion keyword as written in the expression,
+// since the tokenizer turns "in" into "to".
+func keywordAt(runes []rune, keyword token) string {
+	return string(runes[keyword.pos : keyword.pos+len(ConvertTo)])
+}
+
+// unitToken returns the first literal of rpn that carries a unit.
+func unitToken(rpn []token) (token, bool) {
+	for _, tok := range rpn {
+		if isQuantity(tok.value) {
+			return tok, true
+		}
+	}
+	return token{}, false
+}
+
+func isQuantity(literal string) bool {
+	_, ok := ParseQuantity(literal)
+	return ok
+}
+
+// sameDimension operations need operands of one dimension; comparisons
+// among them give plain numbers.
+var sameDimension = map[string]bool{
+	"+": true, "-": true, "%": true, "min": true, "max": true,
+	"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true, "//": true,
+}
+
+// applyQuantity computes an operation on operands of which at least one
+// carries a unit, checking and combining their dimensions.
+func applyQuantity(tok token, args []value) (value, error) {
+	reals := make([]float64, len(args))
+	for i, arg := range args {
+		switch {
+		case arg.matrix != nil:
+			return value{}, errorAt(ErrQuantityOperand, tok, "matrices cannot carry units")
+		case imag(arg.number) != 0:
+			return value{}, errorAt(ErrComplexOperand, tok, "quantities hold real numbers")
+		}
+		reals[i] = real(arg.number)
+	}
+
+	name := operationName(tok.value)
+	var dim Dimension
+	unit := ""
+	switch {
+	case name == ConvertTo:
+		if args[0].dim != args[1].dim {
+			return value{}, errorAt(ErrDimensionMismatch, tok, fmt.Sprintf("%s cannot be converted to %s", describe(args[0]), args[1].unit))
+		}
+		return value{number: args[0].number, dim: args[0].dim, unit: args[1].unit}, nil
+	case sameDimension[name]:
+		for _, arg := range args[1:] {
+			if arg.dim != args[0].dim {
+				return value{}, errorAt(ErrDimensionMismatch, tok, fmt.Sprintf("%s and %s have different dimensions", describe(args[0]), describe(arg)))
+			}
+		}
+		if name == "+" || name == "-" || name == "%" || name == "min" || name == "max" {
+			dim, unit = args[0].dim, args[0].unit
+		}
+	case name == "*":
+		dim = args[0].dim.times(args[1].dim, 1)
+		unit = scaledUnit(args[0], args[1])
+		if unit == "" {
+			unit = scaledUnit(args[1], args[0])
+		}
+	case name == "/":
+		dim = args[0].dim.times(args[1].dim, -1)
+		unit = scaledUnit(args[0], args[1])
+	case name == UnaryMinus || name == "abs":
+		dim, unit = args[0].dim, args[0].unit
+	case name == "^":
+		exponent := reals[1]
+		if !args[1].dim.IsZero() {
+			return value{}, errorAt(ErrDimensionMismatch, tok, "use a plain number as the exponent")
+		}
+		if !args[0].dim.IsZero() && exponent != math.Trunc(exponent) {
+			return value{}, errorAt(ErrDimensionMismatch, tok, "raise a quantity to an integer power")
+		}
+		dim = args[0].dim.power(int(exponent))
+	case name == "sqrt":
+		for _, exponent := range args[0].dim {
+			if exponent%2 != 0 {
+				return value{}, errorAt(ErrDimensionMismatch, tok, fmt.Sprintf("%s has no square root", describe(args[0])))
+			}
+		}
+		dim = args[0].dim
+		for i := range dim {
+			dim[i] /= 2
+		}
+	default:
+		for _, arg := range args {
+			if !arg.dim.IsZero() {
+				return value{}, errorAt(ErrQuantityOperand, tok, fmt.Sprintf("%s expects plain numbers", sourceText(tok.value)))
+			}
+		}
+	}
+
+	result, err := applyFloat(tok, reals)
+	return value{number: complex(result, 0), dim: dim, unit: unit}, err
+}
+
+// scaledUnit keeps the unit of quantity when it is scaled by a plain
+// number, so that 2 * 5 km stays in km.
+func scaledUnit(quantity, factor value) string {
+	if factor.unit != "" || !factor.dim.IsZero() {
+		return ""
+	}
+	return quantity.unit
+}
+
+// describe names the unit of v for error messages.
+func describe(v value) string {
+	switch {
+	case v.unit != "":
+		return v.unit
+	case v.dim.IsZero():
+		return "a plain number"
+	}
+	return v.dim.String()
+}
+
+// ResultUnit returns the unit of the result of operation applied to
+// operands given by their values in SI base units and the units they are
+// shown in. Units are kept as evaluation keeps them, so 2 km + 300 m is in
+// km, while other results are in SI base units.
+func ResultUnit(operation string, values []float64, units []string) (string, error) {
+	args := make([]value, len(values))
+	for i := range values {
+		_, dim, _ := parseUnit(units[i])
+		args[i] = value{number: complex(values[i], 0), dim: dim, unit: units[i]}
+	}
+
+	if IsFunction(operation) {
+		operation = FormatCall(operation, len(args))
+	}
+	result, err := applyQuantity(token{value: operation}, args)
+	if err != nil {
+		return "", err
+	}
+	if result.unit != "" {
+		return result.unit, nil
+	}
+	return result.dim.String(), nil
+}
+
+// unitNames lists the known units for hints.
+func unitNames() string {
+	names := make([]string, 0, len(units))
+	for name := range units {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,116 @@
+package calculation
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestUnitErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		opts       Options
+		kind       error
+		position   int
+	}{
+		{"metres plus seconds", "1 m + 2 s", Options{}, ErrDimensionMismatch, 4},
+		{"conversion", "3 ft to kg", Options{}, ErrDimensionMismatch, 5},
+		{"plain number", "3 to m", Options{}, ErrDimensionMismatch, 2},
+		{"unknown target", "3 ft to parsec", Options{}, ErrUnknownUnit, 8},
+		{"target expression", "3 ft to m + 1", Options{}, ErrInvalidExpression, 10},
+		{"fractional power", "4 m ^ 0.5", Options{}, ErrDimensionMismatch, 4},
+		{"odd square root", "sqrt(2 m)", Options{}, ErrDimensionMismatch, 0},
+		{"function", "sin(1 m)", Options{}, ErrQuantityOperand, 0},
+		{"matrix", "[1, 2] * 3 m", Options{}, ErrQuantityOperand, 7},
+		{"exact precision", "2 * 3 m", Options{Precision: PrecisionExact}, ErrQuantityOperand, 4},
+		{"complex", "2i * 3 m", Options{}, ErrComplexOperand, 3},
+		{"inch written as in", "2in", Options{}, ErrUnknownUnit, 1},
+		{"inch written as in before to", "1 in to cm", Options{}, ErrUnknownUnit, 2},
+		{"conversion without target", "(3 ft to) * 2", Options{}, ErrUnknownUnit, 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := RPNWithOptions(tt.expression, tt.opts)
+
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) || !errors.Is(err, tt.kind) {
+				t.Fatalf("RPNWithOptions(%q) error = %v, want %v", tt.expression, err, tt.kind)
+			}
+			if parseErr.Position != tt.position {
+				t.Errorf("error position = %d, want %d", parseErr.Position, tt.position)
+			}
+		})
+	}
+}
+
+func TestVariablesShadowUnits(t *testing.T) {
+	opts := Options{Variables: map[string]float64{"m": 3}}
+
+	rpn, err := RPNWithOptions("2 m * 1 km to m", opts)
+	if err != nil {
+		t.Fatalf("RPNWithOptions() error = %v", err)
+	}
+	if want := []string{"2", "3", "*", "1 km", "*", "1 m", ConvertTo}; !slices.Equal(rpn, want) {
+		t.Errorf("RPNWithOptions() = %v, want %v", rpn, want)
+	}
+
+	tree, err := ParseWithOptions("2 m", opts)
+	if err != nil {
+		t.Fatalf("ParseWithOptions() error = %v", err)
+	}
+	if left := tree.Args[0]; left.End != 1 {
+		t.Errorf("span of 2 ends at %d, want 1", left.End)
+	}
+}
+
+func TestDefineUnits(t *testing.T) {
+	if err := DefineUnits("furlong=201.168 m; fortnight=14 d"); err != nil {
+		t.Fatalf("DefineUnits() error = %v", err)
+	}
+	t.Cleanup(func() {
+		delete(units, "furlong")
+		delete(units, "fortnight")
+	})
+
+	rpn, err := RPN("1 furlong/fortnight to mm/min")
+	if err != nil {
+		t.Fatalf("RPN() error = %v", err)
+	}
+	if want := []string{"1 furlong/fortnight", "1 mm/min", ConvertTo}; !slices.Equal(rpn, want) {
+		t.Errorf("RPN() = %v, want %v", rpn, want)
+	}
+
+	for _, definitions := range []string{"m=1 ft", "sin=2 m", "in=1 m", "knot=1852 parsec/h", "knot"} {
+		if err := DefineUnits(definitions); err == nil {
+			t.Errorf("DefineUnits(%q) error = nil, want an error", definitions)
+		}
+	}
+}
+
+func TestResultUnit(t *testing.T) {
+	tests := []struct {
+		operation string
+		values    []float64
+		units     []string
+		expected  string
+	}{
+		{"/", []float64{5000, 1800}, []string{"m", "s"}, "m/s"},
+		{"*", []float64{2, 3}, []string{"kg*m/s^2", "m"}, "m^2*kg/s^2"},
+		{"sqrt", []float64{4}, []string{"m^2"}, "m"},
+		{"+", []float64{2000, 300}, []string{"km", "m"}, "km"},
+		{"+", []float64{1, 2}, []string{"", ""}, ""},
+	}
+
+	for _, tt := range tests {
+		got, err := ResultUnit(tt.operation, tt.values, tt.units)
+		if err != nil || got != tt.expected {
+			t.Errorf("ResultUnit(%q, %v) = %q, %v, want %q", tt.operation, tt.units, got, err, tt.expected)
+		}
+	}
+
+	if _, err := ResultUnit("+", []float64{1, 2}, []string{"m", "s"}); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("ResultUnit(m + s) error = %v, want %v", err, ErrDimensionMismatch)
+	}
+}
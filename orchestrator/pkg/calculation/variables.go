@@ -0,0 +1,92 @@
+package calculation
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+func validateVariables(variables map[string]float64) error {
+	for name := range variables {
+		if !isIdentifier(name) {
+			return fmt.Errorf("%w: %q", ErrInvalidVariableName, name)
+		}
+		if IsFunction(name) || IsUnaryOperator(name) || IsBinaryOperator(name) || IsConstant(name) || name == LastResult || name == convertToIn {
+			return fmt.Errorf("%w: %q", ErrReservedName, name)
+		}
+	}
+	return nil
+}
+
+// bindVariables substitutes constants, variables and resolved references.
+// References the resolver reports as pending are left in place.
+func bindVariables(rpn []token, opts Options) ([]token, error) {
+	bound := make([]token, len(rpn))
+
+	for i, tok := range rpn {
+		if IsReference(tok.value) {
+			value, err := resolveReference(tok, opts.Resolve)
+			if errors.Is(err, ErrPendingReference) {
+				bound[i] = tok
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			bound[i] = token{value, tok.pos}
+			continue
+		}
+
+		if value, ok := constants[tok.value]; ok {
+			bound[i] = token{value, tok.pos}
+			continue
+		}
+
+		if !isIdentifier(tok.value) || IsUnaryOperator(tok.value) || IsBinaryOperator(tok.value) {
+			bound[i] = tok
+			continue
+		}
+
+		value, ok := opts.Variables[tok.value]
+		if !ok {
+			return nil, errorAt(ErrUnboundVariable, tok, fmt.Sprintf("bind %q in variables", tok.value))
+		}
+		bound[i] = token{strconv.FormatFloat(value, 'g', -1, 64), tok.pos}
+	}
+
+	return bound, nil
+}
+
+func resolveReference(tok token, resolve Resolver) (string, error) {
+	if resolve == nil {
+		return "", errorAt(ErrUnknownReference, tok, "references are not available here")
+	}
+
+	value, err := resolve(tok.value)
+	switch {
+	case errors.Is(err, ErrPendingReference):
+		return "", err
+	case errors.Is(err, ErrFailedReference):
+		return "", errorAt(ErrFailedReference, tok, "fix the referenced expression first")
+	case err != nil && tok.value == LastResult:
+		return "", errorAt(ErrUnknownReference, tok, "there is no finished expression yet")
+	case err != nil:
+		return "", errorAt(ErrUnknownReference, tok, "refer to one of your earlier expressions")
+	}
+
+	if !isNumber(value) && !isFraction(value) && !isQuantity(value) && !isMatrix(value) {
+		return "", errorAt(ErrReferenceValue, tok, "the referenced result is not a number or a matrix")
+	}
+
+	return value, nil
+}
+
+// hasPendingReferences reports whether bindVariables left any reference
+// unresolved.
+func hasPendingReferences(rpn []token) bool {
+	for _, tok := range rpn {
+		if IsReference(tok.value) {
+			return true
+		}
+	}
+	return false
+}